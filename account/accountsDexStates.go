@@ -144,7 +144,7 @@ func RemoveDexAccountsFromDB(height int64) error {
 }
 
 func LastHeightStoredInDexAccounts() (int64, error) {
-	i := int64(0)
+	i := database.FirstStoredHeight()
 	for {
 		ib := common.GetByteInt64(i)
 		prefix := append(common.DexAccountsDBPrefix[:], ib...)
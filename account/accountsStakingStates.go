@@ -133,7 +133,7 @@ func RemoveStakingAccountsFromDB(height int64) error {
 }
 
 func LastHeightStoredInStakingAccounts() (int64, error) {
-	i := int64(0)
+	i := database.FirstStoredHeight()
 	for {
 		ib := common.GetByteInt64(i)
 		prefix := append(common.StakingAccountsDBPrefix[:], ib...)
@@ -164,3 +164,25 @@ func GetStakedInAllDelegatedAccounts() int64 {
 
 	return totalStaked
 }
+
+// LoadStakedInDelegatedAccounts returns coins staked in every delegated account as stored after block at height
+func LoadStakedInDelegatedAccounts(height int64) ([256]int64, error) {
+	staked := [256]int64{}
+	for i := 0; i < 256; i++ {
+		prefix := append(common.StakingAccountsDBPrefix[:], common.GetByteInt64(height)...)
+		prefix = append(prefix, byte(i))
+		b, err := database.MainDB.Get(prefix)
+		if err != nil || b == nil {
+			return staked, fmt.Errorf("no staking accounts stored at height %v: LoadStakedInDelegatedAccounts", height)
+		}
+		sas := StakingAccountsType{}
+		err = sas.Unmarshal(b)
+		if err != nil {
+			return staked, err
+		}
+		for _, sa := range sas.AllStakingAccounts {
+			staked[i] += sa.StakedBalance
+		}
+	}
+	return staked, nil
+}
@@ -37,14 +37,15 @@ func GetBalance(address [common.AddressLength]byte) int64 {
 // Marshal converts AccountsType to a binary format.
 func (at AccountsType) Marshal() []byte {
 	var buffer bytes.Buffer
-	// Number of accounts
+	// Number of accounts, negative number marks accounts stored with length
+	// as multi sign and escrow accounts have variable length
 	accountCount := len(at.AllAccounts)
-	buffer.Write(common.GetByteInt64(int64(accountCount)))
+	buffer.Write(common.GetByteInt64(-int64(accountCount) - 1))
 
 	// Iterate over map and marshal each account
 	for address, acc := range at.AllAccounts {
-		buffer.Write(address[:])    // Write address
-		buffer.Write(acc.Marshal()) // Marshal and write account
+		buffer.Write(address[:])                               // Write address
+		buffer.Write(common.BytesToLenAndBytes(acc.Marshal())) // Marshal and write account
 	}
 	buffer.Write(common.GetByteInt64(at.Height))
 	return buffer.Bytes()
@@ -55,6 +56,10 @@ func (at *AccountsType) Unmarshal(data []byte) error {
 	buffer := bytes.NewBuffer(data)
 	// Number of accounts
 	accountCount := common.GetInt64FromByte(buffer.Next(8))
+	withLength := accountCount < 0
+	if withLength {
+		accountCount = -accountCount - 1
+	}
 
 	at.AllAccounts = make(map[[common.AddressLength]byte]Account, accountCount)
 
@@ -69,9 +74,18 @@ func (at *AccountsType) Unmarshal(data []byte) error {
 		}
 
 		// Account binary data
-		accountData := buffer.Next(common.AddressLength + 17) // Account data length (8 bytes for delay + 1 for multisignNumber + 8 bytes for balance + 20 bytes for address)
-		if len(accountData) != common.AddressLength+17 {
-			return fmt.Errorf("incorrect account data length: got %d, want 33", len(accountData))
+		var accountData []byte
+		if withLength {
+			ab, _, err := common.BytesWithLenToBytes(buffer.Bytes())
+			if err != nil {
+				return fmt.Errorf("failed to read account data: %w", err)
+			}
+			accountData = buffer.Next(4 + len(ab))[4:]
+		} else {
+			accountData = buffer.Next(common.AddressLength + 17) // Account data length (8 bytes for delay + 1 for multisignNumber + 8 bytes for balance + 20 bytes for address)
+			if len(accountData) != common.AddressLength+17 {
+				return fmt.Errorf("incorrect account data length: got %d, want 33", len(accountData))
+			}
 		}
 
 		if err := acc.Unmarshal(accountData); err != nil {
@@ -139,7 +153,7 @@ func LoadAccounts(height int64) error {
 }
 
 func LastHeightStoredInAccounts() (int64, error) {
-	i := int64(0)
+	i := database.FirstStoredHeight()
 	for {
 		ib := common.GetByteInt64(i)
 		prefix := append(common.AccountsDBPrefix[:], ib...)
@@ -16,6 +16,15 @@ type Account struct {
 	TransactionDelay   int64                        `json:"transactionDelay"`
 	MultiSignNumber    uint8                        `json:"multiSignNumber"`
 	MultiSignAddresses [][common.AddressLength]byte `json:"multiSignAddresses,omitempty"`
+	Guardian           [common.AddressLength]byte   `json:"guardian,omitempty"`
+}
+
+// guardianMarker precedes guardian address at the end of marshaled account. Accounts without
+// guardian are marshaled as before.
+const guardianMarker = byte('G')
+
+func (a Account) HasGuardian() bool {
+	return a.Guardian != [common.AddressLength]byte{}
 }
 
 func GetAccountByAddressBytes(address []byte) Account {
@@ -31,17 +40,28 @@ func CanBeModifiedAccount(address []byte) bool {
 	return acc.MultiSignNumber == 0 && acc.TransactionDelay == 0
 }
 
-func (a *Account) ModifyAccountToEscrow(transactionDelay int64) error {
+// ModifyAccountToEscrow sets delay of outgoing transactions. Guardian can cancel delayed
+// transactions, zero address means no guardian.
+func (a *Account) ModifyAccountToEscrow(transactionDelay int64, guardian [common.AddressLength]byte) error {
 	if a.TransactionDelay > 0 {
 		return fmt.Errorf("account is just escrow and cannot be modified")
 	}
+	return a.ReconfigureEscrow(transactionDelay, guardian)
+}
+
+// ReconfigureEscrow sets delay and guardian also when account is escrow already
+func (a *Account) ReconfigureEscrow(transactionDelay int64, guardian [common.AddressLength]byte) error {
 	if transactionDelay == 0 {
 		return fmt.Errorf("transaction delay in escrow must be larger than 0")
 	}
 	if transactionDelay > common.MaxTransactionDelay {
 		return fmt.Errorf("transaction delay in escrow must be less than %v", common.MaxTransactionDelay)
 	}
+	if guardian == a.Address {
+		return fmt.Errorf("escrow account cannot be its own guardian")
+	}
 	a.TransactionDelay = transactionDelay
+	a.Guardian = guardian
 	AccountsRWMutex.Lock()
 	Accounts.AllAccounts[a.Address] = *a
 	AccountsRWMutex.Unlock()
@@ -52,6 +72,11 @@ func (a *Account) ModifyAccountToMultiSign(numApprovals uint8, addresses []commo
 	if a.MultiSignNumber > 0 {
 		return fmt.Errorf("account is just MultiSign and cannot be modified")
 	}
+	return a.ReconfigureMultiSign(numApprovals, addresses)
+}
+
+// ReconfigureMultiSign sets co-signers and number of approvals also when account is MultiSign already
+func (a *Account) ReconfigureMultiSign(numApprovals uint8, addresses []common.Address) error {
 	if int(numApprovals) == 0 {
 		return fmt.Errorf("MultiSign must have at least 1 Approval account")
 	}
@@ -104,6 +129,10 @@ func (a Account) Marshal() []byte {
 	for _, msa := range a.MultiSignAddresses {
 		b = append(b, msa[:]...)
 	}
+	if a.HasGuardian() {
+		b = append(b, guardianMarker)
+		b = append(b, a.Guardian[:]...)
+	}
 	return b
 }
 
@@ -116,8 +145,17 @@ func (a *Account) Unmarshal(data []byte) error {
 	copy(a.Address[:], data[8:28])
 	a.TransactionDelay = common.GetInt64FromByte(data[28:36])
 	a.MultiSignNumber = data[36]
+	a.Guardian = [common.AddressLength]byte{}
 	if len(data) > 37 {
 		data = data[37:]
+		if len(data)%common.AddressLength == 1 {
+			gi := len(data) - common.AddressLength - 1
+			if data[gi] != guardianMarker {
+				return fmt.Errorf("wrongly defined guardian of account")
+			}
+			copy(a.Guardian[:], data[gi+1:])
+			data = data[:gi]
+		}
 		lenAccMS := len(data) / 20
 		if int(a.MultiSignNumber) > lenAccMS {
 			return fmt.Errorf("wrongly defined multisign account")
@@ -140,6 +178,9 @@ func (a Account) GetString() string {
 	if a.TransactionDelay > 0 {
 		r += "Escrow account with "
 		r += "Transactions Delayed: " + strconv.FormatInt(a.TransactionDelay, 10) + " blocks\n"
+		if a.HasGuardian() {
+			r += "Guardian: " + hexutil.Encode(a.Guardian[:]) + "\n"
+		}
 	}
 	if a.MultiSignNumber > 0 {
 		r += "Multi Signature account with \n"
@@ -0,0 +1,75 @@
+package account
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+func TestAccount_MarshalGuardian(t *testing.T) {
+	tests := []struct {
+		name string
+		acc  Account
+	}{
+		{"plain", Account{Balance: 5, Address: [common.AddressLength]byte{1}}},
+		{"escrow", Account{Balance: 5, Address: [common.AddressLength]byte{1}, TransactionDelay: 10}},
+		{"escrow with guardian", Account{Balance: 5, Address: [common.AddressLength]byte{1}, TransactionDelay: 10,
+			Guardian: [common.AddressLength]byte{2}}},
+		{"multisig with guardian", Account{Balance: 5, Address: [common.AddressLength]byte{1}, TransactionDelay: 10,
+			MultiSignNumber: 2, Guardian: [common.AddressLength]byte{2},
+			MultiSignAddresses: [][common.AddressLength]byte{{3}, {4}, {5}}}},
+	}
+	for _, tt := range tests {
+		got := Account{}
+		if err := got.Unmarshal(tt.acc.Marshal()); err != nil {
+			t.Errorf("%s: Expected account decoded, got %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.acc) {
+			t.Errorf("%s: Expected %+v, got %+v", tt.name, tt.acc, got)
+		}
+	}
+}
+
+func TestAccount_MarshalWithoutGuardianUnchanged(t *testing.T) {
+	acc := Account{Balance: 5, Address: [common.AddressLength]byte{1}, TransactionDelay: 10, MultiSignNumber: 1,
+		MultiSignAddresses: [][common.AddressLength]byte{{3}}}
+	if n := len(acc.Marshal()); n != 37+common.AddressLength {
+		t.Errorf("Expected account without guardian encoded as before, got %v bytes", n)
+	}
+	b := Account{Address: [common.AddressLength]byte{1}, Guardian: [common.AddressLength]byte{2}}.Marshal()
+	b[37] = 'X'
+	if err := (&Account{}).Unmarshal(b); err == nil {
+		t.Errorf("Expected guardian without marker rejected")
+	}
+}
+
+func TestAccountsType_MarshalVariableLengthAccounts(t *testing.T) {
+	at := AccountsType{Height: 7, AllAccounts: map[[common.AddressLength]byte]Account{
+		{1}: {Balance: 1, Address: [common.AddressLength]byte{1}},
+		{2}: {Balance: 2, Address: [common.AddressLength]byte{2}, TransactionDelay: 3, Guardian: [common.AddressLength]byte{9}},
+		{3}: {Balance: 3, Address: [common.AddressLength]byte{3}, MultiSignNumber: 1,
+			MultiSignAddresses: [][common.AddressLength]byte{{4}, {5}}},
+	}}
+	got := AccountsType{}
+	if err := got.Unmarshal(at.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, at) {
+		t.Errorf("Expected %+v, got %+v", at, got)
+	}
+
+	// accounts stored before accounts had variable length are decoded too
+	acc := Account{Balance: 1, Address: [common.AddressLength]byte{1}}
+	legacy := append(common.GetByteInt64(1), acc.Address[:]...)
+	legacy = append(legacy, acc.Marshal()...)
+	legacy = append(legacy, common.GetByteInt64(7)...)
+	got = AccountsType{}
+	if err := got.Unmarshal(legacy); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.AllAccounts[acc.Address], acc) || got.Height != 7 {
+		t.Errorf("Expected legacy accounts decoded, got %+v", got)
+	}
+}
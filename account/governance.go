@@ -0,0 +1,196 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+)
+
+const (
+	ProposalVoting    = "voting"
+	ProposalPassed    = "passed"
+	ProposalRejected  = "rejected"
+	ProposalActivated = "activated"
+)
+
+// GovernanceProposal changes governed parameter at ActivationHeight when stake of delegated accounts
+// voting yes at VotingEnd exceeds quorum of parameter
+type GovernanceProposal struct {
+	ID               int64                      `json:"id"`
+	Name             string                     `json:"name"`
+	Value            int64                      `json:"value"`
+	Proposer         [common.AddressLength]byte `json:"proposer"`
+	DelegatedAccount int                        `json:"delegated_account"`
+	Height           int64                      `json:"height"`
+	VotingEnd        int64                      `json:"voting_end"`
+	ActivationHeight int64                      `json:"activation_height"`
+	Votes            map[int]bool               `json:"votes"` // delegated account votes yes or no
+	YesStaked        int64                      `json:"yes_staked,omitempty"`
+	NoStaked         int64                      `json:"no_staked,omitempty"`
+	TotalStaked      int64                      `json:"total_staked,omitempty"`
+	QuorumThirds     int64                      `json:"quorum_thirds,omitempty"`
+	Status           string                     `json:"status"`
+}
+
+type GovernanceType struct {
+	Proposals map[int64]GovernanceProposal `json:"proposals"`
+	Changes   []common.ParamChange         `json:"changes"` // passed changes in order of passing
+	NextID    int64                        `json:"next_id"`
+	Height    int64                        `json:"height"`
+}
+
+var Governance = GovernanceType{Proposals: map[int64]GovernanceProposal{}}
+var GovernanceRWMutex sync.RWMutex
+
+func GetProposal(id int64) (GovernanceProposal, bool) {
+	GovernanceRWMutex.RLock()
+	defer GovernanceRWMutex.RUnlock()
+	p, ok := Governance.Proposals[id]
+	return p, ok
+}
+
+func SetProposal(p GovernanceProposal) {
+	GovernanceRWMutex.Lock()
+	defer GovernanceRWMutex.Unlock()
+	Governance.Proposals[p.ID] = p
+}
+
+// NextProposalID returns id which will get next proposal
+func NextProposalID() int64 {
+	GovernanceRWMutex.RLock()
+	defer GovernanceRWMutex.RUnlock()
+	return Governance.NextID
+}
+
+// AddProposal assigns id to proposal and stores it
+func AddProposal(p GovernanceProposal) GovernanceProposal {
+	GovernanceRWMutex.Lock()
+	defer GovernanceRWMutex.Unlock()
+	p.ID = Governance.NextID
+	Governance.NextID++
+	Governance.Proposals[p.ID] = p
+	return p
+}
+
+// GetAllProposals returns proposals in order of ids
+func GetAllProposals() []GovernanceProposal {
+	GovernanceRWMutex.RLock()
+	defer GovernanceRWMutex.RUnlock()
+	ps := make([]GovernanceProposal, 0, len(Governance.Proposals))
+	for _, p := range Governance.Proposals {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].ID < ps[j].ID
+	})
+	return ps
+}
+
+// GetParamChanges returns passed changes of governed parameters
+func GetParamChanges() []common.ParamChange {
+	GovernanceRWMutex.RLock()
+	defer GovernanceRWMutex.RUnlock()
+	return append([]common.ParamChange{}, Governance.Changes...)
+}
+
+func AddParamChange(c common.ParamChange) {
+	GovernanceRWMutex.Lock()
+	defer GovernanceRWMutex.Unlock()
+	Governance.Changes = append(Governance.Changes, c)
+}
+
+// ApplyGovernedParams sets governed parameters to values of changes active at height
+func ApplyGovernedParams(height int64) error {
+	active := []common.ParamChange{}
+	for _, c := range GetParamChanges() {
+		if c.ActivationHeight <= height {
+			active = append(active, c)
+		}
+	}
+	return common.SetGovernedParams(active)
+}
+
+func ClearGovernance() {
+	GovernanceRWMutex.Lock()
+	defer GovernanceRWMutex.Unlock()
+	Governance = GovernanceType{Proposals: map[int64]GovernanceProposal{}}
+}
+
+func StoreGovernance(height int64) error {
+	if height < 0 {
+		height = common.GetHeight()
+	}
+	GovernanceRWMutex.Lock()
+	defer GovernanceRWMutex.Unlock()
+	Governance.Height = height
+	k, err := json.Marshal(Governance)
+	if err != nil {
+		return err
+	}
+	prefix := append(common.GovernanceDBPrefix[:], common.GetByteInt64(height)...)
+	err = database.MainDB.Put(prefix, k)
+	if err != nil {
+		logger.GetLogger().Println("cannot store governance", err)
+		return err
+	}
+	return nil
+}
+
+func LoadGovernance(height int64) error {
+	var err error
+	GovernanceRWMutex.Lock()
+	defer GovernanceRWMutex.Unlock()
+	if height < 0 {
+		height, err = LastHeightStoredInGovernance()
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+	prefix := append(common.GovernanceDBPrefix[:], common.GetByteInt64(height)...)
+	b, err := database.MainDB.Get(prefix)
+	if err != nil || b == nil {
+		return fmt.Errorf("cannot load governance at height %v: LoadGovernance", height)
+	}
+	g := GovernanceType{}
+	err = json.Unmarshal(b, &g)
+	if err != nil {
+		return err
+	}
+	if g.Proposals == nil {
+		g.Proposals = map[int64]GovernanceProposal{}
+	}
+	g.Height = height
+	Governance = g
+	return nil
+}
+
+func RemoveGovernanceFromDB(height int64) error {
+	prefix := append(common.GovernanceDBPrefix[:], common.GetByteInt64(height)...)
+	err := database.MainDB.Delete(prefix)
+	if err != nil {
+		logger.GetLogger().Println("cannot remove governance", err)
+		return err
+	}
+	return nil
+}
+
+func LastHeightStoredInGovernance() (int64, error) {
+	i := database.FirstStoredHeight()
+	for {
+		prefix := append(common.GovernanceDBPrefix[:], common.GetByteInt64(i)...)
+		isKey, err := database.MainDB.IsKey(prefix)
+		if err != nil {
+			return i - 1, err
+		}
+		if !isKey {
+			break
+		}
+		i++
+	}
+	return i - 1, nil
+}
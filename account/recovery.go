@@ -0,0 +1,226 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+)
+
+// Recovery keeps guardians of account which can rotate its pubkeys when keys are lost or compromised.
+// Rotation approved by Threshold guardians becomes effective after Delay blocks unless cancelled by account.
+// Change of guardians proposed by account needs the same approval and delay.
+type Recovery struct {
+	Address         [common.AddressLength]byte   `json:"address"`
+	Guardians       [][common.AddressLength]byte `json:"guardians"`
+	Threshold       uint8                        `json:"threshold"`
+	Delay           int64                        `json:"delay"`
+	NewPrimary      [common.AddressLength]byte   `json:"new_primary"`
+	NewSecondary    [common.AddressLength]byte   `json:"new_secondary"`
+	NewPrimaryKey   []byte                       `json:"new_primary_key,omitempty"`
+	NewSecondaryKey []byte                       `json:"new_secondary_key,omitempty"`
+	GuardianChange  bool                         `json:"guardian_change,omitempty"`
+	NewGuardians    [][common.AddressLength]byte `json:"new_guardians,omitempty"`
+	NewThreshold    uint8                        `json:"new_threshold,omitempty"`
+	NewDelay        int64                        `json:"new_delay,omitempty"`
+	Approvals       [][common.AddressLength]byte `json:"approvals,omitempty"`
+	EffectiveHeight int64                        `json:"effective_height"`
+}
+
+type RecoveriesType struct {
+	AllRecoveries map[[common.AddressLength]byte]Recovery `json:"all_recoveries"`
+	Height        int64                                   `json:"height"`
+}
+
+var Recoveries = RecoveriesType{AllRecoveries: map[[common.AddressLength]byte]Recovery{}}
+var RecoveriesRWMutex sync.RWMutex
+
+func (r Recovery) IsGuardian(address []byte) bool {
+	for _, g := range r.Guardians {
+		if bytes.Equal(g[:], address) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPendingRotation is true when guardians proposed new pubkeys
+func (r Recovery) HasPendingRotation() bool {
+	return r.NewPrimary != [common.AddressLength]byte{} || r.NewSecondary != [common.AddressLength]byte{}
+}
+
+// HasPendingChange is true when rotation or change of guardians waits for approvals or delay
+func (r Recovery) HasPendingChange() bool {
+	return r.HasPendingRotation() || r.GuardianChange
+}
+
+// ClearRotation removes proposal of new pubkeys or guardians and its approvals
+func (r *Recovery) ClearRotation() {
+	r.NewPrimary = [common.AddressLength]byte{}
+	r.NewSecondary = [common.AddressLength]byte{}
+	r.NewPrimaryKey = nil
+	r.NewSecondaryKey = nil
+	r.GuardianChange = false
+	r.NewGuardians = nil
+	r.NewThreshold = 0
+	r.NewDelay = 0
+	r.Approvals = nil
+	r.EffectiveHeight = 0
+}
+
+// Approve adds approval of guardian to pending change, when Threshold is reached change becomes
+// effective after Delay. Returns true when threshold is reached.
+func (r *Recovery) Approve(guardian []byte, height int64) bool {
+	for _, a := range r.Approvals {
+		if bytes.Equal(a[:], guardian) {
+			return false
+		}
+	}
+	g := [common.AddressLength]byte{}
+	copy(g[:], guardian)
+	r.Approvals = append(r.Approvals, g)
+	if len(r.Approvals) < int(r.Threshold) {
+		return false
+	}
+	r.EffectiveHeight = height + r.Delay
+	return true
+}
+
+func GetRecoveryByAddressBytes(address []byte) Recovery {
+	RecoveriesRWMutex.RLock()
+	defer RecoveriesRWMutex.RUnlock()
+	addrb := [common.AddressLength]byte{}
+	copy(addrb[:], address[:common.AddressLength])
+	return Recoveries.AllRecoveries[addrb]
+}
+
+// SetRecovery stores recovery of account, recovery without guardians is removed
+func SetRecovery(r Recovery) {
+	RecoveriesRWMutex.Lock()
+	defer RecoveriesRWMutex.Unlock()
+	if len(r.Guardians) == 0 {
+		delete(Recoveries.AllRecoveries, r.Address)
+		return
+	}
+	Recoveries.AllRecoveries[r.Address] = r
+}
+
+func ClearRecoveries() {
+	RecoveriesRWMutex.Lock()
+	defer RecoveriesRWMutex.Unlock()
+	Recoveries.AllRecoveries = map[[common.AddressLength]byte]Recovery{}
+}
+
+// GetRecoveriesWithPendingChange returns recoveries with pending rotation or change of guardians in order of addresses
+func GetRecoveriesWithPendingChange() []Recovery {
+	RecoveriesRWMutex.RLock()
+	defer RecoveriesRWMutex.RUnlock()
+	rs := []Recovery{}
+	for _, r := range Recoveries.AllRecoveries {
+		if r.HasPendingChange() {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return bytes.Compare(rs[i].Address[:], rs[j].Address[:]) < 0
+	})
+	return rs
+}
+
+func (rt RecoveriesType) Marshal() ([]byte, error) {
+	rs := make([]Recovery, 0, len(rt.AllRecoveries))
+	for _, r := range rt.AllRecoveries {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return bytes.Compare(rs[i].Address[:], rs[j].Address[:]) < 0
+	})
+	return json.Marshal(rs)
+}
+
+func (rt *RecoveriesType) Unmarshal(data []byte) error {
+	rs := []Recovery{}
+	err := json.Unmarshal(data, &rs)
+	if err != nil {
+		return err
+	}
+	rt.AllRecoveries = make(map[[common.AddressLength]byte]Recovery, len(rs))
+	for _, r := range rs {
+		rt.AllRecoveries[r.Address] = r
+	}
+	return nil
+}
+
+func StoreRecoveries(height int64) error {
+	if height < 0 {
+		height = common.GetHeight()
+	}
+	RecoveriesRWMutex.Lock()
+	defer RecoveriesRWMutex.Unlock()
+	Recoveries.Height = height
+	k, err := Recoveries.Marshal()
+	if err != nil {
+		return err
+	}
+	prefix := append(common.RecoveriesDBPrefix[:], common.GetByteInt64(height)...)
+	err = database.MainDB.Put(prefix, k)
+	if err != nil {
+		logger.GetLogger().Println("cannot store recoveries", err)
+		return err
+	}
+	return nil
+}
+
+func LoadRecoveries(height int64) error {
+	var err error
+	RecoveriesRWMutex.Lock()
+	defer RecoveriesRWMutex.Unlock()
+	if height < 0 {
+		height, err = LastHeightStoredInRecoveries()
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+	prefix := append(common.RecoveriesDBPrefix[:], common.GetByteInt64(height)...)
+	b, err := database.MainDB.Get(prefix)
+	if err != nil || b == nil {
+		return fmt.Errorf("cannot load recoveries at height %v: LoadRecoveries", height)
+	}
+	err = (&Recoveries).Unmarshal(b)
+	if err != nil {
+		return err
+	}
+	Recoveries.Height = height
+	return nil
+}
+
+func RemoveRecoveriesFromDB(height int64) error {
+	prefix := append(common.RecoveriesDBPrefix[:], common.GetByteInt64(height)...)
+	err := database.MainDB.Delete(prefix)
+	if err != nil {
+		logger.GetLogger().Println("cannot remove recoveries", err)
+		return err
+	}
+	return nil
+}
+
+func LastHeightStoredInRecoveries() (int64, error) {
+	i := database.FirstStoredHeight()
+	for {
+		prefix := append(common.RecoveriesDBPrefix[:], common.GetByteInt64(i)...)
+		isKey, err := database.MainDB.IsKey(prefix)
+		if err != nil {
+			return i - 1, err
+		}
+		if !isKey {
+			break
+		}
+		i++
+	}
+	return i - 1, nil
+}
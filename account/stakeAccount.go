@@ -17,6 +17,8 @@ type StakingAccount struct {
 	Address            [common.AddressLength]byte `json:"address"`
 	OperationalAccount bool                       `json:"operational_account"`
 	StakingDetails     map[int64][]StakingDetail  `json:"staking_details,omitempty"` // block number as key of map
+	Vestings           []VestingSchedule          `json:"vestings,omitempty"`
+	Unbonding          []UnbondingEntry           `json:"unbonding,omitempty"`
 }
 
 type StakingDetail struct {
@@ -44,7 +46,8 @@ func GetLockedAmount(accb []byte, height int64, delegatedAccount int) (int64, er
 		}
 		locked += lock
 	}
-	return locked, nil
+	unvested, _ := unvestedAmount(acc, height)
+	return locked + unvested, nil
 }
 
 func Stake(accb []byte, amount int64, height int64, delegatedAccount int, operational bool, lockedAmount int64, releasePerBlock int64) error {
@@ -128,9 +131,15 @@ func Unstake(accb []byte, amount int64, height int64, delegatedAccount int) erro
 		}
 		locked += lock
 	}
+	unvested, vestedInd := unvestedAmount(acc, height)
+	locked += unvested
 	if acc.StakedBalance-locked+amount < 0 {
 		return fmt.Errorf("insufficient staked balance after locking")
 	}
+	for i := len(vestedInd) - 1; i >= 0; i-- {
+		ind := vestedInd[i]
+		acc.Vestings = append(acc.Vestings[:ind], acc.Vestings[ind+1:]...)
+	}
 	for _, ind := range toRemoveLockedInd {
 		acc.LockedAmount = append(acc.LockedAmount[:ind], acc.LockedAmount[ind+1:]...)
 		acc.ReleasePerBlock = append(acc.ReleasePerBlock[:ind], acc.ReleasePerBlock[ind+1:]...)
@@ -250,6 +259,25 @@ func (sa StakingAccount) Marshal() []byte {
 			buffer.Write(common.GetByteInt64(detail.LastUpdated))
 		}
 	}
+	// vesting schedules and unbonding entries are appended only when present so older accounts keep format
+	if len(sa.Vestings) > 0 || len(sa.Unbonding) > 0 {
+		buffer.Write(common.GetByteInt64(int64(len(sa.Vestings))))
+		for _, v := range sa.Vestings {
+			buffer.Write(common.GetByteInt64(v.Amount))
+			buffer.Write(common.GetByteInt64(v.StartBlock))
+			buffer.Write(common.GetByteInt64(v.CliffBlock))
+			buffer.Write(common.GetByteInt64(v.StepBlocks))
+			buffer.Write(common.GetByteInt64(v.EndBlock))
+			buffer.Write(v.Grantor[:])
+		}
+	}
+	if len(sa.Unbonding) > 0 {
+		buffer.Write(common.GetByteInt64(int64(len(sa.Unbonding))))
+		for _, u := range sa.Unbonding {
+			buffer.Write(common.GetByteInt64(u.Amount))
+			buffer.Write(common.GetByteInt64(u.ReleaseBlock))
+		}
+	}
 
 	return buffer.Bytes()
 }
@@ -313,6 +341,37 @@ func (sa *StakingAccount) Unmarshal(data []byte) error {
 
 		sa.StakingDetails[key] = details
 	}
+	sa.Vestings = nil
+	if buffer.Len() >= 8 {
+		vestingsCount := common.GetInt64FromByte(buffer.Next(8))
+		for i := int64(0); i < vestingsCount; i++ {
+			if buffer.Len() < 5*8+common.AddressLength {
+				return fmt.Errorf("insufficient data for vesting schedule %d", i)
+			}
+			v := VestingSchedule{
+				Amount:     common.GetInt64FromByte(buffer.Next(8)),
+				StartBlock: common.GetInt64FromByte(buffer.Next(8)),
+				CliffBlock: common.GetInt64FromByte(buffer.Next(8)),
+				StepBlocks: common.GetInt64FromByte(buffer.Next(8)),
+				EndBlock:   common.GetInt64FromByte(buffer.Next(8)),
+			}
+			copy(v.Grantor[:], buffer.Next(common.AddressLength))
+			sa.Vestings = append(sa.Vestings, v)
+		}
+	}
+	sa.Unbonding = nil
+	if buffer.Len() >= 8 {
+		unbondingCount := common.GetInt64FromByte(buffer.Next(8))
+		for i := int64(0); i < unbondingCount; i++ {
+			if buffer.Len() < 16 {
+				return fmt.Errorf("insufficient data for unbonding entry %d", i)
+			}
+			sa.Unbonding = append(sa.Unbonding, UnbondingEntry{
+				Amount:       common.GetInt64FromByte(buffer.Next(8)),
+				ReleaseBlock: common.GetInt64FromByte(buffer.Next(8)),
+			})
+		}
+	}
 	return nil
 }
 
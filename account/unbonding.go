@@ -0,0 +1,131 @@
+package account
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+// UnbondingEntry is unstaked amount which waits for ReleaseBlock. Until then it can be slashed.
+type UnbondingEntry struct {
+	Amount       int64 `json:"amount"`
+	ReleaseBlock int64 `json:"release_block"`
+}
+
+// AddUnbonding queues amount unstaked from delegated account to be released at releaseBlock
+func AddUnbonding(accb []byte, amount int64, releaseBlock int64, delegatedAccount int) error {
+	acc := GetStakingAccountByAddressBytes(accb, delegatedAccount)
+	if !bytes.Equal(acc.Address[:], accb) {
+		return fmt.Errorf("no staking account for unbonding: AddUnbonding")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("unbonding amount has to be positive: AddUnbonding")
+	}
+	StakingRWMutex.Lock()
+	defer StakingRWMutex.Unlock()
+	acc.Unbonding = append(acc.Unbonding, UnbondingEntry{Amount: amount, ReleaseBlock: releaseBlock})
+	StakingAccounts[delegatedAccount].AllStakingAccounts[acc.Address] = acc
+	return nil
+}
+
+// ReleaseUnbonding removes matured unbonding entries and returns amounts to be credited per address
+func ReleaseUnbonding(height int64) map[[common.AddressLength]byte]int64 {
+	StakingRWMutex.Lock()
+	defer StakingRWMutex.Unlock()
+	released := map[[common.AddressLength]byte]int64{}
+	for n := 1; n < 256; n++ {
+		for address, acc := range StakingAccounts[n].AllStakingAccounts {
+			if len(acc.Unbonding) == 0 {
+				continue
+			}
+			left := []UnbondingEntry{}
+			for _, u := range acc.Unbonding {
+				if u.ReleaseBlock <= height {
+					released[address] += u.Amount
+				} else {
+					left = append(left, u)
+				}
+			}
+			if len(left) == len(acc.Unbonding) {
+				continue
+			}
+			if len(left) == 0 {
+				left = nil
+			}
+			acc.Unbonding = left
+			StakingAccounts[n].AllStakingAccounts[address] = acc
+		}
+	}
+	return released
+}
+
+// GetUnbondingAmount sums amounts of address waiting for release in all delegated accounts
+func GetUnbondingAmount(accb []byte) int64 {
+	sum := int64(0)
+	for n := 1; n < 256; n++ {
+		for _, u := range GetStakingAccountByAddressBytes(accb, n).Unbonding {
+			sum += u.Amount
+		}
+	}
+	return sum
+}
+
+// Slash takes perMille part of stake, locks, vestings and unbonding amounts of all stakers of
+// delegated account and returns total slashed amount
+func Slash(delegatedAccount int, perMille int64, height int64) int64 {
+	StakingRWMutex.Lock()
+	defer StakingRWMutex.Unlock()
+	addresses := make([][common.AddressLength]byte, 0, len(StakingAccounts[delegatedAccount].AllStakingAccounts))
+	for address := range StakingAccounts[delegatedAccount].AllStakingAccounts {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+	total := int64(0)
+	for _, address := range addresses {
+		acc := StakingAccounts[delegatedAccount].AllStakingAccounts[address]
+		slashed := slashedPart(acc.StakedBalance, perMille)
+		acc.StakedBalance -= slashed
+		for i := range acc.LockedAmount {
+			acc.LockedAmount[i] -= slashedPart(acc.LockedAmount[i], perMille)
+		}
+		for i := range acc.Vestings {
+			acc.Vestings[i].Amount -= slashedPart(acc.Vestings[i].Amount, perMille)
+		}
+		for i := range acc.Unbonding {
+			s := slashedPart(acc.Unbonding[i].Amount, perMille)
+			acc.Unbonding[i].Amount -= s
+			slashed += s
+		}
+		if slashed == 0 {
+			continue
+		}
+		total += slashed
+		if acc.StakedBalance == 0 {
+			acc.OperationalAccount = false
+		}
+		if _, ok := acc.StakingDetails[height]; !ok {
+			acc.StakingDetails = map[int64][]StakingDetail{}
+			acc.StakingDetails[height] = []StakingDetail{}
+		}
+		acc.StakingDetails[height] = append(acc.StakingDetails[height], StakingDetail{
+			Amount:      -slashed,
+			LastUpdated: time.Now().Unix(),
+		})
+		StakingAccounts[delegatedAccount].AllStakingAccounts[address] = acc
+	}
+	return total
+}
+
+func slashedPart(amount int64, perMille int64) int64 {
+	if amount <= 0 {
+		return 0
+	}
+	s := new(big.Int).Mul(big.NewInt(amount), big.NewInt(perMille))
+	return s.Quo(s, big.NewInt(1000)).Int64()
+}
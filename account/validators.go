@@ -0,0 +1,201 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+)
+
+// CommissionChange records commission of operator set at height
+type CommissionChange struct {
+	Height     int64 `json:"height"`
+	Commission int16 `json:"commission"`
+}
+
+// Validator describes delegated account for stakers. Metadata and commission are set by operator,
+// uptime is counted from nonce participation recorded in blocks.
+type Validator struct {
+	DelegatedAccount   int                        `json:"delegated_account"`
+	Operator           [common.AddressLength]byte `json:"operator"`
+	Name               string                     `json:"name"`
+	URL                string                     `json:"url"`
+	Registered         bool                       `json:"registered"`
+	Commission         int16                      `json:"commission"` // per mille of block reward for operator
+	CommissionChanges  []CommissionChange         `json:"commission_changes,omitempty"`
+	BlocksProduced     int64                      `json:"blocks_produced"`
+	RoundsExpected     int64                      `json:"rounds_expected"`
+	RoundsParticipated int64                      `json:"rounds_participated"`
+	MissedInRow        int64                      `json:"missed_in_row"`
+	MissedReveals      int64                      `json:"missed_reveals"` // commits of rand oracle not opened within common.RandRevealWindow blocks
+	Jailed             bool                       `json:"jailed"`
+	JailedUntil        int64                      `json:"jailed_until,omitempty"`
+}
+
+type ValidatorsType struct {
+	AllValidators map[int]Validator `json:"all_validators"`
+	Height        int64             `json:"height"`
+}
+
+var Validators = ValidatorsType{AllValidators: map[int]Validator{}}
+var ValidatorsRWMutex sync.RWMutex
+
+// Uptime is part of rounds in which validator sent nonce
+func (v Validator) Uptime() float64 {
+	if v.RoundsExpected == 0 {
+		return 0
+	}
+	return float64(v.RoundsParticipated) / float64(v.RoundsExpected)
+}
+
+// LastCommissionChange returns height of last change of commission, 0 when never changed
+func (v Validator) LastCommissionChange() int64 {
+	if len(v.CommissionChanges) == 0 {
+		return 0
+	}
+	return v.CommissionChanges[len(v.CommissionChanges)-1].Height
+}
+
+func GetValidator(delegatedAccount int) (Validator, bool) {
+	ValidatorsRWMutex.RLock()
+	defer ValidatorsRWMutex.RUnlock()
+	v, ok := Validators.AllValidators[delegatedAccount]
+	return v, ok
+}
+
+func SetValidator(v Validator) {
+	ValidatorsRWMutex.Lock()
+	defer ValidatorsRWMutex.Unlock()
+	Validators.AllValidators[v.DelegatedAccount] = v
+}
+
+func IsValidatorJailed(delegatedAccount int) bool {
+	v, ok := GetValidator(delegatedAccount)
+	return ok && v.Jailed
+}
+
+// GetValidatorCommission returns registered commission or defaultCommission for unregistered validator
+func GetValidatorCommission(delegatedAccount int, defaultCommission int16) int16 {
+	v, ok := GetValidator(delegatedAccount)
+	if !ok || !v.Registered {
+		return defaultCommission
+	}
+	return v.Commission
+}
+
+// GetAllValidators returns validators in order of delegated accounts
+func GetAllValidators() []Validator {
+	ValidatorsRWMutex.RLock()
+	defer ValidatorsRWMutex.RUnlock()
+	vs := make([]Validator, 0, len(Validators.AllValidators))
+	for _, v := range Validators.AllValidators {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[i].DelegatedAccount < vs[j].DelegatedAccount
+	})
+	return vs
+}
+
+func ClearValidators() {
+	ValidatorsRWMutex.Lock()
+	defer ValidatorsRWMutex.Unlock()
+	Validators.AllValidators = map[int]Validator{}
+}
+
+func (vt ValidatorsType) Marshal() ([]byte, error) {
+	vs := make([]Validator, 0, len(vt.AllValidators))
+	for _, v := range vt.AllValidators {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[i].DelegatedAccount < vs[j].DelegatedAccount
+	})
+	return json.Marshal(vs)
+}
+
+func (vt *ValidatorsType) Unmarshal(data []byte) error {
+	vs := []Validator{}
+	err := json.Unmarshal(data, &vs)
+	if err != nil {
+		return err
+	}
+	vt.AllValidators = make(map[int]Validator, len(vs))
+	for _, v := range vs {
+		vt.AllValidators[v.DelegatedAccount] = v
+	}
+	return nil
+}
+
+func StoreValidators(height int64) error {
+	if height < 0 {
+		height = common.GetHeight()
+	}
+	ValidatorsRWMutex.Lock()
+	defer ValidatorsRWMutex.Unlock()
+	Validators.Height = height
+	k, err := Validators.Marshal()
+	if err != nil {
+		return err
+	}
+	prefix := append(common.ValidatorsDBPrefix[:], common.GetByteInt64(height)...)
+	err = database.MainDB.Put(prefix, k)
+	if err != nil {
+		logger.GetLogger().Println("cannot store validators", err)
+		return err
+	}
+	return nil
+}
+
+func LoadValidators(height int64) error {
+	var err error
+	ValidatorsRWMutex.Lock()
+	defer ValidatorsRWMutex.Unlock()
+	if height < 0 {
+		height, err = LastHeightStoredInValidators()
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+	prefix := append(common.ValidatorsDBPrefix[:], common.GetByteInt64(height)...)
+	b, err := database.MainDB.Get(prefix)
+	if err != nil || b == nil {
+		return fmt.Errorf("cannot load validators at height %v: LoadValidators", height)
+	}
+	err = (&Validators).Unmarshal(b)
+	if err != nil {
+		return err
+	}
+	Validators.Height = height
+	return nil
+}
+
+func RemoveValidatorsFromDB(height int64) error {
+	prefix := append(common.ValidatorsDBPrefix[:], common.GetByteInt64(height)...)
+	err := database.MainDB.Delete(prefix)
+	if err != nil {
+		logger.GetLogger().Println("cannot remove validators", err)
+		return err
+	}
+	return nil
+}
+
+func LastHeightStoredInValidators() (int64, error) {
+	i := database.FirstStoredHeight()
+	for {
+		prefix := append(common.ValidatorsDBPrefix[:], common.GetByteInt64(i)...)
+		isKey, err := database.MainDB.IsKey(prefix)
+		if err != nil {
+			return i - 1, err
+		}
+		if !isKey {
+			break
+		}
+		i++
+	}
+	return i - 1, nil
+}
@@ -0,0 +1,222 @@
+package account
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+// Vesting schedule is set in OptData of locking transaction:
+// 'V' cliff(8) step(8) duration(8) revocable(1), heights relative to block of transaction.
+// Grant is revoked with staking transaction of amount 0 sent by grantor with OptData 'X' grantee(20).
+const (
+	VestingMarker           = byte('V')
+	VestingRevocationMarker = byte('X')
+	vestingOptDataLength    = 1 + 3*8 + 1
+)
+
+// VestingSchedule locks Amount staked at StartBlock. Nothing is released before CliffBlock,
+// then Amount vests every StepBlocks proportionally until EndBlock. Grantor of revocable
+// grant can take back unvested amount.
+type VestingSchedule struct {
+	Amount     int64                      `json:"amount"`
+	StartBlock int64                      `json:"start_block"`
+	CliffBlock int64                      `json:"cliff_block"`
+	StepBlocks int64                      `json:"step_blocks"`
+	EndBlock   int64                      `json:"end_block"`
+	Grantor    [common.AddressLength]byte `json:"grantor,omitempty"`
+}
+
+type VestingStatus struct {
+	DelegatedAccount  int             `json:"delegated_account"`
+	Schedule          VestingSchedule `json:"schedule"`
+	Vested            int64           `json:"vested"`
+	Unvested          int64           `json:"unvested"`
+	NextReleaseHeight int64           `json:"next_release_height,omitempty"`
+	NextReleaseAmount int64           `json:"next_release_amount,omitempty"`
+}
+
+func (v VestingSchedule) IsRevocable() bool {
+	return v.Grantor != [common.AddressLength]byte{}
+}
+
+func (v VestingSchedule) Vested(height int64) int64 {
+	if height < v.CliffBlock {
+		return 0
+	}
+	if height >= v.EndBlock {
+		return v.Amount
+	}
+	elapsed := (height - v.StartBlock) / v.StepBlocks * v.StepBlocks
+	vested := new(big.Int).Mul(big.NewInt(v.Amount), big.NewInt(elapsed))
+	vested.Quo(vested, big.NewInt(v.EndBlock-v.StartBlock))
+	return vested.Int64()
+}
+
+func (v VestingSchedule) Unvested(height int64) int64 {
+	return v.Amount - v.Vested(height)
+}
+
+// NextRelease returns height and amount of next increase of vested amount after height
+func (v VestingSchedule) NextRelease(height int64) (int64, int64) {
+	vested := v.Vested(height)
+	h := height
+	for h < v.EndBlock {
+		h = v.StartBlock + ((h-v.StartBlock)/v.StepBlocks+1)*v.StepBlocks
+		if h < v.CliffBlock {
+			h = v.CliffBlock
+		}
+		if h > v.EndBlock {
+			h = v.EndBlock
+		}
+		if amount := v.Vested(h) - vested; amount > 0 {
+			return h, amount
+		}
+	}
+	return 0, 0
+}
+
+// ParseVestingOptData creates schedule from OptData of locking transaction
+func ParseVestingOptData(opt []byte, amount int64, height int64, grantor [common.AddressLength]byte) (VestingSchedule, error) {
+	if len(opt) != vestingOptDataLength || opt[0] != VestingMarker {
+		return VestingSchedule{}, fmt.Errorf("wrong vesting data: ParseVestingOptData")
+	}
+	cliff := common.GetInt64FromByte(opt[1:9])
+	step := common.GetInt64FromByte(opt[9:17])
+	duration := common.GetInt64FromByte(opt[17:25])
+	if duration <= 0 || step <= 0 || cliff < 0 || cliff > duration || step > duration {
+		return VestingSchedule{}, fmt.Errorf("vesting needs 0 <= cliff <= duration and 0 < step <= duration: ParseVestingOptData")
+	}
+	v := VestingSchedule{
+		Amount:     amount,
+		StartBlock: height,
+		CliffBlock: height + cliff,
+		StepBlocks: step,
+		EndBlock:   height + duration,
+	}
+	if opt[25] > 0 {
+		v.Grantor = grantor
+	}
+	return v, nil
+}
+
+// VestingOptData is inverse of ParseVestingOptData
+func VestingOptData(cliff, step, duration int64, revocable bool) []byte {
+	b := []byte{VestingMarker}
+	b = append(b, common.GetByteInt64(cliff)...)
+	b = append(b, common.GetByteInt64(step)...)
+	b = append(b, common.GetByteInt64(duration)...)
+	return append(b, common.BoolToByte(revocable))
+}
+
+// ParseVestingRevocation returns grantee from OptData of revocation transaction
+func ParseVestingRevocation(opt []byte) ([common.AddressLength]byte, bool) {
+	grantee := [common.AddressLength]byte{}
+	if len(opt) != 1+common.AddressLength || opt[0] != VestingRevocationMarker {
+		return grantee, false
+	}
+	copy(grantee[:], opt[1:])
+	return grantee, true
+}
+
+// AddVesting locks part of just staked amount with vesting schedule
+func AddVesting(accb []byte, delegatedAccount int, v VestingSchedule) error {
+	acc := GetStakingAccountByAddressBytes(accb, delegatedAccount)
+	if !bytes.Equal(acc.Address[:], accb) {
+		return fmt.Errorf("no staking account for vesting: AddVesting")
+	}
+	StakingRWMutex.Lock()
+	defer StakingRWMutex.Unlock()
+	if v.Amount <= 0 || v.Amount > acc.StakedBalance {
+		return fmt.Errorf("vested amount has to be positive and not larger than staked balance: AddVesting")
+	}
+	acc.Vestings = append(acc.Vestings, v)
+	StakingAccounts[delegatedAccount].AllStakingAccounts[acc.Address] = acc
+	return nil
+}
+
+// RevokeVesting ends revocable grants of grantor at height and returns unvested amount
+// which is removed from staked balance of grantee. Amount is limited to staked balance,
+// which can be lower than unvested amount after slashing.
+func RevokeVesting(accb []byte, grantor []byte, height int64, delegatedAccount int) (int64, error) {
+	acc := GetStakingAccountByAddressBytes(accb, delegatedAccount)
+	if !bytes.Equal(acc.Address[:], accb) {
+		return 0, fmt.Errorf("no staking account with vesting: RevokeVesting")
+	}
+	StakingRWMutex.Lock()
+	defer StakingRWMutex.Unlock()
+	unvested := int64(0)
+	for i, v := range acc.Vestings {
+		if !v.IsRevocable() || !bytes.Equal(v.Grantor[:], grantor) {
+			continue
+		}
+		u := v.Unvested(height)
+		if u <= 0 {
+			continue
+		}
+		unvested += u
+		v.Amount -= u
+		v.EndBlock = height
+		if v.CliffBlock > height {
+			v.CliffBlock = height
+		}
+		acc.Vestings[i] = v
+	}
+	if unvested == 0 {
+		return 0, fmt.Errorf("no revocable unvested amount: RevokeVesting")
+	}
+	if unvested > acc.StakedBalance {
+		unvested = acc.StakedBalance
+	}
+	acc.StakedBalance -= unvested
+	if acc.StakedBalance == 0 {
+		acc.OperationalAccount = false
+	}
+	if _, ok := acc.StakingDetails[height]; !ok {
+		acc.StakingDetails = map[int64][]StakingDetail{}
+		acc.StakingDetails[height] = []StakingDetail{}
+	}
+	acc.StakingDetails[height] = append(acc.StakingDetails[height], StakingDetail{
+		Amount:      -unvested,
+		LastUpdated: time.Now().Unix(),
+	})
+	StakingAccounts[delegatedAccount].AllStakingAccounts[acc.Address] = acc
+	return unvested, nil
+}
+
+// unvestedAmount sums amounts not vested yet and returns indices of fully vested schedules
+func unvestedAmount(acc StakingAccount, height int64) (int64, []int) {
+	unvested := int64(0)
+	vestedInd := []int{}
+	for ind, v := range acc.Vestings {
+		u := v.Unvested(height)
+		if u <= 0 {
+			vestedInd = append(vestedInd, ind)
+		}
+		unvested += u
+	}
+	return unvested, vestedInd
+}
+
+// GetVestingStatus returns vesting schedules of address in all delegated accounts
+func GetVestingStatus(accb []byte, height int64) []VestingStatus {
+	statuses := []VestingStatus{}
+	for n := 1; n < 256; n++ {
+		acc := GetStakingAccountByAddressBytes(accb, n)
+		for _, v := range acc.Vestings {
+			nh, na := v.NextRelease(height)
+			statuses = append(statuses, VestingStatus{
+				DelegatedAccount:  n,
+				Schedule:          v,
+				Vested:            v.Vested(height),
+				Unvested:          v.Unvested(height),
+				NextReleaseHeight: nh,
+				NextReleaseAmount: na,
+			})
+		}
+	}
+	return statuses
+}
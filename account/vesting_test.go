@@ -0,0 +1,131 @@
+package account
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+func testSchedule() VestingSchedule {
+	return VestingSchedule{
+		Amount:     1000,
+		StartBlock: 100,
+		CliffBlock: 120,
+		StepBlocks: 10,
+		EndBlock:   200,
+	}
+}
+
+func TestVestingSchedule_Vested(t *testing.T) {
+	v := testSchedule()
+	tests := []struct {
+		height int64
+		vested int64
+	}{
+		{100, 0},
+		{119, 0},
+		{120, 200},
+		{125, 200},
+		{130, 300},
+		{199, 900},
+		{200, 1000},
+		{250, 1000},
+	}
+	for _, tt := range tests {
+		if got := v.Vested(tt.height); got != tt.vested {
+			t.Errorf("Expected %v vested at height %v, got %v", tt.vested, tt.height, got)
+		}
+		if got := v.Unvested(tt.height); got != v.Amount-tt.vested {
+			t.Errorf("Expected %v unvested at height %v, got %v", v.Amount-tt.vested, tt.height, got)
+		}
+	}
+}
+
+func TestVestingSchedule_NextRelease(t *testing.T) {
+	v := testSchedule()
+	tests := []struct {
+		height         int64
+		releaseHeight  int64
+		releasedAmount int64
+	}{
+		{100, 120, 200},
+		{120, 130, 100},
+		{125, 130, 100},
+		{190, 200, 100},
+		{200, 0, 0},
+	}
+	for _, tt := range tests {
+		h, a := v.NextRelease(tt.height)
+		if h != tt.releaseHeight || a != tt.releasedAmount {
+			t.Errorf("Expected release %v at height %v after %v, got %v at %v", tt.releasedAmount, tt.releaseHeight, tt.height, a, h)
+		}
+	}
+}
+
+func TestParseVestingOptData(t *testing.T) {
+	grantor := [common.AddressLength]byte{7}
+	v, err := ParseVestingOptData(VestingOptData(20, 10, 100, true), 1000, 100, grantor)
+	if err != nil {
+		t.Fatalf("Expected valid vesting data, got %v", err)
+	}
+	want := testSchedule()
+	want.Grantor = grantor
+	if v != want {
+		t.Errorf("Expected %+v, got %+v", want, v)
+	}
+	for _, opt := range [][]byte{
+		VestingOptData(20, 10, 0, false),
+		VestingOptData(20, 0, 100, false),
+		VestingOptData(120, 10, 100, false),
+		VestingOptData(20, 110, 100, false),
+		VestingOptData(20, 10, 100, false)[:10],
+	} {
+		if _, err := ParseVestingOptData(opt, 1000, 100, grantor); err == nil {
+			t.Errorf("Expected error for vesting data %x", opt)
+		}
+	}
+}
+
+func TestRevokeVesting(t *testing.T) {
+	grantee := [common.AddressLength]byte{1}
+	grantor := [common.AddressLength]byte{2}
+	other := [common.AddressLength]byte{3}
+	tests := []struct {
+		name    string
+		staked  int64
+		grantor [common.AddressLength]byte
+		revoker [common.AddressLength]byte
+		height  int64
+		revoked int64
+		wantErr bool
+	}{
+		{"unvested amount returned", 1500, grantor, grantor, 150, 500, false},
+		{"limited to slashed staked balance", 300, grantor, grantor, 150, 300, false},
+		{"other grantor", 1500, grantor, other, 150, 0, true},
+		{"not revocable", 1500, [common.AddressLength]byte{}, grantor, 150, 0, true},
+		{"fully vested", 1500, grantor, grantor, 200, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := testSchedule()
+			v.Grantor = tt.grantor
+			StakingAccounts[1].AllStakingAccounts = map[[common.AddressLength]byte]StakingAccount{
+				grantee: {Address: grantee, StakedBalance: tt.staked, Vestings: []VestingSchedule{v}},
+			}
+			revoked, err := RevokeVesting(grantee[:], tt.revoker[:], tt.height, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if revoked != tt.revoked {
+				t.Errorf("Expected %v revoked, got %v", tt.revoked, revoked)
+			}
+			acc := StakingAccounts[1].AllStakingAccounts[grantee]
+			if acc.StakedBalance != tt.staked-tt.revoked {
+				t.Errorf("Expected staked balance %v, got %v", tt.staked-tt.revoked, acc.StakedBalance)
+			}
+			if !tt.wantErr && acc.Vestings[0].Unvested(tt.height) != 0 {
+				t.Errorf("Expected nothing unvested after revocation, got %v", acc.Vestings[0].Unvested(tt.height))
+			}
+		})
+	}
+}
@@ -127,7 +127,10 @@ func (bl Block) StoreBlock() error {
 	if err != nil {
 		return err
 	}
-
+	err = StoreCumulativeDifficulty(bl)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -145,11 +148,15 @@ func RemoveBlockFromDB(height int64) error {
 	if err != nil {
 		return err
 	}
+	err = RemoveCumulativeDifficultyFromDB(height)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 func LastHeightStoredInBlocks() (int64, error) {
-	i := int64(0)
+	i := database.FirstStoredHeight()
 	for {
 		ib := common.GetByteInt64(i)
 		prefix := append(common.BlockByHeightDBPrefix[:], ib...)
@@ -0,0 +1,178 @@
+package blocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+// Escrow or multi signature account changes its configuration with transaction sent to itself
+// with new MultiSignNumber and MultiSignAddresses and/or EscrowTransactionsDelay. Such transaction
+// waits in escrow pool for current delay and in multi signature pool for approvals of current quorum.
+
+type AccountConfig struct {
+	Hash               common.Hash      `json:"hash"`
+	Height             int64            `json:"height"`
+	TransactionDelay   int64            `json:"transaction_delay"`
+	Guardian           common.Address   `json:"guardian"`
+	MultiSignNumber    uint8            `json:"multi_sign_number"`
+	MultiSignAddresses []common.Address `json:"multi_sign_addresses"`
+}
+
+// IsAccountReconfiguration checks if transaction changes configuration of escrow or multi signature account
+func IsAccountReconfiguration(tx transactionsDefinition.Transaction) bool {
+	if tx.TxData.EscrowTransactionsDelay == 0 && tx.TxData.MultiSignNumber == 0 {
+		return false
+	}
+	if !bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+		return false
+	}
+	if !bytes.Equal(tx.TxData.Recipient.GetBytes(), tx.TxParam.Sender.GetBytes()) {
+		return false
+	}
+	return !account.CanBeModifiedAccount(tx.TxParam.Sender.GetBytes())
+}
+
+// CheckAccountConfigTransaction checks transaction which sets escrow or multi signature parameters.
+// Plain account sets parameters of recipient which is not escrow or multi signature yet, escrow or
+// multi signature account changes only its own configuration. Configured is set of accounts
+// configured by previous transactions of block.
+func CheckAccountConfigTransaction(tx transactionsDefinition.Transaction, configured map[[common.AddressLength]byte]bool) error {
+	td := tx.TxData
+	if td.EscrowTransactionsDelay == 0 && td.MultiSignNumber == 0 {
+		if td.EscrowGuardian != [common.AddressLength]byte{} {
+			return fmt.Errorf("guardian can be set only with escrow delay: CheckAccountConfigTransaction")
+		}
+		return nil
+	}
+	if !bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+		return fmt.Errorf("vote on multi signature proposal cannot change configuration: CheckAccountConfigTransaction")
+	}
+	target := td.Recipient.ByteValue
+	if account.CanBeModifiedAccount(tx.TxParam.Sender.GetBytes()) {
+		if !account.CanBeModifiedAccount(target[:]) {
+			return fmt.Errorf("recipient is escrow or multi signature account already: CheckAccountConfigTransaction")
+		}
+	} else if !bytes.Equal(target[:], tx.TxParam.Sender.GetBytes()) {
+		return fmt.Errorf("escrow or multi signature account can change only its own configuration: CheckAccountConfigTransaction")
+	}
+	if configured[target] {
+		return fmt.Errorf("configuration of account changed twice in block: CheckAccountConfigTransaction")
+	}
+	configured[target] = true
+	if td.EscrowTransactionsDelay > common.MaxTransactionDelay || td.EscrowTransactionsDelay < 0 {
+		return fmt.Errorf("wrong escrow delay: CheckAccountConfigTransaction")
+	}
+	if td.EscrowTransactionsDelay == 0 && td.EscrowGuardian != [common.AddressLength]byte{} {
+		return fmt.Errorf("guardian can be set only with escrow delay: CheckAccountConfigTransaction")
+	}
+	if td.EscrowGuardian == target {
+		return fmt.Errorf("escrow account cannot be its own guardian: CheckAccountConfigTransaction")
+	}
+	if int(td.MultiSignNumber) > len(td.MultiSignAddresses) {
+		return fmt.Errorf("not enough co-signers for multi signature: CheckAccountConfigTransaction")
+	}
+	return nil
+}
+
+// ProcessAccountReconfiguration applies approved or matured configuration change
+func ProcessAccountReconfiguration(tx transactionsDefinition.Transaction, height int64) error {
+	acc := account.GetAccountByAddressBytes(tx.TxParam.Sender.GetBytes())
+	if tx.TxData.EscrowTransactionsDelay > 0 {
+		err := acc.ReconfigureEscrow(tx.TxData.EscrowTransactionsDelay, escrowGuardianFromTx(tx))
+		if err != nil {
+			return err
+		}
+	}
+	if tx.TxData.MultiSignNumber > 0 {
+		addresses := make([]common.Address, len(tx.TxData.MultiSignAddresses))
+		for i, a := range tx.TxData.MultiSignAddresses {
+			addresses[i] = common.Address{ByteValue: a}
+		}
+		err := acc.ReconfigureMultiSign(tx.TxData.MultiSignNumber, addresses)
+		if err != nil {
+			return err
+		}
+	}
+	logger.GetLogger().Println("configuration of account", tx.TxParam.Sender.GetHex(), "changed at height", height)
+	return storeAccountConfig(acc, tx.Hash, height)
+}
+
+// storeAccountConfig appends new version of account configuration to its history
+func storeAccountConfig(acc account.Account, hash common.Hash, height int64) error {
+	history := GetAccountConfigHistory(acc.Address[:])
+	c := AccountConfig{
+		Hash:             hash,
+		Height:           height,
+		TransactionDelay: acc.TransactionDelay,
+		Guardian:         common.Address{ByteValue: acc.Guardian},
+		MultiSignNumber:  acc.MultiSignNumber,
+	}
+	for _, a := range acc.MultiSignAddresses {
+		c.MultiSignAddresses = append(c.MultiSignAddresses, common.Address{ByteValue: a})
+	}
+	b, err := json.Marshal(append(history, c))
+	if err != nil {
+		return err
+	}
+	err = database.MainDB.Put(append(common.AccountConfigDBPrefix[:], acc.Address[:]...), b)
+	if err != nil {
+		return err
+	}
+	key := append(common.AccountConfigsByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	addresses, err := database.MainDB.Get(key)
+	if err != nil {
+		addresses = []byte{}
+	}
+	return database.MainDB.Put(key, append(addresses, acc.Address[:]...))
+}
+
+// GetAccountConfigHistory returns all versions of configuration of escrow or multi signature account
+func GetAccountConfigHistory(address []byte) []AccountConfig {
+	history := []AccountConfig{}
+	b, err := database.MainDB.Get(append(common.AccountConfigDBPrefix[:], address...))
+	if err != nil || len(b) == 0 {
+		return history
+	}
+	err = json.Unmarshal(b, &history)
+	if err != nil {
+		logger.GetLogger().Println(err)
+		return []AccountConfig{}
+	}
+	return history
+}
+
+// RemoveAccountConfigsFromDB removes configuration versions set at height, used when chain is reset
+func RemoveAccountConfigsFromDB(height int64) error {
+	key := append(common.AccountConfigsByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	addresses, err := database.MainDB.Get(key)
+	if err != nil || len(addresses) == 0 {
+		return nil
+	}
+	for i := 0; i+common.AddressLength <= len(addresses); i += common.AddressLength {
+		address := addresses[i : i+common.AddressLength]
+		history := GetAccountConfigHistory(address)
+		for len(history) > 0 && history[len(history)-1].Height >= height {
+			history = history[:len(history)-1]
+		}
+		if len(history) == 0 {
+			err = database.MainDB.Delete(append(common.AccountConfigDBPrefix[:], address...))
+		} else {
+			var b []byte
+			b, err = json.Marshal(history)
+			if err == nil {
+				err = database.MainDB.Put(append(common.AccountConfigDBPrefix[:], address...), b)
+			}
+		}
+		if err != nil {
+			logger.GetLogger().Println("cannot revert account configuration", err)
+		}
+	}
+	return database.MainDB.Delete(key)
+}
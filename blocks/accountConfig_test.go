@@ -0,0 +1,112 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+func configTestTx(sender, recipient [common.AddressLength]byte, delay int64, approvals uint8, cosigners ...[common.AddressLength]byte) transactionsDefinition.Transaction {
+	tx := escrowTestTx(sender, recipient, 0, true)
+	tx.TxData.EscrowTransactionsDelay = delay
+	tx.TxData.MultiSignNumber = approvals
+	tx.TxData.MultiSignAddresses = cosigners
+	_ = tx.CalcHashAndSet()
+	return tx
+}
+
+func TestAccountConfig_CheckTransaction(t *testing.T) {
+	defer func(a account.AccountsType) { account.Accounts = a }(account.Accounts)
+	plain := [common.AddressLength]byte{11}
+	escrow := [common.AddressLength]byte{12}
+	other := [common.AddressLength]byte{13}
+	c1, c2 := [common.AddressLength]byte{14}, [common.AddressLength]byte{15}
+	account.Accounts = account.AccountsType{AllAccounts: map[[common.AddressLength]byte]account.Account{
+		plain:  {Address: plain},
+		escrow: {Address: escrow, TransactionDelay: 10},
+		other:  {Address: other, MultiSignNumber: 1, MultiSignAddresses: [][common.AddressLength]byte{c1}},
+	}}
+	vote := configTestTx(escrow, escrow, 20, 0)
+	vote.TxParam.MultiSignTx = common.Hash{1}
+	withGuardian := configTestTx(escrow, escrow, 20, 0)
+	withGuardian.TxData.EscrowGuardian = c1
+	ownGuardian := configTestTx(escrow, escrow, 20, 0)
+	ownGuardian.TxData.EscrowGuardian = escrow
+	guardianOnly := configTestTx(escrow, escrow, 0, 0)
+	guardianOnly.TxData.EscrowGuardian = c1
+
+	tests := []struct {
+		name            string
+		tx              transactionsDefinition.Transaction
+		reconfiguration bool
+		valid           bool
+	}{
+		{"plain account configures recipient", configTestTx(plain, [common.AddressLength]byte{16}, 10, 0), false, true},
+		{"plain account configures escrow", configTestTx(plain, escrow, 10, 0), false, false},
+		{"escrow changes delay", configTestTx(escrow, escrow, 20, 0), true, true},
+		{"escrow sets guardian", withGuardian, true, true},
+		{"escrow is its own guardian", ownGuardian, true, false},
+		{"guardian without delay", guardianOnly, false, false},
+		{"escrow configures other account", configTestTx(escrow, other, 20, 0), false, false},
+		{"multisig changes quorum", configTestTx(other, other, 0, 2, c1, c2), true, true},
+		{"multisig quorum above co-signers", configTestTx(other, other, 0, 3, c1, c2), true, false},
+		{"vote on proposal", vote, false, false},
+		{"too long delay", configTestTx(escrow, escrow, common.MaxTransactionDelay+1, 0), true, false},
+	}
+	for _, tt := range tests {
+		if got := IsAccountReconfiguration(tt.tx); got != tt.reconfiguration {
+			t.Errorf("%s: Expected reconfiguration %v, got %v", tt.name, tt.reconfiguration, got)
+		}
+		err := CheckAccountConfigTransaction(tt.tx, map[[common.AddressLength]byte]bool{})
+		if tt.valid && err != nil {
+			t.Errorf("%s: Expected valid, got %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: Expected error", tt.name)
+		}
+	}
+
+	configured := map[[common.AddressLength]byte]bool{}
+	if err := CheckAccountConfigTransaction(configTestTx(escrow, escrow, 20, 0), configured); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckAccountConfigTransaction(configTestTx(escrow, escrow, 30, 0), configured); err == nil {
+		t.Errorf("Expected second configuration of account in block rejected")
+	}
+}
+
+func TestAccountConfig_HistoryAndRevert(t *testing.T) {
+	defer func(a account.AccountsType) { account.Accounts = a }(account.Accounts)
+	const height = int64(1) << 40
+	addr := [common.AddressLength]byte{21, 1}
+	c1, c2 := [common.AddressLength]byte{22}, [common.AddressLength]byte{23}
+	account.Accounts = account.AccountsType{AllAccounts: map[[common.AddressLength]byte]account.Account{
+		addr: {Address: addr, TransactionDelay: 10, MultiSignNumber: 1, MultiSignAddresses: [][common.AddressLength]byte{c1}},
+	}}
+	defer RemoveAccountConfigsFromDB(height)
+	defer RemoveAccountConfigsFromDB(height + 1)
+
+	if err := ProcessAccountReconfiguration(configTestTx(addr, addr, 20, 2, c1, c2), height); err != nil {
+		t.Fatal(err)
+	}
+	acc := account.GetAccountByAddressBytes(addr[:])
+	if acc.TransactionDelay != 20 || acc.MultiSignNumber != 2 || len(acc.MultiSignAddresses) != 2 {
+		t.Fatalf("Expected new configuration applied, got %+v", acc)
+	}
+	if err := ProcessAccountReconfiguration(configTestTx(addr, addr, 30, 0), height+1); err != nil {
+		t.Fatal(err)
+	}
+	history := GetAccountConfigHistory(addr[:])
+	if len(history) != 2 || history[0].TransactionDelay != 20 || history[1].TransactionDelay != 30 || history[1].MultiSignNumber != 2 {
+		t.Fatalf("Expected two configuration versions, got %+v", history)
+	}
+
+	if err := RemoveAccountConfigsFromDB(height + 1); err != nil {
+		t.Fatal(err)
+	}
+	if history = GetAccountConfigHistory(addr[:]); len(history) != 1 || history[0].Height != height {
+		t.Errorf("Expected version of reset block removed from history, got %+v", history)
+	}
+}
@@ -38,6 +38,7 @@ func GetSupplyInAccounts() int64 {
 	return sum
 }
 
+// GetSupplyInStakedAccounts returns staked coins, including unstaked coins waiting for release, and rewards
 func GetSupplyInStakedAccounts() (int64, int64) {
 	sumStaked := int64(0)
 	sumRewards := int64(0)
@@ -47,6 +48,9 @@ func GetSupplyInStakedAccounts() (int64, int64) {
 	for _, delAcc := range account.StakingAccounts {
 		for _, acc := range delAcc.AllStakingAccounts {
 			sumStaked += acc.StakedBalance
+			for _, u := range acc.Unbonding {
+				sumStaked += u.Amount
+			}
 			sumRewards += acc.StakingRewards
 		}
 	}
@@ -0,0 +1,82 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+// smart contract calls and DEX operations of escrow and multi signature accounts which
+// matured or were approved in currently processed block
+var (
+	deferredSCTxs  []transactionsDefinition.Transaction
+	deferredSCLock sync.Mutex
+)
+
+func resetDeferredSC() {
+	deferredSCLock.Lock()
+	defer deferredSCLock.Unlock()
+	deferredSCTxs = nil
+}
+
+// deferSCTransaction remembers transaction when it calls smart contract or DEX
+func deferSCTransaction(tx transactionsDefinition.Transaction) {
+	n, err := account.IntDelegatedAccountFromAddress(tx.TxData.Recipient)
+	if (len(tx.TxData.OptData) == 0 || tx.GetLockedAmount() > 0) && !(err == nil && n > 512) {
+		return
+	}
+	deferredSCLock.Lock()
+	defer deferredSCLock.Unlock()
+	deferredSCTxs = append(deferredSCTxs, tx)
+}
+
+// EvaluateDeferredSC executes contract calls of matured escrow and approved multi signature
+// transactions in order of hashes and sets snapshot of block height, it is the last change
+// of EVM state in block. Failure of call does not invalidate block as transaction
+// was accepted earlier, it is stored in output logs of transaction.
+func EvaluateDeferredSC(bl Block) {
+	deferredSCLock.Lock()
+	txs := deferredSCTxs
+	deferredSCTxs = nil
+	deferredSCLock.Unlock()
+	sort.Slice(txs, func(i, j int) bool {
+		return bytes.Compare(txs[i].Hash.GetBytes(), txs[j].Hash.GetBytes()) < 0
+	})
+	height := bl.GetHeader().Height
+	for _, t := range txs {
+		logs := map[[common.HashLength]byte]string{}
+		addresses := map[[common.HashLength]byte]common.Address{}
+		optDatas := map[[common.AddressLength]byte][]byte{}
+		rets := map[[common.HashLength]byte][]byte{}
+		hh := [common.HashLength]byte{}
+		copy(hh[:], t.Hash.GetBytes())
+		if !evaluateSCTransaction(t, common.TransactionDBPrefix[:], bl, logs, addresses, optDatas, rets) {
+			logs[hh] = fmt.Sprint("execution of deferred transaction fails at height ", height)
+			logger.GetLogger().Println(logs[hh], t.Hash.GetHex())
+		}
+		if l, ok := logs[hh]; ok {
+			err := database.MainDB.Put(append(common.OutputLogsHashesDBPrefix[:], hh[:]...), []byte(l))
+			if err != nil {
+				logger.GetLogger().Println("Cannot store output logs")
+			}
+		}
+		if a, ok := addresses[hh]; ok {
+			aa := [common.AddressLength]byte{}
+			copy(aa[:], a.GetBytes())
+			err := database.MainDB.Put(append(common.OutputAddressesHashesDBPrefix[:], hh[:]...), optDatas[aa])
+			if err != nil {
+				logger.GetLogger().Println("Cannot store address codes")
+			}
+		}
+	}
+	StateMutex.Lock()
+	State.SetSnapShotNum(height, State.Snapshot())
+	StateMutex.Unlock()
+}
@@ -0,0 +1,131 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+// Difficulty is logarithmic, each of two halves of block header hash anded has to have
+// 128 - minimalHashProof(difficulty) leading zero bits, so expected work grows as (4/3)^bits.
+var workBase = math.Log(4.0 / 3.0)
+
+// difficultyBits returns number of leading zero bits required by difficulty
+func difficultyBits(difficulty int32) float64 {
+	return 128.0 - minimalHashProof(difficulty)
+}
+
+func clampDifficulty(difficulty int32) int32 {
+	if difficulty < 1 {
+		return 1
+	}
+	if difficulty > 0xff00 {
+		return 0xff00
+	}
+	return difficulty
+}
+
+// LWMADifficulty returns difficulty of next block as linearly weighted moving average of work of
+// blocks in window scaled by ratio of target to weighted solve times. Timestamps and difficulties of
+// len(timestamps)-1 last blocks are given from the oldest one preceded by timestamp of block before window.
+// Timestamps earlier than previous are moved after it and solve times are clamped to 6 targets,
+// so manipulated timestamp changes difficulty by at most its weight in window. Without difficulties
+// minimal difficulty is returned, with wrong number of timestamps the last difficulty.
+func LWMADifficulty(timestamps []int64, difficulties []int32, target float64) int32 {
+	if len(difficulties) == 0 {
+		return clampDifficulty(0)
+	}
+	n := len(timestamps) - 1
+	if n < 1 || len(difficulties) != n {
+		return clampDifficulty(difficulties[len(difficulties)-1])
+	}
+	weighted := 0.0
+	maxLogWork := 0.0
+	logWorks := make([]float64, n)
+	previous := timestamps[0]
+	for i := 1; i <= n; i++ {
+		t := timestamps[i]
+		if t <= previous {
+			t = previous + 1
+		}
+		solveTime := float64(t - previous)
+		if solveTime > 6*target {
+			solveTime = 6 * target
+		}
+		previous = t
+		weighted += float64(i) * solveTime
+		logWorks[i-1] = difficultyBits(difficulties[i-1]) * workBase
+		if i == 1 || logWorks[i-1] > maxLogWork {
+			maxLogWork = logWorks[i-1]
+		}
+	}
+	// average work is computed in log space as works overflow float64 for large difficulties
+	sum := 0.0
+	for _, lw := range logWorks {
+		sum += math.Exp(lw - maxLogWork)
+	}
+	logAverageWork := maxLogWork + math.Log(sum/float64(n))
+	k := float64(n*(n+1)) / 2
+	// difficulty does not grow faster than 10 times work in one block
+	if weighted < k*target/10 {
+		weighted = k * target / 10
+	}
+	bits := (logAverageWork + math.Log(k*target/weighted)) / workBase
+	return clampDifficulty(int32(math.Round(bits*float64(common.DifficultyMultiplier))) * 10)
+}
+
+func isLWMAActive(height int64) bool {
+	return common.DifficultyLWMAHeight > 0 && height >= common.DifficultyLWMAHeight && height > common.DifficultyWindow
+}
+
+// NextDifficulty returns difficulty of block following lastBlock, interval is time elapsed since lastBlock.
+// Step rule of AdjustDifficulty is replaced by LWMADifficulty from common.DifficultyLWMAHeight.
+func NextDifficulty(lastBlock Block, interval int64) int32 {
+	if !isLWMAActive(lastBlock.GetHeader().Height + 1) {
+		return AdjustDifficulty(lastBlock.GetHeader().Difficulty, interval)
+	}
+	difficulty, err := lwmaNextDifficulty(lastBlock, LoadBlock)
+	if err != nil {
+		return AdjustDifficulty(lastBlock.GetHeader().Difficulty, interval)
+	}
+	return difficulty
+}
+
+// lwmaNextDifficulty returns LWMADifficulty of block following lastBlock, blocks of window are loaded with loadBlock
+func lwmaNextDifficulty(lastBlock Block, loadBlock func(int64) (Block, error)) (int32, error) {
+	height := lastBlock.GetHeader().Height + 1
+	n := common.DifficultyWindow
+	timestamps := make([]int64, n+1)
+	difficulties := make([]int32, n)
+	timestamps[n] = lastBlock.GetBlockTimeStamp()
+	difficulties[n-1] = lastBlock.GetHeader().Difficulty
+	for i := n - 1; i >= 0; i-- {
+		bl, err := loadBlock(height - n - 1 + i)
+		if err != nil {
+			return 0, err
+		}
+		timestamps[i] = bl.GetBlockTimeStamp()
+		if i > 0 {
+			difficulties[i-1] = bl.GetHeader().Difficulty
+		}
+	}
+	return LWMADifficulty(timestamps, difficulties, float64(common.BlockTimeInterval)), nil
+}
+
+// checkDifficulty rejects block which difficulty differs from NextDifficulty from common.DifficultyLWMAHeight on.
+// Window reaching below firstStored height, the first block stored after snapshot sync, cannot be checked.
+func checkDifficulty(newBlock Block, lastBlock Block, loadBlock func(int64) (Block, error), firstStored int64) error {
+	height := lastBlock.GetHeader().Height + 1
+	if !isLWMAActive(height) || height-common.DifficultyWindow-1 < firstStored {
+		return nil
+	}
+	difficulty, err := lwmaNextDifficulty(lastBlock, loadBlock)
+	if err != nil {
+		return err
+	}
+	if newBlock.GetHeader().Difficulty != difficulty {
+		return fmt.Errorf("wrong difficulty %v of block, should be %v: checkDifficulty", newBlock.GetHeader().Difficulty, difficulty)
+	}
+	return nil
+}
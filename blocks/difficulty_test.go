@@ -0,0 +1,175 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+const simWindow = 60
+
+type difficultyRule func(timestamps []int64, difficulties []int32, interval int64) int32
+
+func stepRule(timestamps []int64, difficulties []int32, interval int64) int32 {
+	return AdjustDifficulty(difficulties[len(difficulties)-1], interval)
+}
+
+func lwmaRule(timestamps []int64, difficulties []int32, interval int64) int32 {
+	l := len(timestamps)
+	if l <= simWindow {
+		return stepRule(timestamps, difficulties, interval)
+	}
+	return LWMADifficulty(timestamps[l-simWindow-1:], difficulties[l-simWindow:], float64(common.BlockTimeInterval))
+}
+
+// simulateBlockTimes returns intervals of blocks produced with rule. Every second each validator sends
+// nonce which makes block with probability (3/4)^bits of difficulty, validators[i] is number of
+// validators when block i is produced.
+func simulateBlockTimes(rule difficultyRule, validators []int, seed int64) []int64 {
+	rng := rand.New(rand.NewSource(seed))
+	timestamps := []int64{0}
+	difficulties := []int32{1600}
+	intervals := []int64{}
+	t := int64(0)
+	for _, v := range validators {
+		for {
+			t++
+			last := timestamps[len(timestamps)-1]
+			d := rule(timestamps, difficulties, t-last)
+			p := math.Exp(-difficultyBits(d) * workBase)
+			if rng.Float64() < 1-math.Pow(1-p, float64(v)) {
+				timestamps = append(timestamps, t)
+				difficulties = append(difficulties, d)
+				intervals = append(intervals, t-last)
+				break
+			}
+		}
+	}
+	return intervals
+}
+
+func meanInterval(intervals []int64) float64 {
+	sum := 0.0
+	for _, i := range intervals {
+		sum += float64(i)
+	}
+	return sum / float64(len(intervals))
+}
+
+func TestLWMADifficulty_ConvergesWhenValidatorsJoinAndLeave(t *testing.T) {
+	target := float64(common.BlockTimeInterval)
+	phases := []int{10, 40, 5}
+	n := 600
+	validators := []int{}
+	for _, v := range phases {
+		for i := 0; i < n; i++ {
+			validators = append(validators, v)
+		}
+	}
+	for seed := int64(1); seed <= 3; seed++ {
+		step := simulateBlockTimes(stepRule, validators, seed)
+		lwma := simulateBlockTimes(lwmaRule, validators, seed)
+		for i, v := range phases {
+			// second half of phase, after difficulty adjusted to new number of validators
+			stepMean := meanInterval(step[i*n+n/2 : (i+1)*n])
+			lwmaMean := meanInterval(lwma[i*n+n/2 : (i+1)*n])
+			t.Logf("seed %v, %v validators: step rule %.2fs, LWMA %.2fs, target %.0fs", seed, v, stepMean, lwmaMean, target)
+			if math.Abs(lwmaMean-target) > 0.1*target {
+				t.Errorf("LWMA block time %.2f does not converge to %v", lwmaMean, target)
+			}
+			if math.Abs(lwmaMean-target) > math.Abs(stepMean-target) {
+				t.Errorf("LWMA block time %.2f is further from target than step rule %.2f", lwmaMean, stepMean)
+			}
+		}
+	}
+}
+
+func TestLWMADifficulty_ClampsTimestamps(t *testing.T) {
+	target := float64(common.BlockTimeInterval)
+	timestamps := make([]int64, simWindow+1)
+	difficulties := make([]int32, simWindow)
+	for i := range timestamps {
+		timestamps[i] = int64(i) * int64(target)
+	}
+	for i := range difficulties {
+		difficulties[i] = 1600
+	}
+	if d := LWMADifficulty(timestamps, difficulties, target); d != 1600 {
+		t.Errorf("Expected difficulty 1600 for blocks on target, got %v", d)
+	}
+
+	future := append([]int64{}, timestamps...)
+	future[simWindow] += 1000000
+	if d := LWMADifficulty(future, difficulties, target); d < 1500 || d >= 1600 {
+		t.Errorf("Expected timestamp in future to lower difficulty by at most 100, got %v", d)
+	}
+
+	past := append([]int64{}, timestamps...)
+	past[simWindow] = 0
+	if d := LWMADifficulty(past, difficulties, target); d <= 1600 || d > 1650 {
+		t.Errorf("Expected timestamp in past to raise difficulty by at most 50, got %v", d)
+	}
+
+	same := make([]int64, simWindow+1)
+	if d := LWMADifficulty(same, difficulties, target); d > 2400 {
+		t.Errorf("Expected growth of difficulty limited to 10 times work, got %v", d)
+	}
+}
+
+func TestLWMADifficulty_WrongWindow(t *testing.T) {
+	target := float64(common.BlockTimeInterval)
+	if d := LWMADifficulty(nil, nil, target); d != 1 {
+		t.Errorf("Expected minimal difficulty without window, got %v", d)
+	}
+	if d := LWMADifficulty([]int64{0}, []int32{1600}, target); d != 1600 {
+		t.Errorf("Expected last difficulty with wrong number of timestamps, got %v", d)
+	}
+}
+
+func TestCheckDifficulty_RejectsWrongDifficulty(t *testing.T) {
+	defer func(h, w int64) { common.DifficultyLWMAHeight, common.DifficultyWindow = h, w }(common.DifficultyLWMAHeight, common.DifficultyWindow)
+	common.DifficultyLWMAHeight = 10
+	common.DifficultyWindow = 5
+	chain := map[int64]Block{}
+	for h := int64(0); h < 20; h++ {
+		bl := Block{}
+		bl.BaseBlock.BaseHeader.Height = h
+		bl.BaseBlock.BaseHeader.Difficulty = 1600
+		bl.BaseBlock.BlockTimeStamp = h * int64(common.BlockTimeInterval)
+		chain[h] = bl
+	}
+	loadBlock := func(h int64) (Block, error) {
+		bl, ok := chain[h]
+		if !ok {
+			return Block{}, fmt.Errorf("no block at height %v", h)
+		}
+		return bl, nil
+	}
+	lastBlock := chain[19]
+	expected, err := lwmaNextDifficulty(lastBlock, loadBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBlock := Block{}
+	newBlock.BaseBlock.BaseHeader.Height = 20
+	newBlock.BaseBlock.BaseHeader.Difficulty = expected
+	if err := checkDifficulty(newBlock, lastBlock, loadBlock, 0); err != nil {
+		t.Errorf("Expected block with difficulty %v accepted, got %v", expected, err)
+	}
+	newBlock.BaseBlock.BaseHeader.Difficulty = expected - 10
+	if err := checkDifficulty(newBlock, lastBlock, loadBlock, 0); err == nil {
+		t.Errorf("Expected block with wrong difficulty rejected")
+	}
+	// window below first stored block after snapshot sync is not checked
+	if err := checkDifficulty(newBlock, lastBlock, loadBlock, 15); err != nil {
+		t.Errorf("Expected unchecked difficulty below snapshot base, got %v", err)
+	}
+	// step rule before activation height
+	common.DifficultyLWMAHeight = 21
+	if err := checkDifficulty(newBlock, lastBlock, loadBlock, 0); err != nil {
+		t.Errorf("Expected unchecked difficulty before activation, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+// Escrowed transfer can be cancelled before it matures with transaction of amount 0 which points
+// to it in TxParam.MultiSignTx and has escrow account as recipient. Cancellation has to be sent by
+// guardian of escrow account or by escrow account itself signed with secondary key.
+
+type EscrowTransfer struct {
+	Hash         common.Hash    `json:"hash"`
+	Account      common.Address `json:"account"`
+	Recipient    common.Address `json:"recipient"`
+	Amount       int64          `json:"amount"`
+	Height       int64          `json:"height"`
+	MatureHeight int64          `json:"mature_height"`
+	Guardian     common.Address `json:"guardian,omitempty"`
+}
+
+// escrowGuardianFromTx returns guardian set in transaction modifying account to escrow, zero address means no guardian
+func escrowGuardianFromTx(tx transactionsDefinition.Transaction) [common.AddressLength]byte {
+	return tx.TxData.EscrowGuardian
+}
+
+// IsEscrowCancellation checks if transaction of amount 0 sent to escrow account points to transfer
+// waiting in escrow pool and is sent by guardian or escrow account itself signed with secondary key. Other transactions pointing
+// to escrowed transfer, like votes of co-signers of escrow multi signature account, are not cancellations.
+func IsEscrowCancellation(tx transactionsDefinition.Transaction) bool {
+	if bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) || tx.TxData.Amount != 0 {
+		return false
+	}
+	escrowed, ok := transactionsPool.PoolTxEscrow.GetTransactionByHash(tx.TxParam.MultiSignTx.GetBytes())
+	if !ok {
+		return false
+	}
+	escrowAddress := escrowed.TxParam.Sender.GetBytes()
+	if !bytes.Equal(tx.TxData.Recipient.GetBytes(), escrowAddress) {
+		return false
+	}
+	acc := account.GetAccountByAddressBytes(escrowAddress)
+	sender := tx.TxParam.Sender.GetBytes()
+	sb := tx.Signature.GetBytes()
+	secondary := len(sb) > 0 && sb[0] != 0
+	return (bytes.Equal(sender, escrowAddress) && secondary) || (acc.HasGuardian() && bytes.Equal(sender, acc.Guardian[:]))
+}
+
+// ProcessEscrowCancellation removes escrowed transfer from pool when cancellation is authorized
+func ProcessEscrowCancellation(tx transactionsDefinition.Transaction, height int64) error {
+	escrowed := transactionsPool.PoolTxEscrow.PopTransactionByHash(tx.TxParam.MultiSignTx.GetBytes())
+	if bytes.Equal(escrowed.Hash.GetBytes(), ZerosHash) {
+		return fmt.Errorf("no escrowed transaction to cancel: ProcessEscrowCancellation")
+	}
+	escrowAddress := escrowed.TxParam.Sender.GetBytes()
+	acc := account.GetAccountByAddressBytes(escrowAddress)
+	sender := tx.TxParam.Sender.GetBytes()
+	sb := tx.Signature.GetBytes()
+	secondary := len(sb) > 0 && sb[0] != 0
+	authorized := (bytes.Equal(sender, escrowAddress) && secondary) ||
+		(acc.HasGuardian() && bytes.Equal(sender, acc.Guardian[:]))
+	if !authorized || !bytes.Equal(tx.TxData.Recipient.GetBytes(), escrowAddress) || tx.TxData.Amount != 0 {
+		transactionsPool.PoolTxEscrow.AddTransaction(escrowed, escrowed.Hash)
+		return fmt.Errorf("escrow cancellation not authorized: ProcessEscrowCancellation")
+	}
+	logger.GetLogger().Println("escrowed transaction", escrowed.Hash.GetHex(), "cancelled at height", height, "by", tx.TxParam.Sender.GetHex())
+	return AddBalance(tx.TxParam.Sender.ByteValue, -tx.GasPrice*tx.GasUsage)
+}
+
+// GetEscrowTransfers returns transfers waiting in escrow pool sent from address or guarded by address
+func GetEscrowTransfers(address []byte) []EscrowTransfer {
+	transfers := []EscrowTransfer{}
+	for _, tx := range transactionsPool.PoolTxEscrow.GetAllTransactions() {
+		acc := account.GetAccountByAddressBytes(tx.TxParam.Sender.GetBytes())
+		if !bytes.Equal(tx.TxParam.Sender.GetBytes(), address) && !(acc.HasGuardian() && bytes.Equal(acc.Guardian[:], address)) {
+			continue
+		}
+		et := EscrowTransfer{
+			Hash:         tx.Hash,
+			Account:      tx.TxParam.Sender,
+			Recipient:    tx.TxData.Recipient,
+			Amount:       tx.TxData.Amount,
+			Height:       tx.GetHeight(),
+			MatureHeight: tx.GetHeight() + acc.TransactionDelay,
+		}
+		if acc.HasGuardian() {
+			et.Guardian = common.Address{ByteValue: acc.Guardian}
+		}
+		transfers = append(transfers, et)
+	}
+	return transfers
+}
@@ -0,0 +1,71 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+func escrowTestTx(sender, recipient [common.AddressLength]byte, amount int64, primary bool) transactionsDefinition.Transaction {
+	tx := transactionsDefinition.Transaction{Height: 10, GasPrice: 1, GasUsage: 21000}
+	tx.TxParam.ChainID = common.GetChainID()
+	tx.TxParam.Sender = common.Address{ByteValue: sender, Primary: true}
+	tx.TxData.Recipient = common.Address{ByteValue: recipient, Primary: true}
+	tx.TxData.Amount = amount
+	scheme := byte(0)
+	if !primary {
+		scheme = 1
+	}
+	tx.Signature = common.Signature{ByteValue: append([]byte{scheme}, make([]byte, 10)...), Primary: primary}
+	_ = tx.CalcHashAndSet()
+	return tx
+}
+
+func TestIsEscrowCancellation_GuardianOrSecondaryKey(t *testing.T) {
+	defer func(a account.AccountsType) { account.Accounts = a }(account.Accounts)
+	escrowAddr := [common.AddressLength]byte{1}
+	guardian := [common.AddressLength]byte{2}
+	stranger := [common.AddressLength]byte{3}
+	recipient := [common.AddressLength]byte{4}
+	account.Accounts = account.AccountsType{AllAccounts: map[[common.AddressLength]byte]account.Account{
+		escrowAddr: {Address: escrowAddr, Balance: 1000, TransactionDelay: 100, Guardian: guardian},
+	}}
+	escrowed := escrowTestTx(escrowAddr, recipient, 500, true)
+	transactionsPool.PoolTxEscrow.AddTransaction(escrowed, escrowed.Hash)
+	defer transactionsPool.PoolTxEscrow.RemoveTransactionByHash(escrowed.Hash.GetBytes())
+
+	cancellation := func(sender, to [common.AddressLength]byte, amount int64, primary bool) transactionsDefinition.Transaction {
+		tx := escrowTestTx(sender, to, amount, primary)
+		tx.TxParam.MultiSignTx = escrowed.Hash
+		return tx
+	}
+	tests := []struct {
+		name string
+		tx   transactionsDefinition.Transaction
+		want bool
+	}{
+		{"guardian", cancellation(guardian, escrowAddr, 0, true), true},
+		{"escrow account with secondary key", cancellation(escrowAddr, escrowAddr, 0, false), true},
+		{"escrow account with primary key", cancellation(escrowAddr, escrowAddr, 0, true), false},
+		{"stranger", cancellation(stranger, escrowAddr, 0, true), false},
+		{"guardian with amount", cancellation(guardian, escrowAddr, 1, true), false},
+		{"guardian to other recipient", cancellation(guardian, recipient, 0, true), false},
+		{"not pointing to escrowed transfer", escrowTestTx(guardian, escrowAddr, 0, true), false},
+	}
+	for _, tt := range tests {
+		if got := IsEscrowCancellation(tt.tx); got != tt.want {
+			t.Errorf("%s: Expected cancellation %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	transfers := GetEscrowTransfers(guardian[:])
+	if len(transfers) != 1 || transfers[0].MatureHeight != escrowed.Height+100 || transfers[0].Guardian.ByteValue != guardian {
+		t.Errorf("Expected escrowed transfer listed for guardian with maturity, got %+v", transfers)
+	}
+	if transfers = GetEscrowTransfers(stranger[:]); len(transfers) != 0 {
+		t.Errorf("Expected no transfers listed for stranger, got %v", len(transfers))
+	}
+}
@@ -188,158 +188,169 @@ func EvaluateSCForBlock(bl Block) (bool, map[[common.HashLength]byte]string, map
 
 		senderAcc := account.GetAccountByAddressBytes(t.TxParam.Sender.GetBytes())
 
+		// escrow and multi signature transactions are executed later, see EvaluateDeferredSC
 		if senderAcc.TransactionDelay > 0 && t.GetHeight()+senderAcc.TransactionDelay > height {
-			//TODO escrow does not execute SC
 			continue
-
 		} else if senderAcc.MultiSignNumber > 0 {
-			//TODO MultiSignNumber does not execute SC
 			continue
 		}
-
-		addressRecipient := t.TxData.Recipient
-		n, err := account.IntDelegatedAccountFromAddress(addressRecipient)
-		if err == nil && n > 512 { // 514 == operation 2 etc...
-			operation := n - 512
-			//DEX checking transaction
-			dexOptData, fromAddress, coinAmount, tokenAmount, price, err := GenerateOptDataDEX(t, operation)
-			loggerMain.GetLogger().Printf("Token Price: %v\n", price)
-			if err != nil {
-				loggerMain.GetLogger().Println(err)
-				return false, nil, nil, nil, nil
-			}
-			// transfering tokens
-			l, _, _, _, err := EvaluateSCDex(t.ContractAddress, fromAddress, dexOptData, t, bl)
-			if err != nil {
-				loggerMain.GetLogger().Println(err)
-				return false, logs, map[[common.HashLength]byte]common.Address{}, map[[common.AddressLength]byte][]byte{}, map[[common.HashLength]byte][]byte{}
-			}
-			t.OutputLogs = []byte(l)
-			err = t.StoreToDBPoolTx(poolprefix)
-			if err != nil {
-				loggerMain.GetLogger().Println(err)
-				return false, logs, map[[common.HashLength]byte]common.Address{}, map[[common.AddressLength]byte][]byte{}, map[[common.HashLength]byte][]byte{}
-			}
-			aa := [common.AddressLength]byte{}
-			da := [common.AddressLength]byte{}
-			copy(aa[:], t.TxParam.Sender.GetBytes())
-			dex := common.GetDexAccountAddress()
-			copy(da[:], dex.GetBytes())
-			// transfering coins OKU
-
-			err = AddBalance(aa, coinAmount)
-			if err != nil {
-				loggerMain.GetLogger().Println(err)
-				return false, nil, nil, nil, nil
-			}
-			err = AddBalance(da, -coinAmount)
-			if err != nil {
-				loggerMain.GetLogger().Println(err)
-				return false, nil, nil, nil, nil
-			}
-
-			ba := [common.AddressLength]byte{}
-			copy(ba[:], t.ContractAddress.GetBytes())
-			StateMutex.RLock()
-			ti, ok := State.Tokens[ba]
-			StateMutex.RUnlock()
-			if !ok {
-				loggerMain.GetLogger().Println("no token with a given address")
-				return false, nil, nil, nil, nil
-			}
-
-			accDex := account.GetDexAccountByAddressBytes(t.ContractAddress.GetBytes())
-
-			accDex.TokenPrice = int64(price * math.Pow10(int(common.Decimals+ti.Decimals)))
-
-			if operation == 2 || operation > 4 { // no sell or buy
-				balances := accDex.Balances
-				if balances == nil {
-					balances = make(map[[common.AddressLength]byte]account.CoinTokenDetails)
-				}
-				coinAmountTmp := accDex.Balances[aa].CoinBalance - coinAmount
-				tokenAmountTmp := accDex.Balances[aa].TokenBalance - tokenAmount
-				balances[aa] = account.CoinTokenDetails{
-					CoinBalance:  coinAmountTmp,
-					TokenBalance: tokenAmountTmp,
-				}
-				accDex.Balances = balances
-			} else {
-				coinPercentTmp := float64(-coinAmount) / float64(accDex.CoinPool)
-				tokenPercentTmp := float64(-tokenAmount) / float64(accDex.TokenPool)
-
-				for addr, acc := range accDex.Balances {
-					balances := accDex.Balances[addr]
-					balances.TokenBalance += int64(common.RoundToken(tokenPercentTmp*float64(acc.TokenBalance), int(ti.Decimals)))
-					balances.CoinBalance += int64(common.RoundToken(coinPercentTmp*float64(acc.CoinBalance), int(common.Decimals)))
-					accDex.Balances[addr] = balances
-				}
-			}
-			accDex.TokenPool += -tokenAmount
-			accDex.CoinPool += -coinAmount
-			account.SetDexAccountByAddressBytes(t.ContractAddress.GetBytes(), accDex)
-
-			continue
-		}
-		if err == nil {
+		// approvals, rejections and cancellations only point to other transaction
+		if !bytes.Equal(t.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
 			continue
 		}
-		if len(t.TxData.OptData) == 0 {
-			continue
+
+		if !evaluateSCTransaction(t, poolprefix, bl, logs, addresses, optDatas, rets) {
+			return false, logs, map[[common.HashLength]byte]common.Address{}, map[[common.AddressLength]byte][]byte{}, map[[common.HashLength]byte][]byte{}
 		}
+	}
+	return true, logs, addresses, optDatas, rets
+}
 
-		l, ret, address, _, err := EvaluateSC(t, bl)
-		if t.TxData.Recipient == common.EmptyAddress() {
-			code := t.TxData.OptData
-			if ok := IsTokenToRegister(code); ok && err == nil {
-				input := stateDB.NameFunc
-				output, _, _, _, _, err := GetViewFunctionReturns(address, input, bl)
-				var name string
-				if err == nil {
-					name = common.GetStringFromSCBytes(common.Hex2Bytes(output), 0)
-				}
-				input = stateDB.SymbolFunc
-				output, _, _, _, _, err = GetViewFunctionReturns(address, input, bl)
-				var symbol string
-				if err == nil {
-					symbol = common.GetStringFromSCBytes(common.Hex2Bytes(output), 0)
-				}
-				input = stateDB.DecimalsFunc
-				output, _, _, _, _, err = GetViewFunctionReturns(address, input, bl)
-				var decimals uint8
-				if err == nil {
-					decimals = uint8(common.GetUintFromSCByte(common.Hex2Bytes(output)))
-				}
-				StateMutex.Lock()
-				State.RegisterNewToken(address, name, symbol, decimals)
-				StateMutex.Unlock()
-			}
+// evaluateSCTransaction executes DEX operation or smart contract of transaction and stores output logs
+func evaluateSCTransaction(t transactionsDefinition.Transaction, poolprefix []byte, bl Block, logs map[[common.HashLength]byte]string, addresses map[[common.HashLength]byte]common.Address, optDatas map[[common.AddressLength]byte][]byte, rets map[[common.HashLength]byte][]byte) bool {
+	addressRecipient := t.TxData.Recipient
+	n, err := account.IntDelegatedAccountFromAddress(addressRecipient)
+	if err == nil && n > 512 { // 514 == operation 2 etc...
+		operation := n - 512
+		//DEX checking transaction
+		dexOptData, fromAddress, coinAmount, tokenAmount, price, err := GenerateOptDataDEX(t, operation)
+		loggerMain.GetLogger().Printf("Token Price: %v\n", price)
+		if err != nil {
+			loggerMain.GetLogger().Println(err)
+			return false
 		}
+		// transfering tokens
+		l, _, _, _, err := EvaluateSCDex(t.ContractAddress, fromAddress, dexOptData, t, bl)
 		if err != nil {
 			loggerMain.GetLogger().Println(err)
-			return false, logs, map[[common.HashLength]byte]common.Address{}, map[[common.AddressLength]byte][]byte{}, map[[common.HashLength]byte][]byte{}
+			return false
 		}
-		//TODO we should refund left gas
-		//t.GasUsage -= int64(leftOverGas)
-		t.ContractAddress = address
-		outputLogs := []byte(l)
-
-		t.OutputLogs = outputLogs[:]
+		t.OutputLogs = []byte(l)
 		err = t.StoreToDBPoolTx(poolprefix)
 		if err != nil {
 			loggerMain.GetLogger().Println(err)
-			return false, logs, map[[common.HashLength]byte]common.Address{}, map[[common.AddressLength]byte][]byte{}, map[[common.HashLength]byte][]byte{}
+			return false
 		}
-		hh := [common.HashLength]byte{}
-		copy(hh[:], t.Hash.GetBytes()[:])
-		rets[hh] = ret
-		addresses[hh] = address
-		logs[hh] = l
 		aa := [common.AddressLength]byte{}
-		copy(aa[:], address.GetBytes()[:])
-		optDatas[aa] = t.TxData.OptData
+		da := [common.AddressLength]byte{}
+		copy(aa[:], t.TxParam.Sender.GetBytes())
+		dex := common.GetDexAccountAddress()
+		copy(da[:], dex.GetBytes())
+		// transfering coins OKU
+
+		err = AddBalance(aa, coinAmount)
+		if err != nil {
+			loggerMain.GetLogger().Println(err)
+			return false
+		}
+		err = AddBalance(da, -coinAmount)
+		if err != nil {
+			loggerMain.GetLogger().Println(err)
+			return false
+		}
+
+		ba := [common.AddressLength]byte{}
+		copy(ba[:], t.ContractAddress.GetBytes())
+		StateMutex.RLock()
+		ti, ok := State.Tokens[ba]
+		StateMutex.RUnlock()
+		if !ok {
+			loggerMain.GetLogger().Println("no token with a given address")
+			return false
+		}
+
+		accDex := account.GetDexAccountByAddressBytes(t.ContractAddress.GetBytes())
+
+		accDex.TokenPrice = int64(price * math.Pow10(int(common.Decimals+ti.Decimals)))
+
+		if operation == 2 || operation > 4 { // no sell or buy
+			balances := accDex.Balances
+			if balances == nil {
+				balances = make(map[[common.AddressLength]byte]account.CoinTokenDetails)
+			}
+			coinAmountTmp := accDex.Balances[aa].CoinBalance - coinAmount
+			tokenAmountTmp := accDex.Balances[aa].TokenBalance - tokenAmount
+			balances[aa] = account.CoinTokenDetails{
+				CoinBalance:  coinAmountTmp,
+				TokenBalance: tokenAmountTmp,
+			}
+			accDex.Balances = balances
+		} else {
+			coinPercentTmp := float64(-coinAmount) / float64(accDex.CoinPool)
+			tokenPercentTmp := float64(-tokenAmount) / float64(accDex.TokenPool)
+
+			for addr, acc := range accDex.Balances {
+				balances := accDex.Balances[addr]
+				balances.TokenBalance += int64(common.RoundToken(tokenPercentTmp*float64(acc.TokenBalance), int(ti.Decimals)))
+				balances.CoinBalance += int64(common.RoundToken(coinPercentTmp*float64(acc.CoinBalance), int(common.Decimals)))
+				accDex.Balances[addr] = balances
+			}
+		}
+		accDex.TokenPool += -tokenAmount
+		accDex.CoinPool += -coinAmount
+		account.SetDexAccountByAddressBytes(t.ContractAddress.GetBytes(), accDex)
+
+		return true
 	}
-	return true, logs, addresses, optDatas, rets
+	if err == nil {
+		return true
+	}
+	// OptData of locking transaction is vesting schedule
+	if len(t.TxData.OptData) == 0 || t.GetLockedAmount() > 0 {
+		return true
+	}
+
+	l, ret, address, _, err := EvaluateSC(t, bl)
+	if t.TxData.Recipient == common.EmptyAddress() {
+		code := t.TxData.OptData
+		if ok := IsTokenToRegister(code); ok && err == nil {
+			input := stateDB.NameFunc
+			output, _, _, _, _, err := GetViewFunctionReturns(address, input, bl)
+			var name string
+			if err == nil {
+				name = common.GetStringFromSCBytes(common.Hex2Bytes(output), 0)
+			}
+			input = stateDB.SymbolFunc
+			output, _, _, _, _, err = GetViewFunctionReturns(address, input, bl)
+			var symbol string
+			if err == nil {
+				symbol = common.GetStringFromSCBytes(common.Hex2Bytes(output), 0)
+			}
+			input = stateDB.DecimalsFunc
+			output, _, _, _, _, err = GetViewFunctionReturns(address, input, bl)
+			var decimals uint8
+			if err == nil {
+				decimals = uint8(common.GetUintFromSCByte(common.Hex2Bytes(output)))
+			}
+			StateMutex.Lock()
+			State.RegisterNewToken(address, name, symbol, decimals)
+			StateMutex.Unlock()
+		}
+	}
+	if err != nil {
+		loggerMain.GetLogger().Println(err)
+		return false
+	}
+	//TODO we should refund left gas
+	//t.GasUsage -= int64(leftOverGas)
+	t.ContractAddress = address
+	outputLogs := []byte(l)
+
+	t.OutputLogs = outputLogs[:]
+	err = t.StoreToDBPoolTx(poolprefix)
+	if err != nil {
+		loggerMain.GetLogger().Println(err)
+		return false
+	}
+	hh := [common.HashLength]byte{}
+	copy(hh[:], t.Hash.GetBytes()[:])
+	rets[hh] = ret
+	addresses[hh] = address
+	logs[hh] = l
+	aa := [common.AddressLength]byte{}
+	copy(aa[:], address.GetBytes()[:])
+	optDatas[aa] = t.TxData.OptData
+	return true
 }
 
 func EvaluateSC(tx transactionsDefinition.Transaction, bl Block) (logs string, ret []byte, address common.Address, leftOverGas uint64, err error) {
@@ -365,6 +376,7 @@ func EvaluateSC(tx transactionsDefinition.Transaction, bl Block) (logs string, r
 		Difficulty:  new(big.Int).SetInt64(int64(bl.GetHeader().Difficulty)),
 		BaseFee:     new(big.Int).SetInt64(int64(0)),
 		Random:      nil,
+		Oracle:      chainOracle{block: bl},
 	}
 	logger := vm.CreateGVMLogger()
 	jumpTable := vm.GetGenericJumpTable()
@@ -428,6 +440,7 @@ func EvaluateSCDex(tokenAddress common.Address, sender common.Address, optData [
 		Difficulty:  new(big.Int).SetInt64(int64(bl.GetHeader().Difficulty)),
 		BaseFee:     new(big.Int).SetInt64(int64(0)),
 		Random:      nil,
+		Oracle:      chainOracle{block: bl},
 	}
 	logger := vm.CreateGVMLogger()
 	jumpTable := vm.GetGenericJumpTable()
@@ -481,6 +494,7 @@ func GetViewFunctionReturns(contractAddr common.Address, OptData []byte, bl Bloc
 		Difficulty:  new(big.Int).SetInt64(int64(bl.GetHeader().Difficulty)),
 		BaseFee:     new(big.Int).SetInt64(int64(0)),
 		Random:      nil,
+		Oracle:      chainOracle{block: bl},
 	}
 	logger := vm.CreateGVMLogger()
 	jumpTable := vm.GetGenericJumpTable()
@@ -0,0 +1,319 @@
+package blocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/pubkeys"
+	"github.com/okuralabs/okura-node/wallet"
+)
+
+// Operators of delegated accounts sign hash of block at every multiple of common.CheckpointInterval.
+// Checkpoint is final when attestations of the same block come from delegated accounts with more
+// than 2/3 of staked coins. Chain is never reorganized below last finalized checkpoint.
+
+// CheckpointAttestation is signature of operator of delegated account under block at checkpoint height
+type CheckpointAttestation struct {
+	Height           int64  `json:"height"`
+	BlockHash        []byte `json:"block_hash"`
+	DelegatedAccount int    `json:"delegated_account"`
+	Signature        []byte `json:"signature"`
+}
+
+// FinalizedCheckpoint is checkpoint attested by supermajority of stake
+type FinalizedCheckpoint struct {
+	Height            int64  `json:"height"`
+	BlockHash         []byte `json:"block_hash"`
+	AttestedStaked    int64  `json:"attested_staked"`
+	TotalStaked       int64  `json:"total_staked"`
+	DelegatedAccounts []int  `json:"delegated_accounts"`
+	FinalizedAt       int64  `json:"finalized_at"` // height of chain when checkpoint became final
+}
+
+// FinalityStatus tells whether block at height is final and how far attestation of next checkpoint is
+type FinalityStatus struct {
+	Height             int64                `json:"height"`
+	Finalized          bool                 `json:"finalized"`
+	LastFinalized      *FinalizedCheckpoint `json:"last_finalized,omitempty"`
+	PendingCheckpoint  int64                `json:"pending_checkpoint"`
+	PendingAttested    int64                `json:"pending_attested"`
+	PendingAttestation []int                `json:"pending_attestation"` // delegated accounts which attested pending checkpoint
+	TotalStaked        int64                `json:"total_staked"`
+	Threshold          int64                `json:"threshold"` // attested stake has to exceed it
+}
+
+var finalityMutex sync.Mutex
+
+func (a CheckpointAttestation) signedHash() ([]byte, error) {
+	b := append([]byte("checkpoint"), common.GetByteInt16(common.GetChainID())...)
+	b = append(b, common.GetByteInt64(a.Height)...)
+	b = append(b, a.BlockHash...)
+	b = append(b, common.GetByteInt64(int64(a.DelegatedAccount))...)
+	return common.CalcHashToByte(b)
+}
+
+// Verify checks that attestation is signed by operator of delegated account with enough staked coins
+// and returns coins staked in delegated account
+func (a CheckpointAttestation) Verify() (int64, error) {
+	if a.Height <= 0 || a.Height%common.CheckpointInterval != 0 {
+		return 0, fmt.Errorf("height %v is not checkpoint: Verify", a.Height)
+	}
+	if a.DelegatedAccount < 1 || a.DelegatedAccount > 255 || len(a.BlockHash) != common.HashLength {
+		return 0, fmt.Errorf("wrong checkpoint attestation: Verify")
+	}
+	if !a.HasValidSignatureLength() {
+		return 0, fmt.Errorf("wrong length of signature of checkpoint attestation: Verify")
+	}
+	_, staked, operator := account.GetStakedInDelegatedAccount(a.DelegatedAccount)
+	if int64(staked) < common.MinStakingForNode {
+		return 0, fmt.Errorf("not enough staked in delegated account %v: Verify", a.DelegatedAccount)
+	}
+	primary := a.Signature[0] == 0
+	pk, err := pubkeys.LoadPubKeyWithPrimary(common.Address{ByteValue: operator.Address, Primary: true}, primary)
+	if err != nil {
+		return 0, err
+	}
+	if pubkeys.IsPubKeyRevoked(pk.Address.GetBytes()) {
+		return 0, fmt.Errorf("pubkey of operator is revoked: Verify")
+	}
+	hash, err := a.signedHash()
+	if err != nil {
+		return 0, err
+	}
+	if !wallet.Verify(hash, a.Signature, pk.GetBytes()) {
+		return 0, fmt.Errorf("wrong signature of checkpoint attestation: Verify")
+	}
+	return int64(staked), nil
+}
+
+// HasValidSignatureLength tells whether signature fits length of primary or secondary scheme
+// given by its first byte, like common.Signature.Init
+func (a CheckpointAttestation) HasValidSignatureLength() bool {
+	if len(a.Signature) < 2 {
+		return false
+	}
+	if a.Signature[0] == 0 {
+		return len(a.Signature) <= common.SignatureLength()+1
+	}
+	return len(a.Signature) <= common.SignatureLength2()+1
+}
+
+// AttestCheckpoint signs block at checkpoint height with wallet of operator of delegated account n
+func AttestCheckpoint(height int64, n int, w *wallet.Wallet) (CheckpointAttestation, error) {
+	_, _, operator := account.GetStakedInDelegatedAccount(n)
+	if operator.Address != w.MainAddress.ByteValue {
+		return CheckpointAttestation{}, fmt.Errorf("wallet is not operator of delegated account: AttestCheckpoint")
+	}
+	hash, err := LoadHashOfBlock(height)
+	if err != nil {
+		return CheckpointAttestation{}, err
+	}
+	a := CheckpointAttestation{Height: height, BlockHash: hash, DelegatedAccount: n}
+	sh, err := a.signedHash()
+	if err != nil {
+		return CheckpointAttestation{}, err
+	}
+	sig, err := w.Sign(sh, common.GetNodeSignPrimary(common.GetHeight()))
+	if err != nil {
+		return CheckpointAttestation{}, err
+	}
+	a.Signature = sig.GetBytes()
+	return a, nil
+}
+
+func attestationsKey(height int64) []byte {
+	return append(common.CheckpointAttestationsDBPrefix[:], common.GetByteInt64(height)...)
+}
+
+// LoadCheckpointAttestations returns attestations of our block at checkpoint height
+func LoadCheckpointAttestations(height int64) ([]CheckpointAttestation, error) {
+	as := []CheckpointAttestation{}
+	b, err := database.MainDB.Get(attestationsKey(height))
+	if err != nil {
+		return as, err
+	}
+	err = json.Unmarshal(b, &as)
+	return as, err
+}
+
+// AddCheckpointAttestation verifies and stores attestation of our block, checkpoint is finalized when
+// attested stake exceeds 2/3 of staked coins. Returns false when attestation is already known.
+func AddCheckpointAttestation(a CheckpointAttestation) (bool, error) {
+	finalityMutex.Lock()
+	defer finalityMutex.Unlock()
+	if a.Height <= LastFinalizedHeight() {
+		return false, nil
+	}
+	if a.Height > common.GetHeight() {
+		return false, fmt.Errorf("checkpoint %v is above last block: AddCheckpointAttestation", a.Height)
+	}
+	hash, err := LoadHashOfBlock(a.Height)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(hash, a.BlockHash) {
+		return false, fmt.Errorf("attestation of other block at %v: AddCheckpointAttestation", a.Height)
+	}
+	as, err := LoadCheckpointAttestations(a.Height)
+	if err != nil {
+		as = []CheckpointAttestation{}
+	}
+	as, added := appendAttestation(as, a)
+	if !added {
+		return false, nil
+	}
+	_, err = a.Verify()
+	if err != nil {
+		return false, err
+	}
+	staked, err := account.LoadStakedInDelegatedAccounts(a.Height)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(as)
+	if err != nil {
+		return false, err
+	}
+	err = database.MainDB.Put(attestationsKey(a.Height), b)
+	if err != nil {
+		return false, err
+	}
+	attested, ids := attestedStake(as, staked)
+	total := totalStake(staked)
+	if isFinalized(attested, total) {
+		f := FinalizedCheckpoint{
+			Height:            a.Height,
+			BlockHash:         a.BlockHash,
+			AttestedStaked:    attested,
+			TotalStaked:       total,
+			DelegatedAccounts: ids,
+			FinalizedAt:       common.GetHeight(),
+		}
+		err = storeFinalizedCheckpoint(f)
+		if err != nil {
+			return true, err
+		}
+		logger.GetLogger().Println("checkpoint finalized at height", a.Height)
+	}
+	return true, nil
+}
+
+// appendAttestation adds attestation unless delegated account already attested the checkpoint
+func appendAttestation(as []CheckpointAttestation, a CheckpointAttestation) ([]CheckpointAttestation, bool) {
+	for _, o := range as {
+		if o.DelegatedAccount == a.DelegatedAccount {
+			return as, false
+		}
+	}
+	return append(as, a), true
+}
+
+// isFinalized tells whether attested stake exceeds 2/3 of total stake
+func isFinalized(attested, total int64) bool {
+	return total > 0 && attested > 2*total/3
+}
+
+func totalStake(staked [256]int64) int64 {
+	total := int64(0)
+	for _, s := range staked {
+		total += s
+	}
+	return total
+}
+
+// attestedStake sums stake as of checkpoint height of delegated accounts which attested it,
+// every delegated account is counted once
+func attestedStake(as []CheckpointAttestation, staked [256]int64) (int64, []int) {
+	sum := int64(0)
+	ids := []int{}
+	seen := map[int]bool{}
+	for _, a := range as {
+		if a.DelegatedAccount < 0 || a.DelegatedAccount > 255 || seen[a.DelegatedAccount] {
+			continue
+		}
+		seen[a.DelegatedAccount] = true
+		sum += staked[a.DelegatedAccount]
+		ids = append(ids, a.DelegatedAccount)
+	}
+	sort.Ints(ids)
+	return sum, ids
+}
+
+func storeFinalizedCheckpoint(f FinalizedCheckpoint) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	err = database.MainDB.Put(append(common.FinalizedCheckpointDBPrefix[:], common.GetByteInt64(f.Height)...), b)
+	if err != nil {
+		return err
+	}
+	return database.MainDB.Put(common.LastFinalizedCheckpointDBKey[:], common.GetByteInt64(f.Height))
+}
+
+func LoadFinalizedCheckpoint(height int64) (FinalizedCheckpoint, error) {
+	f := FinalizedCheckpoint{}
+	b, err := database.MainDB.Get(append(common.FinalizedCheckpointDBPrefix[:], common.GetByteInt64(height)...))
+	if err != nil {
+		return f, err
+	}
+	err = json.Unmarshal(b, &f)
+	return f, err
+}
+
+// LastFinalizedHeight returns height of last finalized checkpoint, 0 when no checkpoint is final
+func LastFinalizedHeight() int64 {
+	b, err := database.MainDB.Get(common.LastFinalizedCheckpointDBKey[:])
+	if err != nil || len(b) != 8 {
+		return 0
+	}
+	return common.GetInt64FromByte(b)
+}
+
+// RemoveCheckpointAttestationsFromDB forgets attestations of block at height, used when chain is reset
+func RemoveCheckpointAttestationsFromDB(height int64) error {
+	if height%common.CheckpointInterval != 0 || height <= LastFinalizedHeight() {
+		return nil
+	}
+	return database.MainDB.Delete(attestationsKey(height))
+}
+
+// GetFinalityStatus returns finality of block at height together with attestation of last checkpoint
+func GetFinalityStatus(height int64) FinalityStatus {
+	h := common.GetHeight()
+	total := account.GetStakedInAllDelegatedAccounts()
+	s := FinalityStatus{
+		Height:             height,
+		PendingCheckpoint:  h - h%common.CheckpointInterval,
+		PendingAttestation: []int{},
+		TotalStaked:        total,
+		Threshold:          2 * total / 3,
+	}
+	last := LastFinalizedHeight()
+	if last > 0 {
+		f, err := LoadFinalizedCheckpoint(last)
+		if err == nil {
+			s.LastFinalized = &f
+		}
+	}
+	s.Finalized = height <= last
+	if s.PendingCheckpoint > last {
+		staked, err := account.LoadStakedInDelegatedAccounts(s.PendingCheckpoint)
+		if err == nil {
+			s.TotalStaked = totalStake(staked)
+			s.Threshold = 2 * s.TotalStaked / 3
+			as, err := LoadCheckpointAttestations(s.PendingCheckpoint)
+			if err == nil {
+				s.PendingAttested, s.PendingAttestation = attestedStake(as, staked)
+			}
+		}
+	}
+	return s
+}
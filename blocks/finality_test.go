@@ -0,0 +1,131 @@
+package blocks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+)
+
+func TestIsFinalized_MoreThanTwoThirds(t *testing.T) {
+	tests := []struct {
+		attested, total int64
+		final           bool
+	}{
+		{200, 300, false},
+		{201, 300, true},
+		{2, 3, false},
+		{3, 3, true},
+		{3, 5, false},
+		{4, 5, true},
+		{0, 0, false},
+	}
+	for _, tt := range tests {
+		if got := isFinalized(tt.attested, tt.total); got != tt.final {
+			t.Errorf("Expected finalized %v for %v of %v, got %v", tt.final, tt.attested, tt.total, got)
+		}
+	}
+}
+
+func TestAttestedStake_Threshold(t *testing.T) {
+	staked := [256]int64{}
+	staked[1], staked[2], staked[3] = 40, 30, 30
+	total := totalStake(staked)
+
+	tests := []struct {
+		accounts []int
+		attested int64
+		final    bool
+	}{
+		{[]int{1}, 40, false},
+		{[]int{2, 3}, 60, false},
+		{[]int{2, 1}, 70, true},
+		{[]int{1, 2, 3}, 100, true},
+	}
+	for _, tt := range tests {
+		as := []CheckpointAttestation{}
+		for _, n := range tt.accounts {
+			as = append(as, CheckpointAttestation{Height: 100, DelegatedAccount: n})
+		}
+		attested, _ := attestedStake(as, staked)
+		if attested != tt.attested || isFinalized(attested, total) != tt.final {
+			t.Errorf("Expected %v attested and finalized %v for %v, got %v", tt.attested, tt.final, tt.accounts, attested)
+		}
+	}
+}
+
+func TestAppendAttestation_Duplicate(t *testing.T) {
+	staked := [256]int64{}
+	staked[1], staked[2] = 40, 60
+
+	as, added := appendAttestation(nil, CheckpointAttestation{Height: 100, DelegatedAccount: 1})
+	if !added {
+		t.Fatalf("Expected first attestation added")
+	}
+	as, added = appendAttestation(as, CheckpointAttestation{Height: 100, DelegatedAccount: 1, Signature: []byte{0, 1}})
+	if added || len(as) != 1 {
+		t.Errorf("Expected duplicate attestation not added, got %v attestations", len(as))
+	}
+
+	// attestations stored twice by older nodes are counted once
+	dup := []CheckpointAttestation{{DelegatedAccount: 1}, {DelegatedAccount: 1}, {DelegatedAccount: 2}}
+	attested, ids := attestedStake(dup, staked)
+	if attested != 100 || !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("Expected duplicate counted once, got %v staked by %v", attested, ids)
+	}
+}
+
+func TestHasValidSignatureLength(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature []byte
+		valid     bool
+	}{
+		{"empty", nil, false},
+		{"scheme byte only", []byte{0}, false},
+		{"primary", append([]byte{0}, make([]byte, common.SignatureLength())...), true},
+		{"primary too long", append([]byte{0}, make([]byte, common.SignatureLength()+1)...), false},
+		{"secondary", append([]byte{1}, make([]byte, common.SignatureLength2())...), true},
+		{"secondary too long", append([]byte{1}, make([]byte, common.SignatureLength2()+1)...), false},
+	}
+	for _, tt := range tests {
+		a := CheckpointAttestation{Signature: tt.signature}
+		if got := a.HasValidSignatureLength(); got != tt.valid {
+			t.Errorf("%s: Expected %v, got %v", tt.name, tt.valid, got)
+		}
+	}
+}
+
+func TestLoadStakedInDelegatedAccounts_AtCheckpointHeight(t *testing.T) {
+	const height = int64(1) << 40
+	defer func(s [256]account.StakingAccountsType) { account.StakingAccounts = s }(account.StakingAccounts)
+	defer func() {
+		for i := 0; i < 256; i++ {
+			_ = database.MainDB.Delete(append(append(common.StakingAccountsDBPrefix[:], common.GetByteInt64(height)...), byte(i)))
+		}
+	}()
+
+	staker := [common.AddressLength]byte{7}
+	for i := range account.StakingAccounts {
+		account.StakingAccounts[i] = account.StakingAccountsType{AllStakingAccounts: map[[common.AddressLength]byte]account.StakingAccount{}}
+	}
+	account.StakingAccounts[5].AllStakingAccounts[staker] = account.StakingAccount{StakedBalance: 1000, Address: staker}
+	if err := account.StoreStakingAccounts(height); err != nil {
+		t.Fatal(err)
+	}
+	// stake changed after checkpoint does not change weight of attestation
+	account.StakingAccounts[5].AllStakingAccounts[staker] = account.StakingAccount{StakedBalance: 5000, Address: staker}
+
+	staked, err := account.LoadStakedInDelegatedAccounts(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staked[5] != 1000 || totalStake(staked) != 1000 {
+		t.Errorf("Expected stake 1000 at checkpoint height, got %v of %v", staked[5], totalStake(staked))
+	}
+	if _, err = account.LoadStakedInDelegatedAccounts(height + 1); err == nil {
+		t.Errorf("Expected error when staking accounts are not stored at height")
+	}
+}
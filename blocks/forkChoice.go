@@ -0,0 +1,342 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+// Fork choice follows chain with the highest cumulative difficulty, sum of BaseHeader.Difficulty of
+// all blocks from genesis. Blocks of competing branches are kept in DB until they are deeper than
+// common.MaxReorgDepth below last block.
+
+func cumulativeDifficultyKey(height int64) []byte {
+	return append(common.CumulativeDifficultyDBPrefix[:], common.GetByteInt64(height)...)
+}
+
+// StoreCumulativeDifficulty stores cumulative difficulty of chain ended with block
+func StoreCumulativeDifficulty(bl Block) error {
+	height := bl.GetHeader().Height
+	cd := int64(bl.GetHeader().Difficulty)
+	if height > 0 {
+		prev, err := LoadCumulativeDifficulty(height - 1)
+		if err != nil {
+			return err
+		}
+		cd += prev
+	}
+	return database.MainDB.Put(cumulativeDifficultyKey(height), common.GetByteInt64(cd))
+}
+
+// LoadCumulativeDifficulty returns cumulative difficulty at height, values missing in DB
+// stored before upgrade are calculated from blocks
+func LoadCumulativeDifficulty(height int64) (int64, error) {
+	if height < 0 {
+		return 0, fmt.Errorf("negative height: LoadCumulativeDifficulty")
+	}
+	b, err := database.MainDB.Get(cumulativeDifficultyKey(height))
+	if err == nil && len(b) == 8 {
+		return common.GetInt64FromByte(b), nil
+	}
+	start := height
+	cd := int64(0)
+	for start > 0 {
+		b, err = database.MainDB.Get(cumulativeDifficultyKey(start - 1))
+		if err == nil && len(b) == 8 {
+			cd = common.GetInt64FromByte(b)
+			break
+		}
+		start--
+	}
+	for i := start; i <= height; i++ {
+		bl, err := LoadBlock(i)
+		if err != nil {
+			return 0, err
+		}
+		cd += int64(bl.GetHeader().Difficulty)
+		err = database.MainDB.Put(cumulativeDifficultyKey(i), common.GetByteInt64(cd))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return cd, nil
+}
+
+func RemoveCumulativeDifficultyFromDB(height int64) error {
+	return database.MainDB.Delete(cumulativeDifficultyKey(height))
+}
+
+// verifiedDifficulty returns difficulty of block bl following prev when header hash is proved by it and
+// block may have it: weighted average of window from common.DifficultyLWMAHeight, before at most one step
+// of AdjustDifficulty from prev. Difficulties claimed by peers are not counted as work otherwise.
+func verifiedDifficulty(bl Block, prev Block, loadBlock func(int64) (Block, error)) (int32, error) {
+	height := bl.GetHeader().Height
+	hash, err := bl.BaseBlock.BaseHeader.CalcHash()
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(hash.GetBytes(), bl.BaseBlock.BlockHeaderHash.GetBytes()) || !bl.CheckProofOfSynergy() {
+		return 0, fmt.Errorf("proof of synergy fails of block %v: verifiedDifficulty", height)
+	}
+	difficulty := bl.GetHeader().Difficulty
+	if isLWMAActive(height) {
+		err = checkDifficulty(bl, prev, loadBlock, database.FirstStoredHeight())
+		if err != nil {
+			return 0, err
+		}
+		return difficulty, nil
+	}
+	last := prev.GetHeader().Difficulty
+	target := int64(common.BlockTimeInterval)
+	for _, interval := range []int64{0, target, 2*target + 1} {
+		if AdjustDifficulty(last, interval) == difficulty {
+			return difficulty, nil
+		}
+	}
+	return 0, fmt.Errorf("wrong difficulty %v of block %v: verifiedDifficulty", difficulty, height)
+}
+
+// BranchCumulativeDifficulty returns cumulative difficulty of chain where branch follows our block at forkHeight,
+// difficulties of branch blocks are verified
+func BranchCumulativeDifficulty(forkHeight int64, branch []Block) (int64, error) {
+	cd, err := LoadCumulativeDifficulty(forkHeight)
+	if err != nil {
+		return 0, err
+	}
+	prev, err := LoadBlock(forkHeight)
+	if err != nil {
+		return 0, err
+	}
+	loadBlock := func(height int64) (Block, error) {
+		if i := height - forkHeight - 1; i >= 0 && i < int64(len(branch)) {
+			return branch[i], nil
+		}
+		return LoadBlock(height)
+	}
+	for _, bl := range branch {
+		d, err := verifiedDifficulty(bl, prev, loadBlock)
+		if err != nil {
+			return 0, err
+		}
+		cd += int64(d)
+		prev = bl
+	}
+	return cd, nil
+}
+
+// IsBetterBranch tells whether branch following our block at forkHeight has higher cumulative
+// difficulty than our chain, with equal difficulty our chain is kept
+func IsBetterBranch(forkHeight int64, branch []Block) (bool, error) {
+	other, err := BranchCumulativeDifficulty(forkHeight, branch)
+	if err != nil {
+		return false, err
+	}
+	ours, err := LoadCumulativeDifficulty(common.GetHeight())
+	if err != nil {
+		return false, err
+	}
+	return other > ours, nil
+}
+
+// CheckBranchLinks checks that branch consecutively follows our block at forkHeight
+func CheckBranchLinks(forkHeight int64, branch []Block) error {
+	prevHash, err := LoadHashOfBlock(forkHeight)
+	if err != nil {
+		return err
+	}
+	for i, bl := range branch {
+		if bl.GetHeader().Height != forkHeight+int64(i)+1 {
+			return fmt.Errorf("wrong height of block %v in branch: CheckBranchLinks", bl.GetHeader().Height)
+		}
+		if !bytes.Equal(bl.GetHeader().PreviousHash.GetBytes(), prevHash) {
+			return fmt.Errorf("block %v does not follow previous block in branch: CheckBranchLinks", bl.GetHeader().Height)
+		}
+		prevHash = bl.BlockHash.GetBytes()
+	}
+	return nil
+}
+
+func branchByHeightKey(height int64) []byte {
+	return append(common.BranchBlocksByHeightDBPrefix[:], common.GetByteInt64(height)...)
+}
+
+// StoreBranchBlock keeps block of competing branch
+func StoreBranchBlock(bl Block) error {
+	hash := bl.BlockHash.GetBytes()
+	err := database.MainDB.Put(append(common.BranchBlocksDBPrefix[:], hash...), bl.GetBytes())
+	if err != nil {
+		return err
+	}
+	hashes := LoadBranchHashes(bl.GetHeader().Height)
+	for _, h := range hashes {
+		if bytes.Equal(h, hash) {
+			return nil
+		}
+	}
+	hb := bytes.Join(append(hashes, hash), nil)
+	return database.MainDB.Put(branchByHeightKey(bl.GetHeader().Height), hb)
+}
+
+func LoadBranchBlock(hash []byte) (Block, error) {
+	b, err := database.MainDB.Get(append(common.BranchBlocksDBPrefix[:], hash...))
+	if err != nil {
+		return Block{}, err
+	}
+	bl := Block{}
+	return bl.GetFromBytes(b)
+}
+
+// LoadBranchHashes returns hashes of blocks of competing branches at height
+func LoadBranchHashes(height int64) [][]byte {
+	hashes := [][]byte{}
+	b, err := database.MainDB.Get(branchByHeightKey(height))
+	if err != nil {
+		return hashes
+	}
+	for i := 0; i+common.HashLength <= len(b); i += common.HashLength {
+		hashes = append(hashes, b[i:i+common.HashLength])
+	}
+	return hashes
+}
+
+// BestBranch returns stored branch following our block at forkHeight with the highest verified
+// cumulative difficulty, empty when no stored block follows it. Forks deeper than common.MaxReorgDepth
+// or below finalized checkpoint are not searched.
+func BestBranch(forkHeight int64) ([]Block, error) {
+	if common.GetHeight()-forkHeight > common.MaxReorgDepth || forkHeight < LastFinalizedHeight() {
+		return nil, fmt.Errorf("fork at %v cannot be reorganized: BestBranch", forkHeight)
+	}
+	forkBlock, err := LoadBlock(forkHeight)
+	if err != nil {
+		return nil, err
+	}
+	s := branchSearch{
+		forkHeight: forkHeight,
+		maxHeight:  forkHeight + common.MaxReorgDepth + common.NumberOfHashesInBucket + 1,
+		cache:      map[common.Hash]branchResult{},
+	}
+	best, _ := s.bestFrom(forkBlock)
+	return best, nil
+}
+
+type branchResult struct {
+	blocks []Block
+	work   int64
+}
+
+// branchSearch finds the heaviest continuation of stored blocks. Result is cached per hash of block,
+// so every stored block is verified once however many branches join at it.
+type branchSearch struct {
+	forkHeight int64
+	maxHeight  int64
+	path       []Block // blocks from fork to the one which continuation is searched
+	cache      map[common.Hash]branchResult
+}
+
+func (s *branchSearch) loadBlock(height int64) (Block, error) {
+	if i := height - s.forkHeight - 1; i >= 0 && i < int64(len(s.path)) {
+		return s.path[i], nil
+	}
+	return LoadBlock(height)
+}
+
+func (s *branchSearch) bestFrom(prev Block) ([]Block, int64) {
+	height := prev.GetHeader().Height + 1
+	if height > s.maxHeight {
+		return []Block{}, 0
+	}
+	if r, ok := s.cache[prev.BlockHash]; ok {
+		return r.blocks, r.work
+	}
+	best, bestWork := []Block{}, int64(0)
+	for _, hash := range LoadBranchHashes(height) {
+		bl, err := LoadBranchBlock(hash)
+		if err != nil || !bytes.Equal(bl.GetHeader().PreviousHash.GetBytes(), prev.BlockHash.GetBytes()) {
+			continue
+		}
+		d, err := verifiedDifficulty(bl, prev, s.loadBlock)
+		if err != nil {
+			continue
+		}
+		s.path = append(s.path, bl)
+		rest, work := s.bestFrom(bl)
+		s.path = s.path[:len(s.path)-1]
+		work += int64(d)
+		if work > bestWork {
+			best, bestWork = append([]Block{bl}, rest...), work
+		}
+	}
+	s.cache[prev.BlockHash] = branchResult{blocks: best, work: bestWork}
+	return best, bestWork
+}
+
+// RemoveBranchBlock forgets block of competing branch, used when block joins chain
+func RemoveBranchBlock(bl Block) error {
+	hash := bl.BlockHash.GetBytes()
+	height := bl.GetHeader().Height
+	hashes := [][]byte{}
+	for _, h := range LoadBranchHashes(height) {
+		if !bytes.Equal(h, hash) {
+			hashes = append(hashes, h)
+		}
+	}
+	err := database.MainDB.Delete(append(common.BranchBlocksDBPrefix[:], hash...))
+	if err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return database.MainDB.Delete(branchByHeightKey(height))
+	}
+	return database.MainDB.Put(branchByHeightKey(height), bytes.Join(hashes, nil))
+}
+
+// RemoveBranchBlocksFromDB forgets blocks of competing branches at height
+func RemoveBranchBlocksFromDB(height int64) error {
+	for _, hash := range LoadBranchHashes(height) {
+		err := database.MainDB.Delete(append(common.BranchBlocksDBPrefix[:], hash...))
+		if err != nil {
+			return err
+		}
+	}
+	return database.MainDB.Delete(branchByHeightKey(height))
+}
+
+// PruneBranches removes competing blocks which cannot be used in reorg any more
+func PruneBranches(height int64) error {
+	keys, err := database.MainDB.LoadAllKeys(common.BranchBlocksByHeightDBPrefix[:])
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if len(k) != 10 {
+			continue
+		}
+		if h := common.GetInt64FromByte(k[2:]); h < height-common.MaxReorgDepth {
+			err = RemoveBranchBlocksFromDB(h)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReturnTransactionsToPool puts transactions of blocks back to pool DB, so blocks removed from
+// chain can be verified again and their transactions included in other blocks
+func ReturnTransactionsToPool(bls []Block) {
+	for _, bl := range bls {
+		for _, h := range bl.TransactionsHashes {
+			if transactionsDefinition.CheckFromDBPoolTx(common.TransactionPoolHashesDBPrefix[:], h.GetBytes()) {
+				continue
+			}
+			tx, err := transactionsDefinition.LoadFromDBPoolTx(common.TransactionDBPrefix[:], h.GetBytes())
+			if err != nil {
+				continue
+			}
+			tx.StoreToDBPoolTx(common.TransactionPoolHashesDBPrefix[:])
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+// provedBlock returns block at height which header hash is proved by difficulty
+func provedBlock(t *testing.T, height int64, difficulty int32) Block {
+	bl := Block{}
+	bl.BaseBlock.BaseHeader.Height = height
+	bl.BaseBlock.BaseHeader.Difficulty = difficulty
+	for i := 0; i < 1000; i++ {
+		bl.BaseBlock.BaseHeader.RootMerkleTree = common.BytesToHash(common.GetByteInt64(int64(i)))
+		hash, err := bl.BaseBlock.BaseHeader.CalcHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bl.BaseBlock.BlockHeaderHash = hash
+		if bl.CheckProofOfSynergy() {
+			return bl
+		}
+	}
+	t.Fatalf("cannot prove block with difficulty %v", difficulty)
+	return bl
+}
+
+func TestVerifiedDifficulty_StepRule(t *testing.T) {
+	defer func(h int64) { common.DifficultyLWMAHeight = h }(common.DifficultyLWMAHeight)
+	common.DifficultyLWMAHeight = 0
+	prev := provedBlock(t, 10, 20)
+	loadBlock := func(h int64) (Block, error) { return prev, nil }
+
+	tests := []struct {
+		difficulty int32
+		valid      bool
+	}{
+		{20 + int32(common.DifficultyChange), true},
+		{20, true},
+		{20 - int32(common.DifficultyChange), true},
+		{20 + 2*int32(common.DifficultyChange), false},
+		{1000, false},
+	}
+	for _, tt := range tests {
+		bl := provedBlock(t, 11, tt.difficulty)
+		d, err := verifiedDifficulty(bl, prev, loadBlock)
+		if tt.valid && (err != nil || d != tt.difficulty) {
+			t.Errorf("Expected difficulty %v verified, got %v, %v", tt.difficulty, d, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Expected difficulty %v not counted as work", tt.difficulty)
+		}
+	}
+}
+
+func TestVerifiedDifficulty_WrongHeaderHash(t *testing.T) {
+	prev := provedBlock(t, 10, 20)
+	bl := provedBlock(t, 11, 20)
+	bl.BaseBlock.BaseHeader.Difficulty = 30
+	if _, err := verifiedDifficulty(bl, prev, func(h int64) (Block, error) { return prev, nil }); err == nil {
+		t.Errorf("Expected block which header hash differs rejected")
+	}
+}
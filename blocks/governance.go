@@ -0,0 +1,170 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+// Operator of delegated account takes part in governance with transactions of amount 0 sent to it. OptData:
+// 'P' name with len, value(8), activation height(8) - proposes change of governed parameter and votes yes,
+// 'G' proposal id(8), vote(1) - votes yes (1) or no (0) with stake of delegated account.
+const (
+	GovernanceProposalMarker = byte('P')
+	GovernanceVoteMarker     = byte('G')
+)
+
+func IsGovernanceOperation(tx transactionsDefinition.Transaction) bool {
+	if tx.TxData.Amount != 0 || tx.GetLockedAmount() != 0 || len(tx.TxData.OptData) == 0 {
+		return false
+	}
+	return tx.TxData.OptData[0] == GovernanceProposalMarker || tx.TxData.OptData[0] == GovernanceVoteMarker
+}
+
+// GovernanceProposalOptData builds OptData of proposal of value of governed parameter
+func GovernanceProposalOptData(name string, value int64, activationHeight int64) []byte {
+	b := []byte{GovernanceProposalMarker}
+	b = append(b, common.BytesToLenAndBytes([]byte(name))...)
+	b = append(b, common.GetByteInt64(value)...)
+	return append(b, common.GetByteInt64(activationHeight)...)
+}
+
+// GovernanceVoteOptData builds OptData of vote on proposal
+func GovernanceVoteOptData(id int64, yes bool) []byte {
+	b := append([]byte{GovernanceVoteMarker}, common.GetByteInt64(id)...)
+	if yes {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// CheckGovernanceOperation checks proposal or vote of operator of delegated account n
+func CheckGovernanceOperation(sender []byte, opt []byte, n int, height int64) (account.GovernanceProposal, error) {
+	p := account.GovernanceProposal{}
+	_, staked, operator := account.GetStakedInDelegatedAccount(n)
+	if !bytes.Equal(operator.Address[:], sender) {
+		return p, fmt.Errorf("sender is not operator of delegated account: CheckGovernanceOperation")
+	}
+	if int64(staked) < common.MinStakingForNode {
+		return p, fmt.Errorf("not enough staked in delegated account: CheckGovernanceOperation")
+	}
+	switch opt[0] {
+	case GovernanceProposalMarker:
+		name, left, err := common.BytesWithLenToBytes(opt[1:])
+		if err != nil {
+			return p, err
+		}
+		if len(left) != 16 {
+			return p, fmt.Errorf("wrong governance proposal: CheckGovernanceOperation")
+		}
+		param, ok := common.GetGovernedParam(string(name))
+		if !ok {
+			return p, fmt.Errorf("parameter %v is not governed: CheckGovernanceOperation", string(name))
+		}
+		value := common.GetInt64FromByte(left[:8])
+		activation := common.GetInt64FromByte(left[8:])
+		if value < param.Min || value > param.Max {
+			return p, fmt.Errorf("value of %v has to be between %v and %v: CheckGovernanceOperation", param.Name, param.Min, param.Max)
+		}
+		votingEnd := height + common.GovernanceVotingPeriod
+		if activation < votingEnd+common.GovernanceActivationDelay {
+			return p, fmt.Errorf("activation height has to be at least %v: CheckGovernanceOperation", votingEnd+common.GovernanceActivationDelay)
+		}
+		p = account.GovernanceProposal{
+			Name:             param.Name,
+			Value:            value,
+			DelegatedAccount: n,
+			Height:           height,
+			VotingEnd:        votingEnd,
+			ActivationHeight: activation,
+			Votes:            map[int]bool{n: true},
+			Status:           account.ProposalVoting,
+		}
+		copy(p.Proposer[:], sender)
+	case GovernanceVoteMarker:
+		if len(opt) != 10 || opt[9] > 1 {
+			return p, fmt.Errorf("wrong governance vote: CheckGovernanceOperation")
+		}
+		var ok bool
+		p, ok = account.GetProposal(common.GetInt64FromByte(opt[1:9]))
+		if !ok {
+			return p, fmt.Errorf("no such governance proposal: CheckGovernanceOperation")
+		}
+		if p.Status != account.ProposalVoting || height > p.VotingEnd {
+			return p, fmt.Errorf("voting on proposal %v is closed: CheckGovernanceOperation", p.ID)
+		}
+		votes := make(map[int]bool, len(p.Votes)+1)
+		for k, v := range p.Votes {
+			votes[k] = v
+		}
+		votes[n] = opt[9] == 1
+		p.Votes = votes
+	default:
+		return p, fmt.Errorf("unknown governance operation: CheckGovernanceOperation")
+	}
+	return p, nil
+}
+
+func ProcessGovernanceOperation(tx transactionsDefinition.Transaction, height int64, n int) error {
+	p, err := CheckGovernanceOperation(tx.TxParam.Sender.GetBytes(), tx.TxData.OptData, n, height)
+	if err != nil {
+		return err
+	}
+	if tx.TxData.OptData[0] == GovernanceProposalMarker {
+		p = account.AddProposal(p)
+		logger.GetLogger().Println("governance proposal", p.ID, "to set", p.Name, "to", p.Value, "at height", p.ActivationHeight)
+	} else {
+		account.SetProposal(p)
+	}
+	return AddBalance(tx.TxParam.Sender.ByteValue, -tx.GasPrice*tx.GasUsage)
+}
+
+func isGovernanceActive(height int64) bool {
+	return common.GovernanceHeight > 0 && height >= common.GovernanceHeight
+}
+
+// ProcessGovernance tallies proposals which voting ended before height with current stakes of
+// delegated accounts and activates governed parameters at their activation height
+func ProcessGovernance(height int64) {
+	for _, p := range account.GetAllProposals() {
+		switch {
+		case p.Status == account.ProposalVoting && height > p.VotingEnd:
+			param, ok := common.GetGovernedParam(p.Name)
+			if !ok {
+				p.Status = account.ProposalRejected
+				account.SetProposal(p)
+				continue
+			}
+			p.YesStaked, p.NoStaked = 0, 0
+			for n, yes := range p.Votes {
+				_, staked, _ := account.GetStakedInDelegatedAccount(n)
+				if yes {
+					p.YesStaked += int64(staked)
+				} else {
+					p.NoStaked += int64(staked)
+				}
+			}
+			p.TotalStaked = account.GetStakedInAllDelegatedAccounts()
+			p.QuorumThirds = param.QuorumThirds(p.Value)
+			if p.YesStaked > p.TotalStaked*p.QuorumThirds/3 {
+				p.Status = account.ProposalPassed
+				account.AddParamChange(common.ParamChange{Name: p.Name, Value: p.Value, ActivationHeight: p.ActivationHeight, ProposalID: p.ID})
+			} else {
+				p.Status = account.ProposalRejected
+			}
+			logger.GetLogger().Println("governance proposal", p.ID, p.Status)
+			account.SetProposal(p)
+		case p.Status == account.ProposalPassed && height >= p.ActivationHeight:
+			p.Status = account.ProposalActivated
+			account.SetProposal(p)
+		}
+	}
+	err := account.ApplyGovernedParams(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+}
@@ -0,0 +1,238 @@
+package blocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+// Multi signature proposal is a transfer sent from multi signature account. Co-signers vote with
+// transactions of amount 0 which point to proposal in TxParam.MultiSignTx:
+// approval has recipient of proposal, rejection has proposal sender (multi sign account) as recipient.
+// When proposal is sent to multi sign account itself (configuration change) rejection is sent
+// by co-signer to their own address. Rejection of co-signer is final and overrides their approval.
+const (
+	MultiSignPending  = "pending"
+	MultiSignExecuted = "executed"
+	MultiSignRejected = "rejected"
+	MultiSignExpired  = "expired"
+	MultiSignFailed   = "failed"
+)
+
+type MultiSignProposal struct {
+	Hash         common.Hash      `json:"hash"`
+	Account      common.Address   `json:"account"`
+	Recipient    common.Address   `json:"recipient"`
+	Amount       int64            `json:"amount"`
+	Height       int64            `json:"height"`
+	ExpireHeight int64            `json:"expire_height"`
+	Required     uint8            `json:"required"`
+	Approvals    []common.Address `json:"approvals"`
+	Rejections   []common.Address `json:"rejections"`
+	Waiting      []common.Address `json:"waiting"`
+	Status       string           `json:"status"`
+	StatusHeight int64            `json:"status_height,omitempty"`
+}
+
+// multiSignVotes counts votes of co-signers of acc for mainTx
+func multiSignVotes(mainTx transactionsDefinition.Transaction, txs []transactionsDefinition.Transaction, acc account.Account) (approvals, rejections, waiting []common.Address) {
+	for _, signer := range acc.MultiSignAddresses {
+		approved, rejected := false, false
+		rejectTo := mainTx.TxParam.Sender.GetBytes()
+		if bytes.Equal(mainTx.TxData.Recipient.GetBytes(), rejectTo) {
+			rejectTo = signer[:]
+		}
+		for _, t := range txs {
+			if t.TxParam.Sender.ByteValue != signer || t.TxData.Amount != 0 ||
+				!bytes.Equal(t.TxParam.MultiSignTx.GetBytes(), mainTx.Hash.GetBytes()) {
+				continue
+			}
+			if bytes.Equal(t.TxData.Recipient.GetBytes(), mainTx.TxData.Recipient.GetBytes()) {
+				approved = true
+			} else if bytes.Equal(t.TxData.Recipient.GetBytes(), rejectTo) {
+				rejected = true
+			}
+		}
+		a := common.Address{ByteValue: signer}
+		switch {
+		case rejected:
+			rejections = append(rejections, a)
+		case approved:
+			approvals = append(approvals, a)
+		default:
+			waiting = append(waiting, a)
+		}
+	}
+	return approvals, rejections, waiting
+}
+
+func newMultiSignProposal(mainTx transactionsDefinition.Transaction, txs []transactionsDefinition.Transaction, acc account.Account, status string, height int64) MultiSignProposal {
+	approvals, rejections, waiting := multiSignVotes(mainTx, txs, acc)
+	return MultiSignProposal{
+		Hash:         mainTx.Hash,
+		Account:      mainTx.TxParam.Sender,
+		Recipient:    mainTx.TxData.Recipient,
+		Amount:       mainTx.TxData.Amount,
+		Height:       mainTx.GetHeight(),
+		ExpireHeight: mainTx.GetHeight() + common.MaxTransactionInMultiSigPool,
+		Required:     acc.MultiSignNumber,
+		Approvals:    approvals,
+		Rejections:   rejections,
+		Waiting:      waiting,
+		Status:       status,
+		StatusHeight: height,
+	}
+}
+
+// isMultiSignRejected is true when not enough co-signers are left to approve
+func isMultiSignRejected(p MultiSignProposal) bool {
+	return len(p.Rejections) > len(p.Approvals)+len(p.Rejections)+len(p.Waiting)-int(p.Required)
+}
+
+// closeMultiSignProposal removes proposal and all votes from pool and stores final result
+func closeMultiSignProposal(txs []transactionsDefinition.Transaction, p MultiSignProposal) {
+	for _, t := range txs {
+		transactionsPool.PoolTxMultiSign.RemoveTransactionByHash(t.Hash.GetBytes())
+	}
+	logger.GetLogger().Println("multi signature proposal", p.Hash.GetHex(), p.Status, "at height", p.StatusHeight)
+	err := storeMultiSignResult(p)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+}
+
+// IsOrphanMultiSignVote tells whether transaction votes on proposal which is not pending. Proposal of
+// escrow multi signature account waits in escrow pool before it matures.
+func IsOrphanMultiSignVote(tx transactionsDefinition.Transaction) bool {
+	h := tx.TxParam.MultiSignTx.GetBytes()
+	if bytes.Equal(h, ZerosHash) {
+		return false
+	}
+	return !transactionsPool.PoolTxMultiSign.TransactionExists(h) && !transactionsPool.PoolTxEscrow.TransactionExists(h)
+}
+
+func isMultiSignVoteActive(height int64) bool {
+	return common.MultiSignVoteHeight > 0 && height >= common.MultiSignVoteHeight
+}
+
+// addMultiSignVote adds vote to pool of multi signature proposals, vote on closed proposal is dropped
+func addMultiSignVote(tx transactionsDefinition.Transaction) {
+	if IsOrphanMultiSignVote(tx) {
+		logger.GetLogger().Println("vote", tx.Hash.GetHex(), "on proposal which is not pending")
+		return
+	}
+	transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.TxParam.MultiSignTx)
+}
+
+func isMultiSignExpiryActive(height int64) bool {
+	return common.MultiSignExpiryHeight > 0 && height >= common.MultiSignExpiryHeight
+}
+
+// ExpireMultiSignProposals removes proposals which waited for approvals longer than common.MaxTransactionInMultiSigPool.
+// Proposals are expired in order of hashes, so results are stored the same way on every node.
+func ExpireMultiSignProposals(height int64) {
+	all := transactionsPool.PoolTxMultiSign.GetAllTransactions()
+	sort.Slice(all, func(i, j int) bool {
+		return bytes.Compare(all[i].Hash.GetBytes(), all[j].Hash.GetBytes()) < 0
+	})
+	for _, mainTx := range all {
+		if !bytes.Equal(mainTx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+			continue
+		}
+		if height-mainTx.GetHeight() <= common.MaxTransactionInMultiSigPool {
+			continue
+		}
+		txs := transactionsPool.PoolTxMultiSign.PeekTransactions(common.MaxTransactionInPool, common.GetInt64FromByte(mainTx.Hash.GetBytes()))
+		acc := account.GetAccountByAddressBytes(mainTx.TxParam.Sender.GetBytes())
+		closeMultiSignProposal(txs, newMultiSignProposal(mainTx, txs, acc, MultiSignExpired, height))
+	}
+}
+
+func storeMultiSignResult(p MultiSignProposal) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	err = database.MainDB.Put(append(common.MultiSignResultDBPrefix[:], p.Hash.GetBytes()...), b)
+	if err != nil {
+		return err
+	}
+	key := append(common.MultiSignResultsByHeightDBPrefix[:], common.GetByteInt64(p.StatusHeight)...)
+	hashes, err := database.MainDB.Get(key)
+	if err != nil {
+		hashes = []byte{}
+	}
+	return database.MainDB.Put(key, append(hashes, p.Hash.GetBytes()...))
+}
+
+// RemoveMultiSignResultsFromDB removes results of proposals closed at height, used when chain is reset
+func RemoveMultiSignResultsFromDB(height int64) error {
+	key := append(common.MultiSignResultsByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	hashes, err := database.MainDB.Get(key)
+	if err != nil || len(hashes) == 0 {
+		return nil
+	}
+	for i := 0; i+common.HashLength <= len(hashes); i += common.HashLength {
+		err = database.MainDB.Delete(append(common.MultiSignResultDBPrefix[:], hashes[i:i+common.HashLength]...))
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+	return database.MainDB.Delete(key)
+}
+
+// GetMultiSignProposals returns pending proposals of multi signature account or proposals
+// waiting for co-signer with given address
+func GetMultiSignProposals(address []byte) []MultiSignProposal {
+	proposals := []MultiSignProposal{}
+	for _, mainTx := range transactionsPool.PoolTxMultiSign.GetAllTransactions() {
+		if !bytes.Equal(mainTx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+			continue
+		}
+		acc := account.GetAccountByAddressBytes(mainTx.TxParam.Sender.GetBytes())
+		related := bytes.Equal(mainTx.TxParam.Sender.GetBytes(), address)
+		for _, a := range acc.MultiSignAddresses {
+			if bytes.Equal(a[:], address) {
+				related = true
+				break
+			}
+		}
+		if !related {
+			continue
+		}
+		txs := transactionsPool.PoolTxMultiSign.PeekTransactions(common.MaxTransactionInPool, common.GetInt64FromByte(mainTx.Hash.GetBytes()))
+		proposals = append(proposals, newMultiSignProposal(mainTx, txs, acc, MultiSignPending, 0))
+	}
+	return proposals
+}
+
+// GetMultiSignProposal returns pending proposal or result of closed one
+func GetMultiSignProposal(hash []byte) (MultiSignProposal, error) {
+	if transactionsPool.PoolTxMultiSign.TransactionExists(hash) {
+		txs := transactionsPool.PoolTxMultiSign.PeekTransactions(common.MaxTransactionInPool, common.GetInt64FromByte(hash))
+		for _, t := range txs {
+			if bytes.Equal(t.Hash.GetBytes(), hash) {
+				acc := account.GetAccountByAddressBytes(t.TxParam.Sender.GetBytes())
+				return newMultiSignProposal(t, txs, acc, MultiSignPending, 0), nil
+			}
+		}
+	}
+	b, err := database.MainDB.Get(append(common.MultiSignResultDBPrefix[:], hash...))
+	if err != nil || len(b) == 0 {
+		return MultiSignProposal{}, fmt.Errorf("no multi signature proposal found: GetMultiSignProposal")
+	}
+	p := MultiSignProposal{}
+	err = json.Unmarshal(b, &p)
+	if err != nil {
+		return MultiSignProposal{}, err
+	}
+	return p, nil
+}
@@ -0,0 +1,35 @@
+package blocks
+
+import (
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/oracles"
+)
+
+// StoreOracleHistory indexes oracles of block and price proposals of validators by height
+func StoreOracleHistory(block Block) error {
+	bb := block.BaseBlock
+	r := oracles.OracleRecord{
+		Height:    bb.BaseHeader.Height,
+		Timestamp: bb.BlockTimeStamp,
+		Price:     bb.PriceOracle,
+		Rand:      bb.RandOracle,
+		Reports:   map[uint8]int64{},
+	}
+	prices, _, staked, err := oracles.ParsePriceData(bb.PriceOracleData)
+	if err != nil {
+		return err
+	}
+	for id, po := range prices {
+		r.Reports[id] = po.Price
+	}
+	r.PriceReporters = len(prices)
+	if total := account.GetStakedInAllDelegatedAccounts(); total > 0 {
+		r.StakedShare = float64(staked) / float64(total)
+	}
+	rands, _, _, err := oracles.ParseRandData(bb.RandOracleData, bb.BaseHeader.Height)
+	if err != nil {
+		return err
+	}
+	r.RandReporters = len(rands)
+	return oracles.StoreOracleRecord(r)
+}
@@ -0,0 +1,23 @@
+package blocks
+
+import "fmt"
+
+// chainOracle reads oracles for precompiled contracts, block being evaluated may be not stored yet
+type chainOracle struct {
+	block Block
+}
+
+func (o chainOracle) OracleAt(height uint64) (int64, int64, int64, error) {
+	bb := o.block.BaseBlock
+	if int64(height) == bb.BaseHeader.Height {
+		return bb.PriceOracle, bb.RandOracle, bb.BlockTimeStamp, nil
+	}
+	if int64(height) > bb.BaseHeader.Height {
+		return 0, 0, 0, fmt.Errorf("oracle height above evaluated block: OracleAt")
+	}
+	bl, err := LoadBlock(int64(height))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return bl.BaseBlock.PriceOracle, bl.BaseBlock.RandOracle, bl.BaseBlock.BlockTimeStamp, nil
+}
@@ -0,0 +1,23 @@
+package blocks
+
+import "testing"
+
+func TestChainOracle_EvaluatedBlock(t *testing.T) {
+	bl := Block{}
+	bl.BaseBlock.BaseHeader.Height = 1 << 40
+	bl.BaseBlock.PriceOracle = 123
+	bl.BaseBlock.RandOracle = -7
+	bl.BaseBlock.BlockTimeStamp = 1000
+	o := chainOracle{block: bl}
+
+	price, rand, ts, err := o.OracleAt(1 << 40)
+	if err != nil || price != 123 || rand != -7 || ts != 1000 {
+		t.Errorf("Expected oracles of evaluated block not stored yet, got %v %v %v %v", price, rand, ts, err)
+	}
+	if _, _, _, err = o.OracleAt(1<<40 + 1); err == nil {
+		t.Errorf("Expected error for height above evaluated block")
+	}
+	if _, _, _, err = o.OracleAt(1<<40 - 1); err == nil {
+		t.Errorf("Expected error for block which is not stored")
+	}
+}
@@ -24,6 +24,10 @@ func CheckBaseBlock(newBlock Block, lastBlock Block) (*transactionsPool.MerkleTr
 		logger.GetLogger().Println("lastBlock.BlockHash", lastBlock.BlockHash.GetHex(), newBlock.GetHeader().PreviousHash.GetHex())
 		return nil, fmt.Errorf("last block hash not match to one stored in new block")
 	}
+	err := checkDifficulty(newBlock, lastBlock, LoadBlock, database.FirstStoredHeight())
+	if err != nil {
+		return nil, err
+	}
 	// needs to check block and process
 	if newBlock.CheckProofOfSynergy() == false {
 		return nil, fmt.Errorf("proof of synergy fails of block")
@@ -55,7 +59,7 @@ func CheckBaseBlock(newBlock Block, lastBlock Block) (*transactionsPool.MerkleTr
 		if !oracles.VerifyPriceOracle(blockHeight, totalStaked, newBlock.BaseBlock.PriceOracle, newBlock.BaseBlock.PriceOracleData) {
 			return nil, fmt.Errorf("price oracle check fails")
 		}
-		if !oracles.VerifyRandOracle(blockHeight, totalStaked, newBlock.BaseBlock.RandOracle, newBlock.BaseBlock.RandOracleData) {
+		if !oracles.VerifyRandOracle(blockHeight, totalStaked, newBlock.BaseBlock.RandOracle, newBlock.BaseBlock.RandOracleData, lastBlock.BaseBlock.RandOracleData) {
 			return nil, fmt.Errorf("rand oracle check fails")
 		}
 	}
@@ -154,6 +158,9 @@ func CheckBlockTransfers(block Block, lastBlock Block) (int64, int64, error) {
 	accounts := map[[common.AddressLength]byte]account.Account{}
 	stakingAccounts := map[[common.AddressLength]byte]account.StakingAccount{}
 	totalFee := int64(0)
+	configured := map[[common.AddressLength]byte]bool{}
+	recoveries := map[[common.AddressLength]byte]account.Recovery{}
+	evidences := map[string]bool{}
 	for _, tx := range txs {
 		hash := tx.GetBytes()
 		poolTx, err := transactionsDefinition.LoadFromDBPoolTx(common.TransactionPoolHashesDBPrefix[:], hash)
@@ -197,7 +204,13 @@ func CheckBlockTransfers(block Block, lastBlock Block) (int64, int64, error) {
 		} else {
 			n, err = account.IntDelegatedAccountFromAddress(recipientAddress)
 		}
-		if err == nil && n < 512 { // delegated account
+		if err == nil && n > 0 && n < 256 && amount < 0 && common.GetUnbondingDelay(block.GetHeader().Height) > 0 {
+			// unstaked coins are not available before unbonding delay
+			total_amount = fee
+		}
+		// votes of co-signers on staking proposals carry no staking amount
+		isVote := isMultiSignVoteActive(block.GetHeader().Height) && !bytes.Equal(poolTx.TxParam.MultiSignTx.GetBytes(), ZerosHash)
+		if err == nil && n < 512 && !isVote { // delegated account
 			stakingAcc := account.GetStakingAccountByAddressBytes(address.GetBytes(), n%256)
 			if !bytes.Equal(stakingAcc.Address[:], address.GetBytes()) {
 
@@ -212,12 +225,20 @@ func CheckBlockTransfers(block Block, lastBlock Block) (int64, int64, error) {
 			stakingAcc.StakedBalance += amount
 			stakingAcc.StakingRewards += fee // just using for fee in the local copy
 			stakingAccounts[stakingAcc.Address] = stakingAcc
-			ret := CheckStakingTransaction(poolTx, stakingAccounts[stakingAcc.Address].StakedBalance, stakingAccounts[stakingAcc.Address].StakingRewards)
+			ret := CheckStakingTransaction(poolTx, stakingAccounts[stakingAcc.Address].StakedBalance, stakingAccounts[stakingAcc.Address].StakingRewards, block.GetHeader().Height)
 			if ret == false {
 				// remove bad transaction from pool
 				transactionsPool.RemoveBadTransactionByHash(poolTx.Hash.GetBytes(), block.GetHeader().Height)
 				return 0, 0, fmt.Errorf("staking transactions checking fails: CheckBlockTransfers")
 			}
+			if n > 0 && n < 256 && IsDoubleSignEvidence(poolTx) {
+				k, err := doubleSignEvidenceKey(poolTx.TxData.OptData, n)
+				if err != nil || evidences[k] {
+					transactionsPool.RemoveBadTransactionByHash(poolTx.Hash.GetBytes(), block.GetHeader().Height)
+					return 0, 0, fmt.Errorf("the same double signing evidence used twice in block: CheckBlockTransfers")
+				}
+				evidences[k] = true
+			}
 		}
 		acc := account.GetAccountByAddressBytes(address.GetBytes())
 		if !bytes.Equal(acc.Address[:], address.GetBytes()) {
@@ -230,6 +251,19 @@ func CheckBlockTransfers(block Block, lastBlock Block) (int64, int64, error) {
 			return 0, 0, fmt.Errorf("transaction which confirms in multi signature account should have amount == 0, OptData = nil, LockedAmount = 0, MultiSignNumber = 0")
 		}
 
+		err = CheckAccountConfigTransaction(poolTx, configured)
+		if err != nil {
+			transactionsPool.RemoveBadTransactionByHash(poolTx.Hash.GetBytes(), block.GetHeader().Height)
+			return 0, 0, err
+		}
+		if IsRecoveryTransaction(poolTx) {
+			err = CheckRecoveryTransaction(poolTx, block.GetHeader().Height, recoveries)
+			if err != nil {
+				transactionsPool.RemoveBadTransactionByHash(poolTx.Hash.GetBytes(), block.GetHeader().Height)
+				return 0, 0, err
+			}
+		}
+
 		if _, ok := accounts[acc.Address]; ok {
 			acc = accounts[acc.Address]
 			acc.Balance -= total_amount
@@ -256,10 +290,25 @@ func CheckBlockTransfers(block Block, lastBlock Block) (int64, int64, error) {
 }
 
 func ProcessBlockTransfers(block Block, reward int64) error {
-	err := ProcessTransactionsEscrow(block.GetHeader().Height)
+	err := CheckValidatorOfBlock(block)
+	if err != nil {
+		return err
+	}
+	resetDeferredSC()
+	err = ProcessTransactionsEscrow(block.GetHeader().Height)
 	if err != nil {
 		logger.GetLogger().Println("ProcessTransactionsEscrow: ", err)
 	}
+	if isMultiSignExpiryActive(block.GetHeader().Height) {
+		ExpireMultiSignProposals(block.GetHeader().Height)
+	}
+	if isSocialRecoveryActive(block.GetHeader().Height) {
+		ExecuteRecoveries(block.GetHeader().Height)
+	}
+	ReleaseUnbonded(block.GetHeader().Height)
+	if isGovernanceActive(block.GetHeader().Height) {
+		ProcessGovernance(block.GetHeader().Height)
+	}
 
 	txs := block.TransactionsHashes
 	for _, tx := range txs {
@@ -332,7 +381,12 @@ func ProcessBlockTransfers(block Block, reward int64) error {
 	} else if rest < 0 {
 		return fmt.Errorf("this shouldn't happen anytime: ProcessBlockTransfers")
 	}
-
+	UpdateValidatorsUptime(block, n)
+	err = StoreOracleHistory(block)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	EvaluateDeferredSC(block)
 	return nil
 }
 
@@ -346,11 +400,8 @@ func RemoveAllTransactionsRelatedToBlock(newBlock Block) {
 }
 
 func EvaluateSmartContracts(bl *Block) bool {
-	height := (*bl).GetHeader().Height
 	if ok, logs, addresses, codes, _ := EvaluateSCForBlock(*bl); ok {
-		StateMutex.Lock()
-		State.SetSnapShotNum(height, State.Snapshot())
-		StateMutex.Unlock()
+		// snapshot of height is set after deferred calls in EvaluateDeferredSC
 		for th, a := range addresses {
 
 			prefix := common.OutputLogsHashesDBPrefix[:]
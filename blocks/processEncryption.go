@@ -2,7 +2,9 @@ package blocks
 
 import (
 	"bytes"
+	"github.com/okuralabs/okura-node/account"
 	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/crypto/oqs"
 	"github.com/okuralabs/okura-node/logger"
 	"github.com/okuralabs/okura-node/voting"
 	"sync"
@@ -29,6 +31,7 @@ func ProcessBlockEncryption(block Block, lastBlock Block) error {
 
 		logger.GetLogger().Println("new encryption: ", enc1.ToString())
 		SetVoteEncryption(block.BaseBlock.BaseHeader.Encryption1[:], true)
+		storeSchemeChange(block.GetHeader().Height, enc1, true)
 		voting.ResetLastVoting()
 
 		err = AddNewPubKeyToActiveWallet(enc1.SigName, true)
@@ -44,6 +47,7 @@ func ProcessBlockEncryption(block Block, lastBlock Block) error {
 		}
 
 		SetVoteEncryption(block.BaseBlock.BaseHeader.Encryption2[:], false)
+		storeSchemeChange(block.GetHeader().Height, enc2, false)
 		voting.ResetLastVoting()
 		err = AddNewPubKeyToActiveWallet(enc2.SigName, false)
 		if err != nil {
@@ -54,6 +58,20 @@ func ProcessBlockEncryption(block Block, lastBlock Block) error {
 	return nil
 }
 
+// storeSchemeChange records applied scheme with votes which led to it, before votes are reset
+func storeSchemeChange(height int64, enc oqs.ConfigEnc, primary bool) {
+	c := voting.SchemeChange{
+		Height:  height,
+		Primary: primary,
+		Config:  enc,
+		Tally:   voting.Tally(height, account.GetStakedInAllDelegatedAccounts(), primary),
+	}
+	err := voting.StoreSchemeChange(c)
+	if err != nil {
+		logger.GetLogger().Println("cannot store scheme change", err)
+	}
+}
+
 func SetVoteEncryption(enc []byte, primary bool) {
 	enc1 := append([]byte{1}, enc...)
 	if primary {
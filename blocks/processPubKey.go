@@ -162,7 +162,12 @@ func StorePubKeyInPatriciaTrie(pk common.PubKey) error {
 //	return pk, nil
 //}
 
-// ProcessBlockPubKey : store pubkey on each transaction
+func isAllTxPubKeysActive(height int64) bool {
+	return common.AllTxPubKeysHeight > 0 && height >= common.AllTxPubKeysHeight
+}
+
+// ProcessBlockPubKey : store pubkey on each transaction. Before common.AllTxPubKeysHeight storing
+// stops at first transaction without pubkey, as in blocks already on chain.
 func ProcessBlockPubKey(block Block) error {
 	for _, txh := range block.TransactionsHashes {
 		t, err := transactionsDefinition.LoadFromDBPoolTx(common.TransactionPoolHashesDBPrefix[:], txh.GetBytes())
@@ -174,8 +179,13 @@ func ProcessBlockPubKey(block Block) error {
 		pk := t.TxData.Pubkey
 		zeroBytes := make([]byte, common.AddressLength)
 		if bytes.Equal(pk.MainAddress.GetBytes(), zeroBytes) {
-			return nil
+			if !isAllTxPubKeysActive(block.GetHeader().Height) {
+				return nil
+			}
+			// pubkeys of later transactions in block have to be stored as well
+			continue
 		}
+		added := !pubkeys.HasAddress(pk.MainAddress, pk.Address)
 		err = StorePubKey(pk)
 		if err != nil {
 			return err
@@ -184,6 +194,12 @@ func ProcessBlockPubKey(block Block) error {
 		if err != nil {
 			return err
 		}
+		if added {
+			err = pubkeys.RecordAddedPubKey(pk.MainAddress, pk.Address, block.GetHeader().Height)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -8,11 +8,13 @@ import (
 	"github.com/okuralabs/okura-node/logger"
 	"github.com/okuralabs/okura-node/transactionsDefinition"
 	"github.com/okuralabs/okura-node/transactionsPool"
+	"sort"
 )
 
 var ZerosHash = make([]byte, common.HashLength)
 
-func CheckStakingTransaction(tx transactionsDefinition.Transaction, sumAmount int64, sumFee int64) bool {
+// CheckStakingTransaction checks staking transaction included in block at height
+func CheckStakingTransaction(tx transactionsDefinition.Transaction, sumAmount int64, sumFee int64, height int64) bool {
 	fee := tx.GasPrice * tx.GasUsage
 	amount := tx.TxData.Amount
 	address := tx.GetSenderAddress()
@@ -69,7 +71,34 @@ func CheckStakingTransaction(tx transactionsDefinition.Transaction, sumAmount in
 				logger.GetLogger().Println("release per block has to be less or equal than ", tx.GetLockedAmount(), ": CheckStakingTransaction")
 				return false
 			}
+			if len(tx.TxData.OptData) > 0 {
+				_, err := account.ParseVestingOptData(tx.TxData.OptData, tx.GetLockedAmount(), tx.GetHeight(), address.ByteValue)
+				if err != nil {
+					logger.GetLogger().Println(err, ": CheckStakingTransaction")
+					return false
+				}
+			}
 
+		} else if _, ok := account.ParseVestingRevocation(tx.TxData.OptData); ok && amount == 0 {
+			// revocation is checked when executed, grantor pays only fee
+		} else if IsDoubleSignEvidence(tx) {
+			err := CheckDoubleSignEvidence(tx.TxData.OptData, n, height)
+			if err != nil {
+				logger.GetLogger().Println(err)
+				return false
+			}
+		} else if IsValidatorOperation(tx) {
+			_, err := CheckValidatorOperation(address.GetBytes(), tx.TxData.OptData, n, height)
+			if err != nil {
+				logger.GetLogger().Println(err)
+				return false
+			}
+		} else if IsGovernanceOperation(tx) {
+			_, err := CheckGovernanceOperation(address.GetBytes(), tx.TxData.OptData, n, height)
+			if err != nil {
+				logger.GetLogger().Println(err)
+				return false
+			}
 		} else {
 			accStaking := account.GetStakingAccountByAddressBytes(address.GetBytes(), n)
 			if !bytes.Equal(accStaking.DelegatedAccount[:], addressRecipient.GetBytes()) {
@@ -115,13 +144,16 @@ func CheckStakingTransaction(tx transactionsDefinition.Transaction, sumAmount in
 	return true
 }
 
-func ProcessMultiSignAndEscrow(tx transactionsDefinition.Transaction) error {
+func ProcessMultiSignAndEscrow(tx transactionsDefinition.Transaction, height int64) error {
 
 	acc := account.SetAccountByAddressBytes(tx.TxData.Recipient.ByteValue[:])
+	if tx.TxData.EscrowTransactionsDelay == 0 && tx.TxData.MultiSignNumber == 0 {
+		return nil
+	}
 
 	// modify escrow parameters
 	if tx.TxData.EscrowTransactionsDelay > 0 {
-		err := acc.ModifyAccountToEscrow(tx.TxData.EscrowTransactionsDelay)
+		err := acc.ModifyAccountToEscrow(tx.TxData.EscrowTransactionsDelay, escrowGuardianFromTx(tx))
 		if err != nil {
 			return err
 		}
@@ -143,13 +175,21 @@ func ProcessMultiSignAndEscrow(tx transactionsDefinition.Transaction) error {
 			return err
 		}
 	}
-	return nil
+	return storeAccountConfig(acc, tx.Hash, height)
+}
+
+// isSpecialDelegatedTransaction tells whether transaction sent to delegated account n is not staking
+// transfer but evidence, validator or governance operation
+func isSpecialDelegatedTransaction(tx transactionsDefinition.Transaction, n int) bool {
+	if n <= 0 || n >= 256 {
+		return false
+	}
+	return IsDoubleSignEvidence(tx) || IsValidatorOperation(tx) || IsGovernanceOperation(tx)
 }
 
 func ProcessTransaction(tx transactionsDefinition.Transaction, height int64) error {
 	fee := tx.GasPrice * tx.GasUsage
 	amount := tx.TxData.Amount
-	operational := len(tx.TxData.OptData) > 0
 	address := tx.GetSenderAddress()
 	addressRecipient := tx.TxData.Recipient
 	var err error
@@ -163,74 +203,59 @@ func ProcessTransaction(tx transactionsDefinition.Transaction, height int64) err
 		n, err = account.IntDelegatedAccountFromAddress(addressRecipient)
 	}
 	if err == nil { // this is delegated account
-		if n > 0 && n < 256 { // this is staking transaction
-
-			if tx.GetLockedAmount() > 0 {
-				if amount >= common.MinStakingUser {
-					err := account.Stake(addressRecipient.GetBytes(), amount, height, n, operational, tx.GetLockedAmount(), tx.GetReleasePerBlock())
-					if err != nil {
-						return err
-					}
-				} else {
-					return fmt.Errorf("wrong amount in locking: ProcessTransaction")
-				}
-				err = AddBalance(address.ByteValue, -fee-amount)
-				if err != nil {
-					return err
-				}
-			} else {
-				if amount >= common.MinStakingUser {
-					err := account.Stake(address.GetBytes(), amount, height, n, operational, 0, 0)
-					if err != nil {
-						return err
-					}
-				} else if amount < 0 {
-					err := account.Unstake(address.GetBytes(), amount, height, n)
-					if err != nil {
-						return err
-					}
-
-				} else {
-					return fmt.Errorf("wrong amount in staking/unstaking: ProcessTransaction")
-				}
-				err = AddBalance(address.ByteValue, -fee-amount)
-				if err != nil {
-					return err
-				}
+		if n == RecoveryAccountNumber { // social recovery
+			return ProcessRecoveryTransaction(tx, height)
+		}
+		if n > 0 && n < 256 && IsDoubleSignEvidence(tx) {
+			return ProcessDoubleSignEvidence(tx, height, n)
+		}
+		if n > 0 && n < 256 && IsValidatorOperation(tx) {
+			return ProcessValidatorOperation(tx, height, n)
+		}
+		if n > 0 && n < 256 && IsGovernanceOperation(tx) {
+			return ProcessGovernanceOperation(tx, height, n)
+		}
+		if n < 512 {
+			if isMultiSignVoteActive(height) && !bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+				// approval of staking operation proposed by multi signature account
+				addMultiSignVote(tx)
+				return AddBalance(address.ByteValue, -fee)
 			}
-			err := ProcessMultiSignAndEscrow(tx)
-			if err != nil {
-				return err
+			if isDelegatedEscrowActive(height) {
+				senderAcc := account.GetAccountByAddressBytes(address.GetBytes())
+				if senderAcc.TransactionDelay > 0 && tx.GetHeight()+senderAcc.TransactionDelay > height {
+					// escrow tx and multisigned should be paid fee upfront
+					transactionsPool.PoolTxEscrow.AddTransaction(tx, tx.Hash)
+					return AddBalance(address.ByteValue, -fee)
+				} else if senderAcc.MultiSignNumber > 0 {
+					transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.Hash)
+					return AddBalance(address.ByteValue, -fee)
+				}
 			}
+			return processStakingTransfer(tx, height, n, fee)
 		}
-		if n >= 256 && n < 512 { // this is reward withdrawal transaction
-
-			accStaking := account.GetStakingAccountByAddressBytes(address.GetBytes(), n%256)
-			if !bytes.Equal(accStaking.Address[:], address.GetBytes()) {
-				return fmt.Errorf("no staking account found in check staking transaction (rewards): ProcessTransaction")
-			}
-			if amount > 0 {
-				logger.GetLogger().Println("not implemented: ProcessTransaction")
-				//err := account.Reward(accStaking.Address[:], amount, height, n%256)
-				//if err != nil {
-				//	return err
-				//}
-			} else if amount < 0 {
-				err := account.WithdrawReward(accStaking.Address[:], amount, height, n%256)
-				if err != nil {
-					return err
-				}
-				err = AddBalance(address.ByteValue, -fee-amount)
-				if err != nil {
-					return err
+		if n > 512 && isDelegatedEscrowActive(height) { // DEX operation
+			senderAcc := account.GetAccountByAddressBytes(address.GetBytes())
+			if !bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+				// approval of DEX operation proposed by multi signature account
+				if isMultiSignVoteActive(height) {
+					addMultiSignVote(tx)
+				} else {
+					transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.TxParam.MultiSignTx)
 				}
-			} else {
-				return fmt.Errorf("wrong amount in rewarding: ProcessTransaction")
+			} else if senderAcc.TransactionDelay > 0 && tx.GetHeight()+senderAcc.TransactionDelay > height {
+				transactionsPool.PoolTxEscrow.AddTransaction(tx, tx.Hash)
+			} else if senderAcc.MultiSignNumber > 0 {
+				transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.Hash)
 			}
 		}
 	} else { // this is not delegated account so standard transaction
 
+		if IsEscrowCancellation(tx) {
+			return ProcessEscrowCancellation(tx, height)
+		}
 		senderAcc := account.GetAccountByAddressBytes(address.GetBytes())
+		pending := true
 
 		if senderAcc.TransactionDelay > 0 && tx.GetHeight()+senderAcc.TransactionDelay > height && bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
 			transactionsPool.PoolTxEscrow.AddTransaction(tx, tx.Hash)
@@ -240,6 +265,7 @@ func ProcessTransaction(tx transactionsDefinition.Transaction, height int64) err
 
 			transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.Hash)
 		} else {
+			pending = false
 			if bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) == false {
 				transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.TxParam.MultiSignTx)
 			}
@@ -259,11 +285,137 @@ func ProcessTransaction(tx transactionsDefinition.Transaction, height int64) err
 			return err
 		}
 
-		err := ProcessMultiSignAndEscrow(tx)
+		if IsAccountReconfiguration(tx) {
+			if pending {
+				// change of configuration waits for approvals or delay like other transfers
+				return nil
+			}
+			// delay of escrow passed before transaction was included
+			return ProcessAccountReconfiguration(tx, height)
+		}
+		err := ProcessMultiSignAndEscrow(tx, height)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDelegatedEscrowActive(height int64) bool {
+	return common.DelegatedEscrowHeight > 0 && height >= common.DelegatedEscrowHeight
+}
+
+// processStakingTransfer executes staking, unstaking, locking or reward withdrawal to delegated
+// account n. Fee is 0 when it was paid upfront by escrow or multi signature account.
+func processStakingTransfer(tx transactionsDefinition.Transaction, height int64, n int, fee int64) error {
+	amount := tx.TxData.Amount
+	operational := len(tx.TxData.OptData) > 0
+	address := tx.GetSenderAddress()
+	addressRecipient := tx.TxData.Recipient
+	var err error
+	if n > 0 && n < 256 { // this is staking transaction
+
+		if tx.GetLockedAmount() > 0 && len(tx.TxData.OptData) > 0 { // vesting grant
+			if amount < common.MinStakingUser {
+				return fmt.Errorf("wrong amount in vesting: processStakingTransfer")
+			}
+			v, err := account.ParseVestingOptData(tx.TxData.OptData, tx.GetLockedAmount(), height, address.ByteValue)
+			if err != nil {
+				return err
+			}
+			err = account.Stake(addressRecipient.GetBytes(), amount, height, n, false, 0, 0)
+			if err != nil {
+				return err
+			}
+			err = account.AddVesting(addressRecipient.GetBytes(), n, v)
+			if err != nil {
+				return err
+			}
+			err = AddBalance(address.ByteValue, -fee-amount)
+			if err != nil {
+				return err
+			}
+		} else if tx.GetLockedAmount() > 0 {
+			if amount >= common.MinStakingUser {
+				err := account.Stake(addressRecipient.GetBytes(), amount, height, n, operational, tx.GetLockedAmount(), tx.GetReleasePerBlock())
+				if err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("wrong amount in locking: processStakingTransfer")
+			}
+			err = AddBalance(address.ByteValue, -fee-amount)
+			if err != nil {
+				return err
+			}
+		} else {
+			if amount >= common.MinStakingUser {
+				err := account.Stake(address.GetBytes(), amount, height, n, operational, 0, 0)
+				if err != nil {
+					return err
+				}
+			} else if amount < 0 {
+				err := account.Unstake(address.GetBytes(), amount, height, n)
+				if err != nil {
+					return err
+				}
+				if delay := common.GetUnbondingDelay(height); delay > 0 {
+					// unstaked coins are credited by ReleaseUnbonded after delay
+					err = account.AddUnbonding(address.GetBytes(), -amount, height+delay, n)
+					if err != nil {
+						return err
+					}
+					amount = 0
+				}
+
+			} else if grantee, ok := account.ParseVestingRevocation(tx.TxData.OptData); ok {
+				// unvested amount of revoked grant returns to grantor
+				unvested, err := account.RevokeVesting(grantee[:], address.GetBytes(), height, n)
+				if err != nil {
+					return err
+				}
+				err = AddBalance(address.ByteValue, unvested)
+				if err != nil {
+					return err
+				}
+			} else {
+				return fmt.Errorf("wrong amount in staking/unstaking: processStakingTransfer")
+			}
+			err = AddBalance(address.ByteValue, -fee-amount)
+			if err != nil {
+				return err
+			}
+		}
+		err = ProcessMultiSignAndEscrow(tx, height)
 		if err != nil {
 			return err
 		}
 	}
+	if n >= 256 && n < 512 { // this is reward withdrawal transaction
+
+		accStaking := account.GetStakingAccountByAddressBytes(address.GetBytes(), n%256)
+		if !bytes.Equal(accStaking.Address[:], address.GetBytes()) {
+			return fmt.Errorf("no staking account found in check staking transaction (rewards): processStakingTransfer")
+		}
+		if amount > 0 {
+			logger.GetLogger().Println("not implemented: processStakingTransfer")
+			//err := account.Reward(accStaking.Address[:], amount, height, n%256)
+			//if err != nil {
+			//	return err
+			//}
+		} else if amount < 0 {
+			err := account.WithdrawReward(accStaking.Address[:], amount, height, n%256)
+			if err != nil {
+				return err
+			}
+			err = AddBalance(address.ByteValue, -fee-amount)
+			if err != nil {
+				return err
+			}
+		} else {
+			return fmt.Errorf("wrong amount in rewarding: processStakingTransfer")
+		}
+	}
 	return nil
 }
 
@@ -272,6 +424,10 @@ func ProcessTransactionsMultiSign(tx transactionsDefinition.Transaction, height
 	if bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
 		return nil
 	}
+	if !transactionsPool.PoolTxMultiSign.TransactionExists(tx.Hash.GetBytes()) {
+		// escrow cancellation is not kept in multi signature pool
+		return nil
+	}
 
 	txs := transactionsPool.PoolTxMultiSign.PeekTransactions(common.MaxTransactionInPool, common.GetInt64FromByte(tx.TxParam.MultiSignTx.GetBytes()))
 
@@ -290,39 +446,21 @@ func ProcessTransactionsMultiSign(tx transactionsDefinition.Transaction, height
 		return fmt.Errorf("no main transaction in multi signature pool")
 	}
 
+	acc := account.GetAccountByAddressBytes(mainTx.TxParam.Sender.GetBytes())
+
 	// remove transactions related to main if more than a week in pool
 	if height-mainTx.GetHeight() > common.MaxTransactionInMultiSigPool {
-		for _, t := range txs {
-			transactionsPool.PoolTxMultiSign.RemoveTransactionByHash(t.Hash.GetBytes())
-		}
+		closeMultiSignProposal(txs, newMultiSignProposal(mainTx, txs, acc, MultiSignExpired, height))
 		return fmt.Errorf("no main transaction in multi signature pool")
 	}
 
-	acc := account.GetAccountByAddressBytes(mainTx.TxParam.Sender.GetBytes())
-
-	if len(txs) < int(acc.MultiSignNumber) {
-		logger.GetLogger().Println("not enough signatures for transactions to process ", tx.TxParam.MultiSignTx.GetHex())
+	proposal := newMultiSignProposal(mainTx, txs, acc, MultiSignPending, height)
+	if isMultiSignRejected(proposal) {
+		proposal.Status = MultiSignRejected
+		closeMultiSignProposal(txs, proposal)
 		return nil
 	}
-	numApprovals := 0
-	notApprovedYet := acc.MultiSignAddresses[:]
-	for _, t := range txs {
-		sender := t.TxParam.Sender.ByteValue
-		appi := -1
-		for i, appr := range notApprovedYet {
-			if sender == appr && bytes.Equal(mainTx.TxData.Recipient.GetBytes(), t.TxData.Recipient.GetBytes()) && t.TxData.Amount == 0 {
-				numApprovals++
-				appi = i
-				break
-			}
-		}
-		if appi > 0 {
-			notApprovedYet = append(notApprovedYet[:appi], notApprovedYet[appi+1:]...)
-		} else if appi == 0 {
-			notApprovedYet = notApprovedYet[1:]
-		}
-	}
-	if numApprovals < int(acc.MultiSignNumber) {
+	if len(proposal.Approvals) < int(acc.MultiSignNumber) {
 		logger.GetLogger().Println("not enough signatures for transactions to process ", tx.TxParam.MultiSignTx.GetHex())
 		return nil
 	}
@@ -342,7 +480,25 @@ func ProcessTransactionsMultiSign(tx transactionsDefinition.Transaction, height
 	} else {
 		n, err = account.IntDelegatedAccountFromAddress(addressRecipient)
 	}
-	if err == nil { // delegated account any transfer should be processed for staking unstaking and reward withdrawal
+	if err == nil && n > 512 { // DEX operation is executed with smart contracts of block
+		proposal.Status = MultiSignExecuted
+		closeMultiSignProposal(txs, proposal)
+		deferSCTransaction(mainTx)
+		return nil
+	}
+	if err == nil { // staking, unstaking or reward withdrawal approved by co-signers
+		if acc.TransactionDelay > 0 && mainTx.GetHeight()+acc.TransactionDelay > height {
+			return fmt.Errorf("transaction should not be executed, should be delayed %v", mainTx.Hash.GetHex())
+		}
+		transactionsPool.PoolTxMultiSign.RemoveTransactionByHash(mainTx.Hash.GetBytes())
+		proposal.Status = MultiSignExecuted
+		err = processStakingTransfer(mainTx, height, n, 0)
+		if err != nil {
+			// votes were valid so block is not rejected, proposal is closed as failed
+			logger.GetLogger().Println(err)
+			proposal.Status = MultiSignFailed
+		}
+		closeMultiSignProposal(txs, proposal)
 		return nil
 	} else { // this is not delegated account so standard transaction
 
@@ -362,6 +518,16 @@ func ProcessTransactionsMultiSign(tx transactionsDefinition.Transaction, height
 			if err != nil {
 				return err
 			}
+			proposal.Status = MultiSignExecuted
+			if IsAccountReconfiguration(mainTx) {
+				err = ProcessAccountReconfiguration(mainTx, height)
+				if err != nil {
+					logger.GetLogger().Println(err)
+					proposal.Status = MultiSignFailed
+				}
+			}
+			closeMultiSignProposal(txs, proposal)
+			deferSCTransaction(mainTx)
 		}
 	}
 	return nil
@@ -370,6 +536,15 @@ func ProcessTransactionsMultiSign(tx transactionsDefinition.Transaction, height
 func ProcessTransactionsEscrow(height int64) error {
 
 	txs := transactionsPool.PoolTxEscrow.PeekTransactions(common.MaxTransactionInPool, height)
+	if isMultiSignVoteActive(height) {
+		// heap order is not the same on all nodes
+		sort.Slice(txs, func(i, j int) bool {
+			if txs[i].GetHeight() != txs[j].GetHeight() {
+				return txs[i].GetHeight() < txs[j].GetHeight()
+			}
+			return bytes.Compare(txs[i].Hash.GetBytes(), txs[j].Hash.GetBytes()) < 0
+		})
+	}
 
 	for _, tx := range txs {
 
@@ -386,35 +561,56 @@ func ProcessTransactionsEscrow(height int64) error {
 		} else {
 			n, err = account.IntDelegatedAccountFromAddress(addressRecipient)
 		}
-		if err == nil { // delegated account any transfer should be processed for staking unstaking and reward withdrawal
-			return nil
-		} else { // this is not delegated account so standard transaction
-			senderAcc := account.GetAccountByAddressBytes(address.GetBytes())
+		isDex := err == nil && n > 512
+		isStaking := err == nil && n < 512 && !isSpecialDelegatedTransaction(tx, n)
+		senderAcc := account.GetAccountByAddressBytes(address.GetBytes())
 
-			if senderAcc.TransactionDelay > 0 && tx.GetHeight()+senderAcc.TransactionDelay > height && bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+		if senderAcc.TransactionDelay > 0 && tx.GetHeight()+senderAcc.TransactionDelay > height && bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+			if !isMultiSignVoteActive(height) {
 				return fmt.Errorf("transaction should not be executed %v", tx.Hash.GetHex())
-			} else if senderAcc.MultiSignNumber > 0 && bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
-				if transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.Hash) {
-					transactionsPool.PoolTxEscrow.RemoveTransactionByHash(tx.Hash.GetBytes())
-				}
-			} else {
-				if bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) == false {
-					transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.TxParam.MultiSignTx)
-				}
+			}
+			// not matured yet
+			continue
+		} else if senderAcc.MultiSignNumber > 0 && bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) {
+			if transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.Hash) {
 				transactionsPool.PoolTxEscrow.RemoveTransactionByHash(tx.Hash.GetBytes())
-				err = AddBalance(address.ByteValue, -amount)
+			}
+		} else {
+			if bytes.Equal(tx.TxParam.MultiSignTx.GetBytes(), ZerosHash) == false {
+				transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.TxParam.MultiSignTx)
+			}
+			transactionsPool.PoolTxEscrow.RemoveTransactionByHash(tx.Hash.GetBytes())
+			if isDex {
+				deferSCTransaction(tx)
+				continue
+			}
+			if isStaking {
+				// fee was paid upfront, failure does not stop other escrowed transactions
+				err = processStakingTransfer(tx, height, n, 0)
 				if err != nil {
-					// this can happen very rare. Only when escrow is multisign account
-					transactionsPool.RemoveBadTransactionByHash(tx.Hash.GetBytes(), height)
-					return err
+					logger.GetLogger().Println("escrowed staking transaction", tx.Hash.GetHex(), "fails:", err)
 				}
+				continue
+			}
+			err = AddBalance(address.ByteValue, -amount)
+			if err != nil {
+				// this can happen very rare. Only when escrow is multisign account
+				transactionsPool.RemoveBadTransactionByHash(tx.Hash.GetBytes(), height)
+				return err
+			}
 
-				// amount is always >= 0, so no error here will be
-				err = AddBalance(addressRecipient.ByteValue, amount)
+			// amount is always >= 0, so no error here will be
+			err = AddBalance(addressRecipient.ByteValue, amount)
+			if err != nil {
+				return err
+			}
+			if IsAccountReconfiguration(tx) {
+				err = ProcessAccountReconfiguration(tx, height)
 				if err != nil {
-					return err
+					logger.GetLogger().Println("escrowed configuration change", tx.Hash.GetHex(), "fails:", err)
 				}
 			}
+			deferSCTransaction(tx)
 		}
 	}
 	return nil
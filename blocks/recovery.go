@@ -0,0 +1,313 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/pubkeys"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+// Social recovery is operated with transactions of amount 0 sent to recovery address (delegated
+// account RecoveryAccountNumber). OptData starts with operation:
+// 'R' threshold(1) delay(8) guardians(20 each) - account sets guardians, threshold 0 removes them,
+// when account has guardians already the change waits for their approval and delay,
+// 'I' account(20) primary pubkey with len, secondary pubkey with len - guardian approves rotation,
+// 'G' account(20) - guardian approves change of guardians,
+// 'C' - account cancels pending rotation or change of guardians with its current key.
+const RecoveryAccountNumber = 512
+
+const (
+	RecoveryRegister        = byte('R')
+	RecoveryInitiate        = byte('I')
+	RecoveryApproveGuardian = byte('G')
+	RecoveryCancel          = byte('C')
+)
+
+func GetRecoveryAddress() common.Address {
+	return common.GetDelegatedAccountAddress(RecoveryAccountNumber)
+}
+
+// IsRecoveryTransaction checks if transaction is sent to recovery address
+func IsRecoveryTransaction(tx transactionsDefinition.Transaction) bool {
+	recoveryAddress := GetRecoveryAddress()
+	return tx.GetLockedAmount() == 0 && bytes.Equal(tx.TxData.Recipient.GetBytes(), recoveryAddress.GetBytes())
+}
+
+// CheckRecoveryTransaction checks recovery operation of transaction to be included at height.
+// Recoveries changed by previous transactions of block are kept in local.
+func CheckRecoveryTransaction(tx transactionsDefinition.Transaction, height int64, local map[[common.AddressLength]byte]account.Recovery) error {
+	get := func(address []byte) account.Recovery {
+		a := [common.AddressLength]byte{}
+		copy(a[:], address)
+		if r, ok := local[a]; ok {
+			return r
+		}
+		return maturedRecovery(account.GetRecoveryByAddressBytes(address), height)
+	}
+	r, err := applyRecoveryOperation(tx, height, get)
+	if err != nil {
+		return err
+	}
+	local[r.Address] = r
+	return nil
+}
+
+func ProcessRecoveryTransaction(tx transactionsDefinition.Transaction, height int64) error {
+	r, err := applyRecoveryOperation(tx, height, account.GetRecoveryByAddressBytes)
+	if err != nil {
+		return err
+	}
+	account.SetRecovery(r)
+	return AddBalance(tx.TxParam.Sender.ByteValue, -tx.GasPrice*tx.GasUsage)
+}
+
+// applyRecoveryOperation returns recovery changed by transaction, get returns current recovery of address
+func applyRecoveryOperation(tx transactionsDefinition.Transaction, height int64, get func([]byte) account.Recovery) (account.Recovery, error) {
+	if tx.TxData.Amount != 0 {
+		return account.Recovery{}, fmt.Errorf("recovery transaction should have amount 0: applyRecoveryOperation")
+	}
+	opt := tx.TxData.OptData
+	if len(opt) == 0 {
+		return account.Recovery{}, fmt.Errorf("no recovery operation: applyRecoveryOperation")
+	}
+	sender := tx.TxParam.Sender.GetBytes()
+	switch opt[0] {
+	case RecoveryRegister:
+		return registerRecovery(get(sender), sender, opt[1:])
+	case RecoveryInitiate:
+		if len(opt) < 1+common.AddressLength {
+			return account.Recovery{}, fmt.Errorf("wrong recovery rotation data: applyRecoveryOperation")
+		}
+		return approveRotation(get(opt[1:1+common.AddressLength]), sender, opt[1+common.AddressLength:], height)
+	case RecoveryApproveGuardian:
+		if len(opt) != 1+common.AddressLength {
+			return account.Recovery{}, fmt.Errorf("wrong guardian change approval data: applyRecoveryOperation")
+		}
+		return approveGuardianChange(get(opt[1:]), sender, height)
+	case RecoveryCancel:
+		return cancelRotation(get(sender))
+	}
+	return account.Recovery{}, fmt.Errorf("unknown recovery operation: applyRecoveryOperation")
+}
+
+// registerRecovery sets guardians of account without guardians, otherwise proposes change of guardians
+func registerRecovery(r account.Recovery, sender []byte, data []byte) (account.Recovery, error) {
+	if len(data) < 9 || (len(data)-9)%common.AddressLength != 0 {
+		return r, fmt.Errorf("wrong recovery registration data: registerRecovery")
+	}
+	if account.GetAccountByAddressBytes(sender).MultiSignNumber > 0 {
+		return r, fmt.Errorf("multi signature account changes co-signers instead: registerRecovery")
+	}
+	if r.HasPendingChange() {
+		return r, fmt.Errorf("guardians cannot be changed during pending rotation or change of guardians: registerRecovery")
+	}
+	threshold := data[0]
+	delay := common.GetInt64FromByte(data[1:9])
+	guardians := [][common.AddressLength]byte{}
+	for i := 9; i < len(data); i += common.AddressLength {
+		g := [common.AddressLength]byte{}
+		copy(g[:], data[i:i+common.AddressLength])
+		if bytes.Equal(g[:], sender) || (account.Recovery{Guardians: guardians}).IsGuardian(g[:]) {
+			return r, fmt.Errorf("guardians have to be unique and different from account: registerRecovery")
+		}
+		guardians = append(guardians, g)
+	}
+	if len(guardians) > 0 {
+		if threshold == 0 || int(threshold) > len(guardians) {
+			return r, fmt.Errorf("threshold has to be between 1 and number of guardians: registerRecovery")
+		}
+		if delay <= 0 || delay > common.MaxTransactionDelay {
+			return r, fmt.Errorf("recovery delay has to be between 1 and %v: registerRecovery", common.MaxTransactionDelay)
+		}
+	} else if threshold != 0 {
+		return r, fmt.Errorf("no guardians defined: registerRecovery")
+	}
+	copy(r.Address[:], sender)
+	if len(r.Guardians) == 0 {
+		r.Guardians, r.Threshold, r.Delay = guardians, threshold, delay
+		return r, nil
+	}
+	r.GuardianChange = true
+	r.NewGuardians, r.NewThreshold, r.NewDelay = guardians, threshold, delay
+	return r, nil
+}
+
+// approveRotation adds approval of guardian. Approval of different pubkeys starts new proposal.
+// Pubkeys are stored only when rotation is executed.
+func approveRotation(r account.Recovery, guardian []byte, data []byte, height int64) (account.Recovery, error) {
+	if !r.IsGuardian(guardian) {
+		return r, fmt.Errorf("sender is not guardian of account: approveRotation")
+	}
+	if r.GuardianChange {
+		return r, fmt.Errorf("change of guardians is pending: approveRotation")
+	}
+	if r.EffectiveHeight > 0 {
+		return r, fmt.Errorf("rotation is approved and waits for delay: approveRotation")
+	}
+	mainAddress := common.Address{ByteValue: r.Address}
+	pkb1, left, err := common.BytesWithLenToBytes(data)
+	if err != nil {
+		return r, err
+	}
+	pkb2, _, err := common.BytesWithLenToBytes(left)
+	if err != nil {
+		return r, err
+	}
+	newKeys := [2][common.AddressLength]byte{}
+	for i, pkb := range [][]byte{pkb1, pkb2} {
+		if len(pkb) == 0 {
+			continue
+		}
+		pk := common.PubKey{}
+		err = pk.Init(pkb, mainAddress)
+		if err != nil {
+			return r, err
+		}
+		if pk.Primary != (i == 0) {
+			return r, fmt.Errorf("wrong type of pubkey in rotation: approveRotation")
+		}
+		if isPubKeyOfOtherAccount(pk) {
+			return r, fmt.Errorf("pubkey is registered for other account: approveRotation")
+		}
+		newKeys[i] = pk.Address.ByteValue
+	}
+	if newKeys == [2][common.AddressLength]byte{} {
+		return r, fmt.Errorf("no pubkeys in rotation: approveRotation")
+	}
+	if r.NewPrimary != newKeys[0] || r.NewSecondary != newKeys[1] {
+		r.ClearRotation()
+		r.NewPrimary, r.NewSecondary = newKeys[0], newKeys[1]
+		r.NewPrimaryKey, r.NewSecondaryKey = pkb1, pkb2
+	}
+	if r.Approve(guardian, height) {
+		logger.GetLogger().Println("rotation of pubkeys of", mainAddress.GetHex(), "effective at height", r.EffectiveHeight)
+	}
+	return r, nil
+}
+
+// approveGuardianChange adds approval of current guardian to change of guardians proposed by account
+func approveGuardianChange(r account.Recovery, guardian []byte, height int64) (account.Recovery, error) {
+	if !r.GuardianChange {
+		return r, fmt.Errorf("no pending change of guardians: approveGuardianChange")
+	}
+	if !r.IsGuardian(guardian) {
+		return r, fmt.Errorf("sender is not guardian of account: approveGuardianChange")
+	}
+	if r.EffectiveHeight > 0 {
+		return r, fmt.Errorf("change of guardians is approved and waits for delay: approveGuardianChange")
+	}
+	if r.Approve(guardian, height) {
+		mainAddress := common.Address{ByteValue: r.Address}
+		logger.GetLogger().Println("change of guardians of", mainAddress.GetHex(), "effective at height", r.EffectiveHeight)
+	}
+	return r, nil
+}
+
+func cancelRotation(r account.Recovery) (account.Recovery, error) {
+	if !r.HasPendingChange() {
+		return r, fmt.Errorf("no pending rotation or change of guardians: cancelRotation")
+	}
+	r.ClearRotation()
+	return r, nil
+}
+
+// isPubKeyOfOtherAccount checks if pubkey is registered already for different main address
+func isPubKeyOfOtherAccount(pk common.PubKey) bool {
+	stored, err := pubkeys.LoadPubKey(pk.Address.GetBytes())
+	return err == nil && !bytes.Equal(stored.MainAddress.GetBytes(), pk.MainAddress.GetBytes())
+}
+
+// maturedRecovery returns recovery as it is after its pending change effective at height is executed
+func maturedRecovery(r account.Recovery, height int64) account.Recovery {
+	if r.EffectiveHeight == 0 || r.EffectiveHeight > height {
+		return r
+	}
+	if r.GuardianChange {
+		r.Guardians, r.Threshold, r.Delay = r.NewGuardians, r.NewThreshold, r.NewDelay
+	}
+	r.ClearRotation()
+	return r
+}
+
+func isSocialRecoveryActive(height int64) bool {
+	return common.SocialRecoveryHeight > 0 && height >= common.SocialRecoveryHeight
+}
+
+// ExecuteRecoveries applies changes of guardians, adds rotated pubkeys to account and revokes replaced
+// ones. Added pubkeys and revocations are recorded with height, so they are reverted on chain reset.
+func ExecuteRecoveries(height int64) {
+	for _, r := range account.GetRecoveriesWithPendingChange() {
+		if r.EffectiveHeight == 0 || r.EffectiveHeight > height {
+			continue
+		}
+		mainAddress := common.Address{ByteValue: r.Address}
+		if r.GuardianChange {
+			logger.GetLogger().Println("guardians of", mainAddress.GetHex(), "changed at height", height)
+		} else {
+			rotatePubKeys(r, height)
+		}
+		account.SetRecovery(maturedRecovery(r, height))
+	}
+}
+
+func rotatePubKeys(r account.Recovery, height int64) {
+	mainAddress := common.Address{ByteValue: r.Address}
+	oldAddresses, err := pubkeys.LoadAddresses(mainAddress)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	keys := []common.PubKey{}
+	for _, pkb := range [][]byte{r.NewPrimaryKey, r.NewSecondaryKey} {
+		if len(pkb) == 0 {
+			continue
+		}
+		pk := common.PubKey{}
+		err = pk.Init(pkb, mainAddress)
+		if err != nil {
+			logger.GetLogger().Println(err)
+			return
+		}
+		if isPubKeyOfOtherAccount(pk) {
+			logger.GetLogger().Println("rotation of pubkeys of", mainAddress.GetHex(), "dropped, pubkey is registered for other account")
+			return
+		}
+		keys = append(keys, pk)
+	}
+	for _, pk := range keys {
+		added := !pubkeys.HasAddress(mainAddress, pk.Address)
+		err = StorePubKey(pk)
+		if err != nil {
+			logger.GetLogger().Println(err)
+			continue
+		}
+		err = StorePubKeyInPatriciaTrie(pk)
+		if err != nil {
+			logger.GetLogger().Println(err)
+			continue
+		}
+		if added {
+			err = pubkeys.RecordAddedPubKey(mainAddress, pk.Address, height)
+			if err != nil {
+				logger.GetLogger().Println(err)
+			}
+		}
+	}
+	for _, old := range oldAddresses {
+		if old.ByteValue == r.NewPrimary || old.ByteValue == r.NewSecondary {
+			continue
+		}
+		if (old.Primary && r.NewPrimary == [common.AddressLength]byte{}) ||
+			(!old.Primary && r.NewSecondary == [common.AddressLength]byte{}) {
+			continue
+		}
+		err = pubkeys.RevokePubKey(old, height)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+	logger.GetLogger().Println("pubkeys of", mainAddress.GetHex(), "rotated at height", height)
+}
@@ -0,0 +1,112 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+)
+
+func recoveryRegistration(threshold uint8, delay int64, guardians ...[common.AddressLength]byte) []byte {
+	data := append([]byte{threshold}, common.GetByteInt64(delay)...)
+	for _, g := range guardians {
+		data = append(data, g[:]...)
+	}
+	return data
+}
+
+func TestRecovery_GuardianChangeNeedsApprovalAndDelay(t *testing.T) {
+	acc := [common.AddressLength]byte{1}
+	g1 := [common.AddressLength]byte{2}
+	g2 := [common.AddressLength]byte{3}
+	g3 := [common.AddressLength]byte{4}
+
+	r, err := registerRecovery(account.Recovery{}, acc[:], recoveryRegistration(2, 10, g1, g2))
+	if err != nil {
+		t.Fatalf("Expected first registration to succeed, got %v", err)
+	}
+	if len(r.Guardians) != 2 || r.GuardianChange {
+		t.Fatalf("Expected first registration to set guardians immediately, got %+v", r)
+	}
+
+	r, err = registerRecovery(r, acc[:], recoveryRegistration(0, 0))
+	if err != nil {
+		t.Fatalf("Expected removal of guardians to be proposed, got %v", err)
+	}
+	if len(r.Guardians) != 2 || !r.GuardianChange {
+		t.Fatalf("Expected guardians kept until change is approved, got %+v", r)
+	}
+	if _, err = registerRecovery(r, acc[:], recoveryRegistration(1, 10, g3)); err == nil {
+		t.Errorf("Expected second change during pending change to fail")
+	}
+
+	tests := []struct {
+		name      string
+		guardian  [common.AddressLength]byte
+		wantErr   bool
+		effective int64
+	}{
+		{"not guardian", g3, true, 0},
+		{"first approval", g1, false, 0},
+		{"repeated approval", g1, false, 0},
+		{"threshold reached", g2, false, 110},
+		{"approval after threshold", g1, true, 110},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := approveGuardianChange(r, tt.guardian[:], 100)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil {
+				r = next
+			}
+			if r.EffectiveHeight != tt.effective {
+				t.Errorf("Expected effective height %v, got %v", tt.effective, r.EffectiveHeight)
+			}
+		})
+	}
+
+	if m := maturedRecovery(r, 109); len(m.Guardians) != 2 {
+		t.Errorf("Expected guardians unchanged before delay, got %+v", m)
+	}
+	if m := maturedRecovery(r, 110); len(m.Guardians) != 0 || m.HasPendingChange() {
+		t.Errorf("Expected guardians removed after delay, got %+v", m)
+	}
+
+	cancelled, err := cancelRotation(r)
+	if err != nil || cancelled.HasPendingChange() || len(cancelled.Guardians) != 2 {
+		t.Errorf("Expected cancel to keep current guardians, got %+v, %v", cancelled, err)
+	}
+	if _, err = cancelRotation(cancelled); err == nil {
+		t.Errorf("Expected cancel without pending change to fail")
+	}
+}
+
+func TestRecovery_RegistrationData(t *testing.T) {
+	acc := [common.AddressLength]byte{1}
+	g1 := [common.AddressLength]byte{2}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", recoveryRegistration(1, 10, g1), false},
+		{"short", []byte{1}, true},
+		{"account as guardian", recoveryRegistration(1, 10, acc), true},
+		{"duplicate guardian", recoveryRegistration(1, 10, g1, g1), true},
+		{"threshold above guardians", recoveryRegistration(2, 10, g1), true},
+		{"zero threshold", recoveryRegistration(0, 10, g1), true},
+		{"zero delay", recoveryRegistration(1, 0, g1), true},
+		{"delay too long", recoveryRegistration(1, common.MaxTransactionDelay+1, g1), true},
+		{"threshold without guardians", recoveryRegistration(1, 10), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := registerRecovery(account.Recovery{}, acc[:], tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
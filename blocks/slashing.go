@@ -0,0 +1,219 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/pubkeys"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/wallet"
+)
+
+// Double signing evidence is transaction of amount 0 sent to delegated account of offending operator
+// with OptData 'E' header1 with len, header2 with len. Both headers have to be signed by the same operator
+// of delegated account at the same height and differ in hash. Stakers of delegated account lose
+// common.SlashingPerMille of their stake, slashed coins go to common.SlashingTreasury. When treasury is not
+// set they are burned to zero address, which keeps them in supply of accounts but nobody can spend them.
+const DoubleSignEvidenceMarker = byte('E')
+
+// DoubleSignEvidenceOptData builds OptData of evidence transaction from two conflicting headers
+func DoubleSignEvidenceOptData(h1, h2 BaseHeader) []byte {
+	b := []byte{DoubleSignEvidenceMarker}
+	b = append(b, common.BytesToLenAndBytes(h1.GetBytes())...)
+	return append(b, common.BytesToLenAndBytes(h2.GetBytes())...)
+}
+
+func IsDoubleSignEvidence(tx transactionsDefinition.Transaction) bool {
+	return tx.TxData.Amount == 0 && tx.GetLockedAmount() == 0 &&
+		len(tx.TxData.OptData) > 0 && tx.TxData.OptData[0] == DoubleSignEvidenceMarker
+}
+
+func parseDoubleSignEvidence(opt []byte) (BaseHeader, BaseHeader, error) {
+	h1, h2 := BaseHeader{}, BaseHeader{}
+	if len(opt) == 0 || opt[0] != DoubleSignEvidenceMarker {
+		return h1, h2, fmt.Errorf("no double signing evidence: parseDoubleSignEvidence")
+	}
+	hb1, left, err := common.BytesWithLenToBytes(opt[1:])
+	if err != nil {
+		return h1, h2, err
+	}
+	hb2, left, err := common.BytesWithLenToBytes(left)
+	if err != nil {
+		return h1, h2, err
+	}
+	if len(left) > 0 {
+		return h1, h2, fmt.Errorf("too many bytes in evidence: parseDoubleSignEvidence")
+	}
+	_, err = h1.GetFromBytes(hb1)
+	if err != nil {
+		return h1, h2, err
+	}
+	_, err = h2.GetFromBytes(hb2)
+	if err != nil {
+		return h1, h2, err
+	}
+	return h1, h2, nil
+}
+
+// verifyEvidenceHeader checks signature of header with pubkey of operator valid at height of header.
+// Type of pubkey is chosen by signature itself, so result does not depend on pubkey which node
+// would choose for signing at that height.
+func verifyEvidenceHeader(bh BaseHeader) bool {
+	msg := bh.GetBytesWithoutSignature()
+	if !bytes.Equal(msg, bh.SignatureMessage) {
+		return false
+	}
+	calcHash, err := common.CalcHashToByte(msg)
+	if err != nil {
+		return false
+	}
+	sig := bh.Signature.GetBytes()
+	if len(sig) == 0 {
+		return false
+	}
+	pk, err := pubkeys.LoadPubKeyAtHeight(bh.OperatorAccount, sig[0] == 0, bh.Height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+		return false
+	}
+	return wallet.Verify(calcHash, sig, pk.GetBytes())
+}
+
+// evidenceWindow is number of blocks in which double signing can be punished. Stake which waits
+// for release is still slashed, without unbonding evidence is accepted within MaxTransactionDelay.
+func evidenceWindow(height int64) int64 {
+	if delay := common.GetUnbondingDelay(height); delay > 0 {
+		return delay
+	}
+	return common.MaxTransactionDelay
+}
+
+// doubleSignEvidenceKey identifies offence of delegated account n, so the same offence is punished once
+func doubleSignEvidenceKey(opt []byte, n int) (string, error) {
+	h1, _, err := parseDoubleSignEvidence(opt)
+	if err != nil {
+		return "", err
+	}
+	return string(evidenceKey(common.GetDelegatedAccountAddress(int16(n)), h1.Height)), nil
+}
+
+// CheckDoubleSignEvidence validates evidence against delegated account n at height
+func CheckDoubleSignEvidence(opt []byte, n int, height int64) error {
+	h1, h2, err := parseDoubleSignEvidence(opt)
+	if err != nil {
+		return err
+	}
+	if h1.Height != h2.Height || h1.Height > height || h1.Height <= height-evidenceWindow(height) {
+		return fmt.Errorf("headers have to be at the same height within unbonding delay: CheckDoubleSignEvidence")
+	}
+	delegated := common.GetDelegatedAccountAddress(int16(n))
+	if !bytes.Equal(h1.DelegatedAccount.GetBytes(), delegated.GetBytes()) ||
+		!bytes.Equal(h2.DelegatedAccount.GetBytes(), delegated.GetBytes()) {
+		return fmt.Errorf("headers are not signed for delegated account: CheckDoubleSignEvidence")
+	}
+	if !bytes.Equal(h1.OperatorAccount.GetBytes(), h2.OperatorAccount.GetBytes()) {
+		return fmt.Errorf("headers are signed by different operators: CheckDoubleSignEvidence")
+	}
+	// only operator of delegated account can cause slashing of its stakers
+	operator := account.GetStakingAccountByAddressBytes(h1.OperatorAccount.GetBytes(), n)
+	if !bytes.Equal(operator.Address[:], h1.OperatorAccount.GetBytes()) || !operator.OperationalAccount {
+		return fmt.Errorf("signer is not operator of delegated account: CheckDoubleSignEvidence")
+	}
+	if bytes.Equal(h1.SignatureMessage, h2.SignatureMessage) {
+		return fmt.Errorf("headers have the same hash: CheckDoubleSignEvidence")
+	}
+	if IsEvidenceUsed(delegated, h1.Height) {
+		return fmt.Errorf("delegated account was already slashed for height %v: CheckDoubleSignEvidence", h1.Height)
+	}
+	if !verifyEvidenceHeader(h1) || !verifyEvidenceHeader(h2) {
+		return fmt.Errorf("wrong signature of header: CheckDoubleSignEvidence")
+	}
+	return nil
+}
+
+// ProcessDoubleSignEvidence slashes stakers of delegated account n, sender pays fee
+func ProcessDoubleSignEvidence(tx transactionsDefinition.Transaction, height int64, n int) error {
+	opt := tx.TxData.OptData
+	err := CheckDoubleSignEvidence(opt, n, height)
+	if err != nil {
+		return err
+	}
+	h1, _, err := parseDoubleSignEvidence(opt)
+	if err != nil {
+		return err
+	}
+	slashed := account.Slash(n, common.SlashingPerMille, height)
+	err = creditSlashed(slashed)
+	if err != nil {
+		return err
+	}
+	err = storeEvidence(h1.DelegatedAccount, h1.Height, height)
+	if err != nil {
+		return err
+	}
+	logger.GetLogger().Println("delegated account", n, "slashed", slashed, "for double signing at height", h1.Height)
+	return AddBalance(tx.TxParam.Sender.ByteValue, -tx.GasPrice*tx.GasUsage)
+}
+
+// creditSlashed moves slashed coins to common.SlashingTreasury, zero address when not set,
+// so sum of balances, stakes and unbonding amounts stays equal to block supply
+func creditSlashed(slashed int64) error {
+	if slashed <= 0 {
+		return nil
+	}
+	return AddBalance(common.SlashingTreasury.ByteValue, slashed)
+}
+
+// ReleaseUnbonded credits unstaked coins which waited UnbondingDelay
+func ReleaseUnbonded(height int64) {
+	for address, amount := range account.ReleaseUnbonding(height) {
+		err := AddBalance(address, amount)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+}
+
+func evidenceKey(delegated common.Address, offenceHeight int64) []byte {
+	return append(delegated.GetBytes(), common.GetByteInt64(offenceHeight)...)
+}
+
+func IsEvidenceUsed(delegated common.Address, offenceHeight int64) bool {
+	isKey, err := database.MainDB.IsKey(append(common.EvidenceDBPrefix[:], evidenceKey(delegated, offenceHeight)...))
+	return err == nil && isKey
+}
+
+func storeEvidence(delegated common.Address, offenceHeight int64, height int64) error {
+	k := evidenceKey(delegated, offenceHeight)
+	err := database.MainDB.Put(append(common.EvidenceDBPrefix[:], k...), common.GetByteInt64(height))
+	if err != nil {
+		return err
+	}
+	key := append(common.EvidenceByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	keys, err := database.MainDB.Get(key)
+	if err != nil {
+		keys = []byte{}
+	}
+	return database.MainDB.Put(key, append(keys, k...))
+}
+
+// RemoveEvidenceFromDB forgets evidences used at height, used when chain is reset
+func RemoveEvidenceFromDB(height int64) error {
+	key := append(common.EvidenceByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	keys, err := database.MainDB.Get(key)
+	if err != nil || len(keys) == 0 {
+		return nil
+	}
+	l := common.AddressLength + 8
+	for i := 0; i+l <= len(keys); i += l {
+		err = database.MainDB.Delete(append(common.EvidenceDBPrefix[:], keys[i:i+l]...))
+		if err != nil {
+			return err
+		}
+	}
+	return database.MainDB.Delete(key)
+}
@@ -0,0 +1,67 @@
+package blocks
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+)
+
+func supplyInState() int64 {
+	staked, rewarded := GetSupplyInStakedAccounts()
+	return GetSupplyInAccounts() + staked + rewarded
+}
+
+func TestSupplyInvariant_UnstakeSlashRelease(t *testing.T) {
+	n := 5
+	staker := [common.AddressLength]byte{1}
+	defer func(accounts map[[common.AddressLength]byte]account.Account, treasury common.Address) {
+		account.Accounts.AllAccounts = accounts
+		account.StakingAccounts[n].AllStakingAccounts = nil
+		common.SlashingTreasury = treasury
+	}(account.Accounts.AllAccounts, common.SlashingTreasury)
+
+	for _, treasury := range []common.Address{{}, {ByteValue: [common.AddressLength]byte{9}}} {
+		common.SlashingTreasury = treasury
+		account.Accounts.AllAccounts = map[[common.AddressLength]byte]account.Account{
+			staker: {Address: staker, Balance: 500},
+		}
+		account.StakingAccounts[n].AllStakingAccounts = map[[common.AddressLength]byte]account.StakingAccount{
+			staker: {Address: staker, StakedBalance: 1000, StakingRewards: 30},
+		}
+		supply := supplyInState()
+
+		// unstake with unbonding delay, as in processStakingTransfer
+		if err := account.Unstake(staker[:], -400, 10, n); err != nil {
+			t.Fatal(err)
+		}
+		if err := account.AddUnbonding(staker[:], 400, 20, n); err != nil {
+			t.Fatal(err)
+		}
+		if got := supplyInState(); got != supply {
+			t.Fatalf("Expected supply %v after unstake, got %v", supply, got)
+		}
+
+		slashed := account.Slash(n, 100, 11)
+		if slashed != 100 {
+			t.Fatalf("Expected 100 slashed from stake and unbonding, got %v", slashed)
+		}
+		if err := creditSlashed(slashed); err != nil {
+			t.Fatal(err)
+		}
+		if got := supplyInState(); got != supply {
+			t.Fatalf("Expected supply %v after slashing, got %v", supply, got)
+		}
+		if got := account.GetBalance(treasury.ByteValue); got != slashed {
+			t.Errorf("Expected %v slashed coins on %x, got %v", slashed, treasury.ByteValue, got)
+		}
+
+		ReleaseUnbonded(20)
+		if got := supplyInState(); got != supply {
+			t.Fatalf("Expected supply %v after release, got %v", supply, got)
+		}
+		if got := account.GetBalance(staker); got != 500+360 {
+			t.Errorf("Expected released unbonding credited, got balance %v", got)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/oracles"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+// Operator manages validator of delegated account with transactions of amount 0 sent to it. OptData:
+// 'M' commission(2) name with len, url with len - sets metadata and commission in per mille,
+// 'U' - releases validator from jail after JailBlocks.
+const (
+	ValidatorMetadataMarker = byte('M')
+	ValidatorUnjailMarker   = byte('U')
+	maxValidatorNameLength  = 64
+	maxValidatorURLLength   = 256
+	maxCommission           = 500
+)
+
+func IsValidatorOperation(tx transactionsDefinition.Transaction) bool {
+	if tx.TxData.Amount != 0 || tx.GetLockedAmount() != 0 || len(tx.TxData.OptData) == 0 {
+		return false
+	}
+	return tx.TxData.OptData[0] == ValidatorMetadataMarker || tx.TxData.OptData[0] == ValidatorUnjailMarker
+}
+
+// CheckValidatorOperation checks operation of operator on validator of delegated account n
+func CheckValidatorOperation(sender []byte, opt []byte, n int, height int64) (account.Validator, error) {
+	_, _, operator := account.GetStakedInDelegatedAccount(n)
+	if !bytes.Equal(operator.Address[:], sender) {
+		return account.Validator{}, fmt.Errorf("sender is not operator of delegated account: CheckValidatorOperation")
+	}
+	v, ok := account.GetValidator(n)
+	if !ok {
+		v = account.Validator{DelegatedAccount: n}
+	}
+	switch opt[0] {
+	case ValidatorMetadataMarker:
+		if len(opt) < 3 {
+			return v, fmt.Errorf("wrong validator metadata: CheckValidatorOperation")
+		}
+		commission := common.GetInt16FromByte(opt[1:3])
+		name, left, err := common.BytesWithLenToBytes(opt[3:])
+		if err != nil {
+			return v, err
+		}
+		url, _, err := common.BytesWithLenToBytes(left)
+		if err != nil {
+			return v, err
+		}
+		if len(name) > maxValidatorNameLength || len(url) > maxValidatorURLLength {
+			return v, fmt.Errorf("validator name or url too long: CheckValidatorOperation")
+		}
+		if commission < 0 || commission > maxCommission {
+			return v, fmt.Errorf("commission has to be between 0 and %v: CheckValidatorOperation", maxCommission)
+		}
+		if v.Registered && commission != v.Commission {
+			if last := v.LastCommissionChange(); last > 0 && height-last < common.CommissionChangeDelay {
+				return v, fmt.Errorf("commission can be changed after height %v: CheckValidatorOperation", last+common.CommissionChangeDelay)
+			}
+			diff := commission - v.Commission
+			if diff > common.MaxCommissionChange || -diff > common.MaxCommissionChange {
+				return v, fmt.Errorf("commission can change by at most %v: CheckValidatorOperation", common.MaxCommissionChange)
+			}
+		}
+		if !v.Registered || commission != v.Commission {
+			v.Commission = commission
+			v.CommissionChanges = append(v.CommissionChanges, account.CommissionChange{Height: height, Commission: commission})
+		}
+		v.Name = string(name)
+		v.URL = string(url)
+		v.Registered = true
+	case ValidatorUnjailMarker:
+		if !v.Jailed {
+			return v, fmt.Errorf("validator is not jailed: CheckValidatorOperation")
+		}
+		if height < v.JailedUntil {
+			return v, fmt.Errorf("validator is jailed until height %v: CheckValidatorOperation", v.JailedUntil)
+		}
+		v.Jailed = false
+		v.JailedUntil = 0
+		v.MissedInRow = 0
+	default:
+		return v, fmt.Errorf("unknown validator operation: CheckValidatorOperation")
+	}
+	copy(v.Operator[:], sender)
+	return v, nil
+}
+
+func ProcessValidatorOperation(tx transactionsDefinition.Transaction, height int64, n int) error {
+	v, err := CheckValidatorOperation(tx.TxParam.Sender.GetBytes(), tx.TxData.OptData, n, height)
+	if err != nil {
+		return err
+	}
+	account.SetValidator(v)
+	return AddBalance(tx.TxParam.Sender.ByteValue, -tx.GasPrice*tx.GasUsage)
+}
+
+// CheckValidatorOfBlock rejects blocks of jailed validators and with commission other than registered,
+// from common.ValidatorJailingHeight on
+func CheckValidatorOfBlock(block Block) error {
+	if !isJailingActive(block.GetHeader().Height) {
+		return nil
+	}
+	n, err := account.IntDelegatedAccountFromAddress(block.BaseBlock.BaseHeader.DelegatedAccount)
+	if err != nil || n < 1 || n > 255 {
+		return fmt.Errorf("wrong delegated account in block: CheckValidatorOfBlock")
+	}
+	v, ok := account.GetValidator(n)
+	if !ok {
+		return nil
+	}
+	if v.Jailed {
+		return fmt.Errorf("validator of delegated account %v is jailed: CheckValidatorOfBlock", n)
+	}
+	if v.Registered && block.GetRewardPercentage() != v.Commission {
+		return fmt.Errorf("reward percentage differs from registered commission: CheckValidatorOfBlock")
+	}
+	return nil
+}
+
+func isJailingActive(height int64) bool {
+	return common.ValidatorJailingHeight > 0 && height >= common.ValidatorJailingHeight
+}
+
+// UpdateValidatorsUptime counts rounds of producer of block and validators expected to send nonces,
+// which are validators present in oracles data of block and validators known from previous rounds.
+// Validator which does not open its committed rand in time misses round. From common.ValidatorJailingHeight
+// on validator missing MaxMissedRounds in row is jailed.
+func UpdateValidatorsUptime(block Block, producer int) {
+	height := block.GetHeader().Height
+	participants := map[uint8]bool{}
+	prices, _, _, err := oracles.ParsePriceData(block.BaseBlock.PriceOracleData)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	for id := range prices {
+		participants[id] = true
+	}
+	// validators without fresh price still send rand in nonce
+	rands, _, _, err := oracles.ParseRandData(block.BaseBlock.RandOracleData, height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	for id := range rands {
+		participants[id] = true
+	}
+	// commit not opened within common.RandRevealWindow, withheld or wrong reveal, is penalized as missed round
+	missedReveal := map[uint8]bool{}
+	for _, id := range unopenedRandCommits(block) {
+		missedReveal[id] = true
+	}
+	for _, n := range expectedValidators(producer, participants) {
+		_, staked, operator := account.GetStakedInDelegatedAccount(n)
+		if int64(staked) < common.MinStakingForNode && n != producer {
+			continue
+		}
+		v, ok := account.GetValidator(n)
+		if !ok {
+			v = account.Validator{DelegatedAccount: n, Operator: operator.Address}
+		}
+		// without oracle data there is no information about participation
+		v = countValidatorRound(v, n == producer, participants[uint8(n)], missedReveal[uint8(n)], len(participants) > 0, height)
+		account.SetValidator(v)
+	}
+}
+
+// unopenedRandCommits returns validators whose commit from block common.RandRevealWindow below block
+// was not opened till block
+func unopenedRandCommits(block Block) []uint8 {
+	height := block.GetHeader().Height
+	commitHeight := height - common.RandRevealWindow
+	if commitHeight < 0 {
+		return nil
+	}
+	commitBlock, err := LoadBlock(commitHeight)
+	if err != nil {
+		return nil
+	}
+	later := [][]byte{}
+	for h := commitHeight + 1; h < height; h++ {
+		b, err := LoadBlock(h)
+		if err != nil {
+			return nil
+		}
+		later = append(later, b.BaseBlock.RandOracleData)
+	}
+	later = append(later, block.BaseBlock.RandOracleData)
+	return oracles.UnopenedCommits(commitBlock.BaseBlock.RandOracleData, commitHeight, later)
+}
+
+// expectedValidators returns producer, participants of round and validators known from previous rounds
+// in order of delegated accounts
+func expectedValidators(producer int, participants map[uint8]bool) []int {
+	ids := map[int]bool{}
+	if producer > 0 && producer < 256 {
+		ids[producer] = true
+	}
+	for id := range participants {
+		if id > 0 {
+			ids[int(id)] = true
+		}
+	}
+	for _, v := range account.GetAllValidators() {
+		ids[v.DelegatedAccount] = true
+	}
+	ns := make([]int, 0, len(ids))
+	for n := range ids {
+		ns = append(ns, n)
+	}
+	sort.Ints(ns)
+	return ns
+}
+
+// countValidatorRound updates uptime of validator v with round of block at height
+func countValidatorRound(v account.Validator, produced bool, participated bool, missedReveal bool, roundKnown bool, height int64) account.Validator {
+	if produced {
+		v.BlocksProduced++
+	}
+	if missedReveal {
+		v.MissedReveals++
+	}
+	if !roundKnown || v.Jailed {
+		return v
+	}
+	v.RoundsExpected++
+	if (participated || produced) && !missedReveal {
+		v.RoundsParticipated++
+		v.MissedInRow = 0
+	} else {
+		v.MissedInRow++
+	}
+	if v.MissedInRow >= common.MaxMissedRounds && isJailingActive(height) {
+		v.Jailed = true
+		v.JailedUntil = height + common.JailBlocks
+		logger.GetLogger().Println("validator of delegated account", v.DelegatedAccount, "jailed until", v.JailedUntil)
+	}
+	return v
+}
@@ -0,0 +1,147 @@
+package blocks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+)
+
+func TestCountValidatorRound(t *testing.T) {
+	defer func(rounds, height int64) {
+		common.MaxMissedRounds, common.ValidatorJailingHeight = rounds, height
+	}(common.MaxMissedRounds, common.ValidatorJailingHeight)
+	common.MaxMissedRounds = 3
+	common.ValidatorJailingHeight = 100
+
+	tests := []struct {
+		name         string
+		v            account.Validator
+		produced     bool
+		participated bool
+		missedReveal bool
+		roundKnown   bool
+		height       int64
+		want         account.Validator
+	}{
+		{"participation resets missed rounds",
+			account.Validator{MissedInRow: 2}, false, true, false, true, 100,
+			account.Validator{RoundsExpected: 1, RoundsParticipated: 1}},
+		{"producer participates",
+			account.Validator{}, true, false, false, true, 100,
+			account.Validator{BlocksProduced: 1, RoundsExpected: 1, RoundsParticipated: 1}},
+		{"missed reveal is missed round",
+			account.Validator{}, false, true, true, true, 100,
+			account.Validator{MissedReveals: 1, RoundsExpected: 1, MissedInRow: 1}},
+		{"round without oracle data is not counted",
+			account.Validator{MissedInRow: 2}, false, false, false, false, 100,
+			account.Validator{MissedInRow: 2}},
+		{"jailed after missed rounds",
+			account.Validator{MissedInRow: 2}, false, false, false, true, 100,
+			account.Validator{RoundsExpected: 1, MissedInRow: 3, Jailed: true, JailedUntil: 100 + common.JailBlocks}},
+		{"not jailed before activation height",
+			account.Validator{MissedInRow: 2}, false, false, false, true, 99,
+			account.Validator{RoundsExpected: 1, MissedInRow: 3}},
+		{"jailed validator is not counted",
+			account.Validator{Jailed: true, JailedUntil: 500}, false, false, false, true, 100,
+			account.Validator{Jailed: true, JailedUntil: 500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := countValidatorRound(tt.v, tt.produced, tt.participated, tt.missedReveal, tt.roundKnown, tt.height)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExpectedValidators(t *testing.T) {
+	account.ClearValidators()
+	defer account.ClearValidators()
+	account.SetValidator(account.Validator{DelegatedAccount: 7})
+	got := expectedValidators(5, map[uint8]bool{3: true, 5: true})
+	if want := []int{3, 5, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestCheckValidatorOperation_Unjail(t *testing.T) {
+	n := 5
+	operator := [common.AddressLength]byte{1}
+	other := [common.AddressLength]byte{2}
+	account.StakingAccounts[n].AllStakingAccounts = map[[common.AddressLength]byte]account.StakingAccount{
+		operator: {Address: operator, StakedBalance: common.MinStakingForNode, OperationalAccount: true},
+	}
+	defer func() {
+		account.StakingAccounts[n].AllStakingAccounts = nil
+		account.ClearValidators()
+	}()
+	unjail := []byte{ValidatorUnjailMarker}
+	tests := []struct {
+		name    string
+		jailed  bool
+		sender  [common.AddressLength]byte
+		height  int64
+		wantErr bool
+	}{
+		{"unjailed after jail time", true, operator, 200, false},
+		{"still in jail", true, operator, 199, true},
+		{"not operator", true, other, 200, true},
+		{"not jailed", false, operator, 200, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account.ClearValidators()
+			v := account.Validator{DelegatedAccount: n, Operator: operator}
+			if tt.jailed {
+				v.Jailed, v.JailedUntil, v.MissedInRow = true, 200, common.MaxMissedRounds
+			}
+			account.SetValidator(v)
+			got, err := CheckValidatorOperation(tt.sender[:], unjail, n, tt.height)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (got.Jailed || got.JailedUntil != 0 || got.MissedInRow != 0) {
+				t.Errorf("Expected validator released from jail, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestCheckValidatorOfBlock(t *testing.T) {
+	defer func(height int64) { common.ValidatorJailingHeight = height }(common.ValidatorJailingHeight)
+	common.ValidatorJailingHeight = 100
+	n := 6
+	defer account.ClearValidators()
+	tests := []struct {
+		name       string
+		v          account.Validator
+		commission int16
+		height     int64
+		wantErr    bool
+	}{
+		{"jailed validator", account.Validator{DelegatedAccount: n, Jailed: true}, 0, 100, true},
+		{"jailed before activation height", account.Validator{DelegatedAccount: n, Jailed: true}, 0, 99, false},
+		{"registered commission", account.Validator{DelegatedAccount: n, Registered: true, Commission: 100}, 100, 100, false},
+		{"other commission", account.Validator{DelegatedAccount: n, Registered: true, Commission: 100}, 50, 100, true},
+		{"unregistered validator", account.Validator{DelegatedAccount: n}, 50, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account.ClearValidators()
+			account.SetValidator(tt.v)
+			block := Block{BaseBlock: BaseBlock{
+				BaseHeader: BaseHeader{
+					Height:           tt.height,
+					DelegatedAccount: common.GetDelegatedAccountAddress(int16(n)),
+				},
+				RewardPercentage: tt.commission,
+			}}
+			if err := CheckValidatorOfBlock(block); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package qtwidgets
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"github.com/okuralabs/okura-node/blocks"
 	"github.com/okuralabs/okura-node/common"
 	clientrpc "github.com/okuralabs/okura-node/rpc/client"
 	"github.com/okuralabs/okura-node/services/transactionServices"
@@ -32,6 +35,9 @@ func ShowEscrowPage() *widgets.QTabWidget {
 	delayEscrow1 := widgets.NewQLineEdit(nil)
 	delayEscrow1.SetPlaceholderText("Primary account to set Escrow: set delay transaction in blocks number > 0 (default 0)")
 	widget.Layout().AddWidget(delayEscrow1)
+	guardianEscrow1 := widgets.NewQLineEdit(nil)
+	guardianEscrow1.SetPlaceholderText("Primary account to set Escrow: guardian address who can cancel delayed transactions (default empty)")
+	widget.Layout().AddWidget(guardianEscrow1)
 	numMulti1 := widgets.NewQLineEdit(nil)
 	numMulti1.SetPlaceholderText("Primary account to set MultiSignature: set number of Approvals > 0 (default 0)")
 	widget.Layout().AddWidget(numMulti1)
@@ -117,6 +123,21 @@ func ShowEscrowPage() *widgets.QTabWidget {
 			info = &v
 			return
 		}
+		guardian := [common.AddressLength]byte{}
+		if guardianEscrow1.Text() != "" {
+			if escrowDelay <= 0 {
+				v = fmt.Sprint("guardian can be set only for escrow account")
+				info = &v
+				return
+			}
+			gb, err := hex.DecodeString(strings.Trim(guardianEscrow1.Text(), " "))
+			if err != nil || len(gb) != common.AddressLength {
+				v = fmt.Sprint("guardian address must be of length 20")
+				info = &v
+				return
+			}
+			copy(guardian[:], gb)
+		}
 
 		txd := transactionsDefinition.TxData{
 			Recipient:               MainWallet.MainAddress,
@@ -124,6 +145,7 @@ func ShowEscrowPage() *widgets.QTabWidget {
 			OptData:                 []byte{},
 			Pubkey:                  pk,
 			EscrowTransactionsDelay: escrowDelay,
+			EscrowGuardian:          guardian,
 			MultiSignNumber:         uint8(numMulti),
 			MultiSignAddresses:      multiAddresses_mod,
 		}
@@ -181,6 +203,91 @@ func ShowEscrowPage() *widgets.QTabWidget {
 	})
 	widget.Layout().AddWidget(buttonChangePrimary)
 
+	hashProposal := widgets.NewQLineEdit(nil)
+	hashProposal.SetPlaceholderText("Hash of multi signature proposal to approve or reject")
+	widget.Layout().AddWidget(hashProposal)
+	buttonApprove := widgets.NewQPushButton2("Approve proposal", nil)
+	buttonApprove.ConnectClicked(func(bool) {
+		v := sendMultiSignVote(hashProposal.Text(), true, pubkeyInclude.IsChecked(), primaryChb.IsChecked())
+		widgets.QMessageBox_Information(nil, "Info", v, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	})
+	widget.Layout().AddWidget(buttonApprove)
+	buttonReject := widgets.NewQPushButton2("Reject proposal", nil)
+	buttonReject.ConnectClicked(func(bool) {
+		v := sendMultiSignVote(hashProposal.Text(), false, pubkeyInclude.IsChecked(), primaryChb.IsChecked())
+		widgets.QMessageBox_Information(nil, "Info", v, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	})
+	widget.Layout().AddWidget(buttonReject)
+
+	pendingProposals := widgets.NewQTextEdit(nil)
+	pendingProposals.SetReadOnly(true)
+	buttonPending := widgets.NewQPushButton2("Show pending multi signature proposals", nil)
+	buttonPending.ConnectClicked(func(bool) {
+		if !MainWallet.Check() {
+			pendingProposals.SetText("Load wallet first")
+			return
+		}
+		clientrpc.InRPC <- SignMessage(append([]byte("MULTL"), MainWallet.MainAddress.GetBytes()...))
+		reply := <-clientrpc.OutRPC
+		proposals := []blocks.MultiSignProposal{}
+		err := json.Unmarshal(reply, &proposals)
+		if err != nil {
+			pendingProposals.SetText(string(reply))
+			return
+		}
+		t := ""
+		for _, p := range proposals {
+			t += "Hash: " + p.Hash.GetHex() + "\n"
+			t += "Account: " + p.Account.GetHex() + "\n"
+			t += "Recipient: " + p.Recipient.GetHex() + "\n"
+			t += "Amount: " + strconv.FormatInt(p.Amount, 10) + "\n"
+			t += "Approvals: " + strconv.Itoa(len(p.Approvals)) + "/" + strconv.Itoa(int(p.Required)) + "\n"
+			t += "Rejections: " + strconv.Itoa(len(p.Rejections)) + "\n"
+			t += "Expires at height: " + strconv.FormatInt(p.ExpireHeight, 10) + "\n\n"
+		}
+		if t == "" {
+			t = "No pending proposals"
+		}
+		pendingProposals.SetText(t)
+	})
+	widget.Layout().AddWidget(buttonPending)
+	widget.Layout().AddWidget(pendingProposals)
+
+	hashEscrowed := widgets.NewQLineEdit(nil)
+	hashEscrowed.SetPlaceholderText("Hash of escrowed transaction to cancel (as guardian or with secondary key)")
+	widget.Layout().AddWidget(hashEscrowed)
+	buttonCancelEscrow := widgets.NewQPushButton2("Cancel escrowed transaction", nil)
+	buttonCancelEscrow.ConnectClicked(func(bool) {
+		v := sendEscrowCancellation(hashEscrowed.Text(), pubkeyInclude.IsChecked())
+		widgets.QMessageBox_Information(nil, "Info", v, widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+	})
+	widget.Layout().AddWidget(buttonCancelEscrow)
+
+	pendingEscrow := widgets.NewQTextEdit(nil)
+	pendingEscrow.SetReadOnly(true)
+	buttonPendingEscrow := widgets.NewQPushButton2("Show pending escrowed transactions", nil)
+	buttonPendingEscrow.ConnectClicked(func(bool) {
+		transfers, err := getEscrowTransfers()
+		if err != nil {
+			pendingEscrow.SetText(err.Error())
+			return
+		}
+		t := ""
+		for _, et := range transfers {
+			t += "Hash: " + et.Hash.GetHex() + "\n"
+			t += "Account: " + et.Account.GetHex() + "\n"
+			t += "Recipient: " + et.Recipient.GetHex() + "\n"
+			t += "Amount: " + strconv.FormatInt(et.Amount, 10) + "\n"
+			t += "Matures at height: " + strconv.FormatInt(et.MatureHeight, 10) + "\n\n"
+		}
+		if t == "" {
+			t = "No pending escrowed transactions"
+		}
+		pendingEscrow.SetText(t)
+	})
+	widget.Layout().AddWidget(buttonPendingEscrow)
+	widget.Layout().AddWidget(pendingEscrow)
+
 	//delayEscrow2 := widgets.NewQLineEdit(nil)
 	//delayEscrow2.SetPlaceholderText("Secondary account to set Escrow: set delay transaction in blocks number > 0 (default 0)")
 	//widget.Layout().AddWidget(delayEscrow2)
@@ -334,3 +441,126 @@ func ShowEscrowPage() *widgets.QTabWidget {
 	//widget.Layout().AddWidget(buttonChangeSecondary)
 	return widget
 }
+
+// sendMultiSignVote sends approval or rejection of multi signature proposal. Approval is sent
+// to proposal recipient, rejection to multi signature account, both with amount 0. Rejection of
+// configuration change of multi signature account is sent to own address.
+func sendMultiSignVote(hashHex string, approve bool, includePubKey bool, primaryEnc bool) string {
+	if !MainWallet.Check() {
+		return "Load wallet first"
+	}
+	hb, err := hex.DecodeString(hashHex)
+	if err != nil || len(hb) != common.HashLength {
+		return "hash should be 32 bytes, so 64 letters in Hex format"
+	}
+	clientrpc.InRPC <- SignMessage(append([]byte("MULTS"), hb...))
+	reply := <-clientrpc.OutRPC
+	p := blocks.MultiSignProposal{}
+	err = json.Unmarshal(reply, &p)
+	if err != nil {
+		return string(reply)
+	}
+	if p.Status != blocks.MultiSignPending {
+		return "proposal is " + p.Status
+	}
+	recipient := p.Recipient
+	if !approve {
+		recipient = p.Account
+		if bytes.Equal(p.Recipient.GetBytes(), p.Account.GetBytes()) {
+			recipient = MainWallet.MainAddress
+		}
+	}
+	return sendConfirmingTx(p.Hash, recipient, includePubKey, primaryEnc)
+}
+
+func getEscrowTransfers() ([]blocks.EscrowTransfer, error) {
+	if !MainWallet.Check() {
+		return nil, fmt.Errorf("Load wallet first")
+	}
+	clientrpc.InRPC <- SignMessage(append([]byte("ESCRL"), MainWallet.MainAddress.GetBytes()...))
+	reply := <-clientrpc.OutRPC
+	transfers := []blocks.EscrowTransfer{}
+	err := json.Unmarshal(reply, &transfers)
+	if err != nil {
+		return nil, fmt.Errorf("%v", string(reply))
+	}
+	return transfers, nil
+}
+
+// sendEscrowCancellation cancels escrowed transaction, it is signed with secondary key
+// which is accepted both from escrow account and from guardian
+func sendEscrowCancellation(hashHex string, includePubKey bool) string {
+	hb, err := hex.DecodeString(hashHex)
+	if err != nil || len(hb) != common.HashLength {
+		return "hash should be 32 bytes, so 64 letters in Hex format"
+	}
+	transfers, err := getEscrowTransfers()
+	if err != nil {
+		return err.Error()
+	}
+	for _, et := range transfers {
+		if bytes.Equal(et.Hash.GetBytes(), hb) {
+			return sendConfirmingTx(et.Hash, et.Account, includePubKey, false)
+		}
+	}
+	return "no pending escrowed transaction with this hash for this wallet"
+}
+
+// sendConfirmingTx sends transaction with amount 0 pointing to other transaction in MultiSignTx
+func sendConfirmingTx(hash common.Hash, recipient common.Address, includePubKey bool, primaryEnc bool) string {
+	pk := common.PubKey{}
+	if includePubKey {
+		if primaryEnc {
+			pk = MainWallet.PublicKey
+		} else {
+			pk = MainWallet.PublicKey2
+		}
+	}
+	txd := transactionsDefinition.TxData{
+		Recipient: recipient,
+		Amount:    int64(0),
+		OptData:   []byte{},
+		Pubkey:    pk,
+	}
+	par := transactionsDefinition.TxParam{
+		ChainID:     ChainID,
+		Sender:      MainWallet.MainAddress,
+		SendingTime: common.GetCurrentTimeStampInSecond(),
+		Nonce:       int16(rand.Intn(0xffff)),
+		MultiSignTx: hash,
+	}
+	tx := transactionsDefinition.Transaction{
+		TxData:    txd,
+		TxParam:   par,
+		Hash:      common.Hash{},
+		Signature: common.Signature{},
+		Height:    0,
+		GasPrice:  int64(rand.Intn(0x0000000f)),
+		GasUsage:  0,
+	}
+	clientrpc.InRPC <- SignMessage([]byte("STAT"))
+	reply := <-clientrpc.OutRPC
+	sm := statistics.GetStatsManager()
+	st := sm.Stats
+	err := common.Unmarshal(reply, common.StatDBPrefix, &st)
+	if err != nil {
+		return fmt.Sprint("Can not unmarshal statistics: ", err)
+	}
+	tx.GasUsage = tx.GasUsageEstimate()
+	tx.Height = st.Height
+	err = tx.CalcHashAndSet()
+	if err != nil {
+		return fmt.Sprint("can not generate hash transaction: ", err)
+	}
+	err = tx.Sign(MainWallet, primaryEnc)
+	if err != nil {
+		return fmt.Sprint(err)
+	}
+	msg, err := transactionServices.GenerateTransactionMsg([]transactionsDefinition.Transaction{tx}, []byte("tx"), [2]byte{'T', 'T'})
+	if err != nil {
+		return fmt.Sprint(err)
+	}
+	clientrpc.InRPC <- SignMessage(append([]byte("TRAN"), msg.GetBytes()...))
+	<-clientrpc.OutRPC
+	return "Tx Hash: " + tx.Hash.GetHex()
+}
@@ -126,6 +126,34 @@ func main() {
 		account.StoreStakingAccounts(-1)
 	}()
 
+	// Load escrow and multisig pending pools
+	logger.GetLogger().Println("Loading pending escrow and multisig pools...")
+	err = transactionsPool.LoadPendingPools(-1)
+	if err != nil {
+		logger.GetLogger().Println("Failed to load pending pools:", err)
+	}
+
+	// Load guardians and pending rotations of social recovery
+	logger.GetLogger().Println("Loading recoveries...")
+	err = account.LoadRecoveries(-1)
+	if err != nil {
+		logger.GetLogger().Println("Failed to load recoveries:", err)
+	}
+
+	// Load validator registry with uptime and jailing
+	logger.GetLogger().Println("Loading validators...")
+	err = account.LoadValidators(-1)
+	if err != nil {
+		logger.GetLogger().Println("Failed to load validators:", err)
+	}
+
+	// Load governance proposals and passed parameter changes
+	logger.GetLogger().Println("Loading governance...")
+	err = account.LoadGovernance(-1)
+	if err != nil {
+		logger.GetLogger().Println("Failed to load governance:", err)
+	}
+
 	// Initialize state database
 	logger.GetLogger().Println("Initializing state database...")
 	blocks.InitStateDB()
@@ -133,6 +161,14 @@ func main() {
 	// Initialize genesis block
 	logger.GetLogger().Println("Initializing genesis block...")
 	genesis.InitGenesis()
+	hg, err := account.LastHeightStoredInGovernance()
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = account.ApplyGovernedParams(hg)
+	if err != nil {
+		logger.GetLogger().Println("Failed to apply governed parameters:", err)
+	}
 
 	// Initialize services
 	logger.GetLogger().Println("Initializing transaction service...")
@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strconv"
@@ -22,10 +23,15 @@ var (
 	DifficultyMultiplier           int32   = 10
 	BlockTimeInterval              float32 = 10 // 10 sec.
 	DifficultyChange               float32 = 10
+	DifficultyLWMAHeight           int64   = 0        // from this height difficulty is weighted average of DifficultyWindow blocks, 0 keeps step rule
+	RandCommitRevealHeight         int64   = 0        // from this height rand oracle is combined from secrets committed in previous block, 0 keeps random numbers
+	RandRevealWindow               int64   = 3        // blocks in which commit of rand oracle has to be opened before validator misses round
+	DifficultyWindow               int64   = 60       // blocks in window of weighted average of difficulty
 	MaxGasUsage                    int64   = 13700000 // circa 6.5k transactions in block
 	MaxGasPrice                    int64   = 100000
 	MaxTransactionsPerBlock        int16   = 5000 // on average 500 TPS
 	MaxTransactionInPool                   = 10000
+	MaxKnownTxHashesPerPeer                = 20000 // size of per peer cache of announced transaction hashes
 	MaxPeersConnected              int     = 6
 	NumberOfHashesInBucket         int64   = 20
 	NumberOfBlocksInBucket         int64   = 20
@@ -36,6 +42,24 @@ var (
 	VotingHeightDistance           int64   = 60           // 60 => ten minute on average
 	MaxTransactionDelay            int64   = 60480        // one week
 	MaxTransactionInMultiSigPool   int64   = 60480        //one week
+	UnbondingDelay                 int64   = 0            // unstaked coins are released after delay from UnbondingHeight on, 0 releases them at once
+	UnbondingHeight                int64   = 0            // height from which UnbondingDelay is applied
+	SlashingPerMille               int64   = 50           // part of stake slashed for double signing, 50 => 5%
+	SlashingTreasury               Address                // receives slashed coins, empty address burns them to zero address
+	ValidatorJailingHeight         int64   = 0            // from this height validators are jailed and blocks have to keep registered commission, 0 disables
+	AllTxPubKeysHeight             int64   = 0            // from this height pubkeys of all transactions in block are stored, before storing stops at first transaction without pubkey, 0 keeps old rule
+	MultiSignExpiryHeight          int64   = 0            // from this height multi signature proposals older than MaxTransactionInMultiSigPool expire, 0 disables
+	DelegatedEscrowHeight          int64   = 0            // from this height escrow delay and multi signature approval apply to staking and DEX operations, 0 disables
+	MultiSignVoteHeight            int64   = 0            // from this height votes on staking and DEX proposals only pay fee and matured escrow transactions are processed in order of height and hash, 0 keeps old rule
+	SocialRecoveryHeight           int64   = 0            // from this height matured social recoveries rotate pubkeys and guardians, 0 disables
+	GovernanceHeight               int64   = 0            // from this height governance proposals are tallied and passed changes activated, 0 disables
+	MaxMissedRounds                int64   = 360          // validator missing nonces for one hour is jailed
+	JailBlocks                     int64   = 8640         // one day in jail before operator can unjail
+	CommissionChangeDelay          int64   = 8640         // operator can change commission once a day
+	MaxCommissionChange            int16   = 50           // by at most 5 percentage points
+	MaxOracleHistoryRange          int64   = 8640         // blocks returned by one oracle history query
+	GovernanceVotingPeriod         int64   = 60480        // one week of voting on parameter change
+	GovernanceActivationDelay      int64   = 8640         // passed change is active at least one day after voting
 	ConnectionMaxTries                     = 10
 	BannedTimeSeconds              int64   = 60                  // 1 minute
 	MessageInitialization                  = [4]byte{2, 0, 2, 9} // will be overwrite in init() by MaxMessageSizeBytes
@@ -45,6 +69,22 @@ var (
 	DefaultLogsHomePath                    = "/.okura/logs/"
 	ConnectionsWithoutVerification         = [][]byte{[]byte("TRAN"), []byte("STAT"), []byte("ENCR"), []byte("DETS"), []byte("STAK"), []byte("ADEX")}
 	CurrentHeightOfNetwork         int64   = 23
+	PeerMaxBytesPerSecond          int64   = 16777216 // per peer and topic, RATE_LIMIT_BYTES_PER_SECOND in .env
+	PeerMaxMessagesPerSecond       int64   = 50       // per peer and message head, RATE_LIMIT_MESSAGES_PER_SECOND in .env
+	PeerMaxOutstandingRequests     int     = 4        // header requests waiting for answer, MAX_OUTSTANDING_REQUESTS in .env
+	PeerReceiveBufferSize          int     = 100      // received messages waiting for processing, RECEIVE_BUFFER_SIZE in .env
+	SyncMaxParallelWindows         int     = 8        // windows of NumberOfBlocksInBucket blocks downloaded at once, SYNC_PARALLEL_WINDOWS in .env
+	SyncRequestTimeoutSeconds      int64   = 10       // after timeout window is reassigned to other peer
+	MaxReorgDepth                  int64   = 100      // forks deeper below last block are not followed, MAX_REORG_DEPTH in .env
+	CheckpointInterval             int64   = 100      // hash of block at multiple of CheckpointInterval is attested by validators, CHECKPOINT_INTERVAL in .env
+	SnapshotInterval               int64   = 10000    // state snapshot is created every SnapshotInterval blocks, SNAPSHOT_INTERVAL in .env, 0 disables
+	SnapshotChunkSize              int     = 1048576  // maximal size of one chunk of state snapshot served to peers
+	SnapshotsKept                  int64   = 2        // number of last snapshots stored in DB
+	TrustedCheckpointHeight        int64   = 0        // TRUSTED_CHECKPOINT_HEIGHT in .env, 0 means sync from genesis
+	TrustedCheckpointHash          []byte             // TRUSTED_CHECKPOINT_HASH in .env, hex of snapshot manifest hash
+	PriceSources                   string             // PRICE_SOURCES in .env, e.g. http:URL|field,file:path,unix:path
+	PriceMaxAgeSeconds             int64   = 60       // older quotes of price sources are stale, PRICE_MAX_AGE_SECONDS in .env
+	PriceMinSources                int     = 1        // fresh quotes needed to propose price, PRICE_MIN_SOURCES in .env
 )
 
 // db prefixes
@@ -75,6 +115,34 @@ var (
 	OutputAddressesHashesDBPrefix    = [2]byte{'C', '0'}
 	TokenDetailsDBPrefix             = [2]byte{'T', 'D'}
 	DexAccountsDBPrefix              = [2]byte{'D', 'A'}
+	SnapshotManifestDBPrefix         = [2]byte{'S', 'N'}
+	SnapshotChunkDBPrefix            = [2]byte{'S', 'C'}
+	SnapshotBaseHeightDBPrefix       = [2]byte{'S', 'B'}
+	PendingEscrowPoolDBPrefix        = [2]byte{'E', 'P'}
+	PendingMultiSignPoolDBPrefix     = [2]byte{'M', 'P'}
+	MultiSignResultDBPrefix          = [2]byte{'M', 'R'}
+	MultiSignResultsByHeightDBPrefix = [2]byte{'M', 'H'}
+	AccountConfigDBPrefix            = [2]byte{'A', 'V'}
+	AccountConfigsByHeightDBPrefix   = [2]byte{'A', 'H'}
+	RecoveriesDBPrefix               = [2]byte{'G', 'R'}
+	RevokedPubKeyDBPrefix            = [2]byte{'R', 'V'}
+	RevokedPubKeysByHeightDBPrefix   = [2]byte{'R', 'W'}
+	EvidenceDBPrefix                 = [2]byte{'D', 'E'}
+	EvidenceByHeightDBPrefix         = [2]byte{'D', 'H'}
+	ValidatorsDBPrefix               = [2]byte{'V', 'A'}
+	OracleHistoryDBPrefix            = [2]byte{'O', 'H'}
+	GovernanceDBPrefix               = [2]byte{'G', 'V'}
+	EncryptionVotesDBPrefix          = [2]byte{'E', 'V'}
+	EncryptionChangesDBPrefix        = [2]byte{'E', 'C'}
+	CumulativeDifficultyDBPrefix     = [2]byte{'C', 'W'}
+	BranchBlocksDBPrefix             = [2]byte{'B', 'F'}
+	BranchBlocksByHeightDBPrefix     = [2]byte{'B', 'G'}
+	PubKeysByHeightDBPrefix          = [2]byte{'P', 'W'}
+	PubKeyAddedHeightDBPrefix        = [2]byte{'P', 'H'}
+	RandSeedDBPrefix                 = [2]byte{'R', 'S'}
+	CheckpointAttestationsDBPrefix   = [2]byte{'F', 'A'}
+	FinalizedCheckpointDBPrefix      = [2]byte{'F', 'C'}
+	LastFinalizedCheckpointDBKey     = [2]byte{'F', 'L'}
 )
 
 var chainID = int16(23)
@@ -82,8 +150,6 @@ var chainIDMutex = sync.Mutex{}
 var nodeSignPrimary = true
 var delegatedAccount Address
 var rewardPercentage int16
-var ShiftToPastInReset int64
-var ShiftToPastMutex sync.RWMutex
 
 func GetChainID() int16 {
 	chainIDMutex.Lock()
@@ -127,6 +193,14 @@ func GetNodeSignPrimary(height int64) bool {
 	return true
 }
 
+// GetUnbondingDelay returns delay of release of coins unstaked at height
+func GetUnbondingDelay(height int64) int64 {
+	if height < UnbondingHeight {
+		return 0
+	}
+	return UnbondingDelay
+}
+
 func GetDelegatedAccount() Address {
 	return delegatedAccount
 }
@@ -149,7 +223,6 @@ func init() {
 	SetEncryption(enc2.SigName, enc2.PubKeyLength, enc2.PrivateKeyLength, enc2.SignatureLength, enc2.IsPaused, false)
 
 	//log.SetOutput(io.Discard)
-	ShiftToPastInReset = 1
 	homePath, err := os.UserHomeDir()
 	if err != nil {
 		logger.GetLogger().Fatal(err)
@@ -178,4 +251,45 @@ func init() {
 		logger.GetLogger().Panicln("Warning no declaration of HEIGHT_OF_NETWORK")
 	}
 	CurrentHeightOfNetwork = int64(ch)
+
+	// optional limits of resources used by single peer
+	if v, err := strconv.ParseInt(os.Getenv("RATE_LIMIT_BYTES_PER_SECOND"), 10, 64); err == nil && v > 0 {
+		PeerMaxBytesPerSecond = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("RATE_LIMIT_MESSAGES_PER_SECOND"), 10, 64); err == nil && v > 0 {
+		PeerMaxMessagesPerSecond = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("MAX_OUTSTANDING_REQUESTS")); err == nil && v > 0 {
+		PeerMaxOutstandingRequests = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("RECEIVE_BUFFER_SIZE")); err == nil && v > 0 {
+		PeerReceiveBufferSize = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SYNC_PARALLEL_WINDOWS")); err == nil && v > 0 {
+		SyncMaxParallelWindows = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("MAX_REORG_DEPTH"), 10, 64); err == nil && v > 0 {
+		MaxReorgDepth = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("CHECKPOINT_INTERVAL"), 10, 64); err == nil && v > 0 {
+		CheckpointInterval = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("SNAPSHOT_INTERVAL"), 10, 64); err == nil && v >= 0 {
+		SnapshotInterval = v
+	}
+	PriceSources = os.Getenv("PRICE_SOURCES")
+	if v, err := strconv.ParseInt(os.Getenv("PRICE_MAX_AGE_SECONDS"), 10, 64); err == nil && v > 0 {
+		PriceMaxAgeSeconds = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PRICE_MIN_SOURCES")); err == nil && v > 0 {
+		PriceMinSources = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("TRUSTED_CHECKPOINT_HEIGHT"), 10, 64); err == nil && v > 0 {
+		hb, err := hex.DecodeString(os.Getenv("TRUSTED_CHECKPOINT_HASH"))
+		if err != nil || len(hb) != HashLength {
+			logger.GetLogger().Fatal("TRUSTED_CHECKPOINT_HASH has to be hex of 32 bytes when TRUSTED_CHECKPOINT_HEIGHT is set")
+		}
+		TrustedCheckpointHeight = v
+		TrustedCheckpointHash = hb
+	}
 }
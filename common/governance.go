@@ -0,0 +1,100 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GovernedParam is protocol parameter which can be changed by governance proposal. Change in safer
+// direction needs more than 1/3 of staked coins like pausing of encryption scheme, other changes
+// need more than 2/3 like replacing of scheme.
+type GovernedParam struct {
+	Name  string
+	Min   int64
+	Max   int64
+	Safer int // -1 when lowering is safer, 1 when raising is safer, 0 when both directions need 2/3
+	get   func() int64
+	set   func(int64)
+}
+
+// BlockTimeInterval is governed in milliseconds, any change of it retargets difficulty so it needs 2/3
+var governedParams = []GovernedParam{
+	{Name: "MaxGasUsage", Min: 1000000, Max: 1000000000, Safer: -1,
+		get: func() int64 { return MaxGasUsage }, set: func(v int64) { MaxGasUsage = v }},
+	{Name: "MaxGasPrice", Min: 1, Max: 100000000, Safer: 0,
+		get: func() int64 { return MaxGasPrice }, set: func(v int64) { MaxGasPrice = v }},
+	{Name: "MinStakingUser", Min: 1, Max: 100000000000000, Safer: 0,
+		get: func() int64 { return MinStakingUser }, set: func(v int64) { MinStakingUser = v }},
+	{Name: "BlockTimeInterval", Min: 1000, Max: 600000, Safer: 0,
+		get: func() int64 { return int64(BlockTimeInterval * 1000) }, set: func(v int64) { BlockTimeInterval = float32(v) / 1000 }},
+	{Name: "MaxTransactionsPerBlock", Min: 1, Max: 30000, Safer: -1,
+		get: func() int64 { return int64(MaxTransactionsPerBlock) }, set: func(v int64) { MaxTransactionsPerBlock = int16(v) }},
+}
+
+var governedDefaults = map[string]int64{}
+
+func GetGovernedParam(name string) (GovernedParam, bool) {
+	for _, p := range governedParams {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return GovernedParam{}, false
+}
+
+func (p GovernedParam) Value() int64 {
+	return p.get()
+}
+
+// QuorumThirds returns number of thirds of all staked coins which has to be exceeded by votes for value
+func (p GovernedParam) QuorumThirds(value int64) int64 {
+	if (p.Safer < 0 && value < p.get()) || (p.Safer > 0 && value > p.get()) {
+		return 1
+	}
+	return 2
+}
+
+// GovernedParamValues returns current values of all governed parameters
+func GovernedParamValues() map[string]int64 {
+	m := map[string]int64{}
+	for _, p := range governedParams {
+		m[p.Name] = p.get()
+	}
+	return m
+}
+
+// SaveGovernedDefaults remembers values set from genesis, governance changes are applied on top of them
+func SaveGovernedDefaults() {
+	for _, p := range governedParams {
+		governedDefaults[p.Name] = p.get()
+	}
+}
+
+// SetGovernedParams restores genesis values and applies changes in order
+func SetGovernedParams(changes []ParamChange) error {
+	for _, p := range governedParams {
+		if v, ok := governedDefaults[p.Name]; ok {
+			p.set(v)
+		}
+	}
+	ordered := append([]ParamChange{}, changes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].ActivationHeight < ordered[j].ActivationHeight
+	})
+	for _, c := range ordered {
+		p, ok := GetGovernedParam(c.Name)
+		if !ok {
+			return fmt.Errorf("unknown governed parameter %v: SetGovernedParams", c.Name)
+		}
+		p.set(c.Value)
+	}
+	return nil
+}
+
+// ParamChange is value of governed parameter valid from ActivationHeight
+type ParamChange struct {
+	Name             string `json:"name"`
+	Value            int64  `json:"value"`
+	ActivationHeight int64  `json:"activation_height"`
+	ProposalID       int64  `json:"proposal_id"`
+}
@@ -0,0 +1,163 @@
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/common/math"
+	"github.com/okuralabs/okura-node/params"
+)
+
+// OracleReader gives precompiled contracts access to oracles stored in blocks
+type OracleReader interface {
+	// OracleAt returns price oracle, rand oracle and time stamp of block at height
+	OracleAt(height uint64) (price int64, rand int64, timestamp int64, err error)
+}
+
+// Oracle precompiled contracts are at reserved addresses after standard precompiles.
+// Height and number of blocks are passed as 32 bytes big endian words, values are
+// returned as 32 bytes two's complement words. Empty input means current block.
+var (
+	OraclePriceAddress = common.BytesToVMAddress([]byte{1, 0})
+	OracleTWAPAddress  = common.BytesToVMAddress([]byte{1, 1})
+	OracleRandAddress  = common.BytesToVMAddress([]byte{1, 2})
+)
+
+var (
+	errOracleHeight    = errors.New("oracle height out of range")
+	errOracleTWAPRange = errors.New("oracle twap number of blocks out of range")
+	errOracleNoPrice   = errors.New("no oracle price in range")
+)
+
+func oraclePrecompile(addr common.Address, ctx BlockContext) (PrecompiledContract, bool) {
+	switch addr {
+	case OraclePriceAddress:
+		return &oraclePrice{ctx: ctx}, true
+	case OracleTWAPAddress:
+		return &oracleTWAP{ctx: ctx}, true
+	case OracleRandAddress:
+		return &oracleRand{ctx: ctx}, true
+	}
+	return nil, false
+}
+
+// oracleHeight reads height from input, current block when input is empty
+func oracleHeight(input []byte, ctx BlockContext) (uint64, error) {
+	current := ctx.BlockNumber.Uint64()
+	if len(input) == 0 {
+		return current, nil
+	}
+	h := new(big.Int).SetBytes(getData(input, 0, 32))
+	if !h.IsUint64() || h.Uint64() > current {
+		return 0, errOracleHeight
+	}
+	return h.Uint64(), nil
+}
+
+func oracleWord(v int64) []byte {
+	return math.U256Bytes(big.NewInt(v))
+}
+
+// oraclePrice returns price oracle at height
+type oraclePrice struct {
+	ctx BlockContext
+}
+
+func (c *oraclePrice) RequiredGas(input []byte) uint64 {
+	return params.OracleReadGas
+}
+
+func (c *oraclePrice) Run(input []byte) ([]byte, error) {
+	height, err := oracleHeight(input, c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	price, _, _, err := c.ctx.Oracle.OracleAt(height)
+	if err != nil {
+		return nil, err
+	}
+	return oracleWord(price), nil
+}
+
+// oracleRand returns rand oracle at height
+type oracleRand struct {
+	ctx BlockContext
+}
+
+func (c *oracleRand) RequiredGas(input []byte) uint64 {
+	return params.OracleReadGas
+}
+
+func (c *oracleRand) Run(input []byte) ([]byte, error) {
+	height, err := oracleHeight(input, c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, rand, _, err := c.ctx.Oracle.OracleAt(height)
+	if err != nil {
+		return nil, err
+	}
+	return oracleWord(rand), nil
+}
+
+// oracleTWAP returns price oracle weighted by time between blocks over last N blocks.
+// Blocks without established price are skipped.
+type oracleTWAP struct {
+	ctx BlockContext
+}
+
+func oracleTWAPBlocks(input []byte) (uint64, bool) {
+	n := new(big.Int).SetBytes(getData(input, 0, 32))
+	if !n.IsUint64() || n.Uint64() == 0 || n.Uint64() > params.OracleTWAPMaxBlocks {
+		return 0, false
+	}
+	return n.Uint64(), true
+}
+
+func (c *oracleTWAP) RequiredGas(input []byte) uint64 {
+	n, ok := oracleTWAPBlocks(input)
+	if !ok {
+		return params.OracleTWAPBaseGas
+	}
+	return params.OracleTWAPBaseGas + n*params.OracleTWAPPerBlockGas
+}
+
+func (c *oracleTWAP) Run(input []byte) ([]byte, error) {
+	n, ok := oracleTWAPBlocks(input)
+	current := c.ctx.BlockNumber.Uint64()
+	if !ok || n > current {
+		return nil, errOracleTWAPRange
+	}
+	_, _, prevTime, err := c.ctx.Oracle.OracleAt(current - n)
+	if err != nil {
+		return nil, err
+	}
+	weighted, sum := new(big.Int), new(big.Int)
+	var weights, count int64
+	for h := current - n + 1; h <= current; h++ {
+		price, _, ts, err := c.ctx.Oracle.OracleAt(h)
+		if err != nil {
+			return nil, err
+		}
+		dt := ts - prevTime
+		prevTime = ts
+		if price <= 0 {
+			continue
+		}
+		count++
+		sum.Add(sum, big.NewInt(price))
+		if dt > 0 {
+			weights += dt
+			weighted.Add(weighted, new(big.Int).Mul(big.NewInt(price), big.NewInt(dt)))
+		}
+	}
+	if count == 0 {
+		return nil, errOracleNoPrice
+	}
+	// blocks with the same time stamp fall back to plain average
+	if weights == 0 {
+		return oracleWord(sum.Div(sum, big.NewInt(count)).Int64()), nil
+	}
+	return oracleWord(weighted.Div(weighted, big.NewInt(weights)).Int64()), nil
+}
@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/common/math"
+	"github.com/okuralabs/okura-node/params"
+)
+
+type testOracle struct {
+	prices, rands, times []int64
+}
+
+func (o testOracle) OracleAt(height uint64) (int64, int64, int64, error) {
+	if height >= uint64(len(o.prices)) {
+		return 0, 0, 0, errors.New("no block")
+	}
+	return o.prices[height], o.rands[height], o.times[height], nil
+}
+
+func testOracleContext() BlockContext {
+	return BlockContext{
+		BlockNumber: big.NewInt(4),
+		Oracle: testOracle{
+			prices: []int64{0, 100, 200, 0, 400},
+			rands:  []int64{1, -2, 3, 4, 5},
+			times:  []int64{0, 10, 20, 30, 60},
+		},
+	}
+}
+
+func word(v int64) []byte {
+	return math.U256Bytes(big.NewInt(v))
+}
+
+func TestOraclePrecompiles(t *testing.T) {
+	ctx := testOracleContext()
+	tests := []struct {
+		addr  string
+		input []byte
+		exp   int64
+	}{
+		{"price current", nil, 400},
+		{"price at height", word(2), 200},
+		{"rand current", nil, 5},
+		{"rand at height", word(1), -2},
+		// (200*10 + 400*30) / 40
+		{"twap", word(3), 350},
+		// block 3 without price is skipped
+		{"twap one block", word(1), 400},
+	}
+	for _, tt := range tests {
+		addr := OraclePriceAddress
+		switch tt.addr[:4] {
+		case "rand":
+			addr = OracleRandAddress
+		case "twap":
+			addr = OracleTWAPAddress
+		}
+		p, ok := oraclePrecompile(addr, ctx)
+		if !ok {
+			t.Fatalf("%s: no precompile", tt.addr)
+		}
+		ret, _, err := RunPrecompiledContract(p, tt.input, 1000000)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.addr, err)
+		}
+		if got := new(big.Int).SetBytes(ret); math.S256(got).Int64() != tt.exp {
+			t.Errorf("%s: got %v, want %v", tt.addr, math.S256(got), tt.exp)
+		}
+	}
+}
+
+func TestOraclePrecompileErrors(t *testing.T) {
+	ctx := testOracleContext()
+	price, _ := oraclePrecompile(OraclePriceAddress, ctx)
+	if _, err := price.Run(word(5)); err != errOracleHeight {
+		t.Errorf("future height: got %v", err)
+	}
+	twap, _ := oraclePrecompile(OracleTWAPAddress, ctx)
+	if _, err := twap.Run(word(5)); err != errOracleTWAPRange {
+		t.Errorf("too many blocks: got %v", err)
+	}
+	if _, err := twap.Run(word(0)); err != errOracleTWAPRange {
+		t.Errorf("zero blocks: got %v", err)
+	}
+	if gas := twap.RequiredGas(word(3)); gas != params.OracleTWAPBaseGas+3*params.OracleTWAPPerBlockGas {
+		t.Errorf("twap gas: got %v", gas)
+	}
+}
+
+func TestEVMPrecompile_OracleAddresses(t *testing.T) {
+	evm := &EVM{Context: testOracleContext()}
+	for _, addr := range []common.Address{OraclePriceAddress, OracleTWAPAddress, OracleRandAddress} {
+		if _, ok := evm.precompile(addr); !ok {
+			t.Errorf("%v: oracle precompile not reachable", addr)
+		}
+	}
+	if _, ok := evm.precompile(common.BytesToVMAddress([]byte{1})); !ok {
+		t.Errorf("standard precompile not reachable")
+	}
+	if _, ok := evm.precompile(common.BytesToVMAddress([]byte{1, 3})); ok {
+		t.Errorf("unexpected precompile after oracle addresses")
+	}
+	evm.Context.Oracle = nil
+	if _, ok := evm.precompile(OraclePriceAddress); ok {
+		t.Errorf("oracle precompile without oracle reader")
+	}
+}
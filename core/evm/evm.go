@@ -54,6 +54,9 @@ func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
 		precompiles = PrecompiledContractsHomestead
 	}
 	p, ok := precompiles[addr]
+	if !ok && evm.Context.Oracle != nil {
+		p, ok = oraclePrecompile(addr, evm.Context)
+	}
 	return p, ok
 }
 
@@ -76,6 +79,9 @@ type BlockContext struct {
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
 	BaseFee     *big.Int       // Provides information for BASEFEE
 	Random      *common.Hash   // Provides information for RANDOM
+
+	// Oracle provides price and rand oracles of chain to oracle precompiled contracts
+	Oracle OracleReader
 }
 
 // TxContext provides the EVM with information about a transaction.
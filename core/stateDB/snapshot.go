@@ -0,0 +1,226 @@
+package stateDB
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+)
+
+type keyValue struct {
+	key   []byte
+	value []byte
+}
+
+func appendSection(b []byte, kvs []keyValue) []byte {
+	sort.Slice(kvs, func(i, j int) bool {
+		return bytes.Compare(kvs[i].key, kvs[j].key) < 0
+	})
+	b = append(b, common.GetByteInt32(int32(len(kvs)))...)
+	for _, kv := range kvs {
+		b = append(b, common.BytesToLenAndBytes(kv.key)...)
+		b = append(b, common.BytesToLenAndBytes(kv.value)...)
+	}
+	return b
+}
+
+func readSection(b []byte) ([]keyValue, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, fmt.Errorf("too short snapshot section")
+	}
+	n := common.GetInt32FromByte(b[:4])
+	b = b[4:]
+	kvs := []keyValue{}
+	var k, v []byte
+	var err error
+	for i := int32(0); i < n; i++ {
+		k, b, err = common.BytesWithLenToBytes(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, b, err = common.BytesWithLenToBytes(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		kvs = append(kvs, keyValue{key: k, value: v})
+	}
+	return kvs, b, nil
+}
+
+// GetSnapshotBytes returns deterministic encoding of contracts state. History of snapshots
+// used for reverting is not included.
+func (sa *StateAccount) GetSnapshotBytes() []byte {
+	b := []byte{}
+	kvs := []keyValue{}
+	for a, acc := range sa.Accounts {
+		kvs = append(kvs, keyValue{key: append([]byte{}, a[:]...), value: acc.Marshal()})
+	}
+	b = appendSection(b, kvs)
+
+	kvs = []keyValue{}
+	for a, c := range sa.Codes {
+		kvs = append(kvs, keyValue{key: append([]byte{}, a[:]...), value: c})
+	}
+	b = appendSection(b, kvs)
+
+	kvs = []keyValue{}
+	for a, h := range sa.CodeHashes {
+		kvs = append(kvs, keyValue{key: append([]byte{}, a[:]...), value: append([]byte{}, h[:]...)})
+	}
+	b = appendSection(b, kvs)
+
+	kvs = []keyValue{}
+	for a, n := range sa.Nonces {
+		kvs = append(kvs, keyValue{key: append([]byte{}, a[:]...), value: common.GetByteInt64(int64(n))})
+	}
+	b = appendSection(b, kvs)
+
+	kvs = []keyValue{}
+	for h, s := range sa.States {
+		kvs = append(kvs, keyValue{key: append([]byte{}, h[:]...), value: s})
+	}
+	b = appendSection(b, kvs)
+
+	kvs = []keyValue{}
+	for a, hs := range sa.StatesHashes {
+		for h, h2 := range hs {
+			k := append(append([]byte{}, a[:]...), h[:]...)
+			kvs = append(kvs, keyValue{key: k, value: append([]byte{}, h2[:]...)})
+		}
+	}
+	b = appendSection(b, kvs)
+
+	kvs = []keyValue{}
+	for a, bs := range sa.Balances {
+		for coin, v := range bs {
+			k := append(append([]byte{}, a[:]...), coin[:]...)
+			kvs = append(kvs, keyValue{key: k, value: common.GetByteInt64(v)})
+		}
+	}
+	b = appendSection(b, kvs)
+
+	kvs = []keyValue{}
+	for a, ti := range sa.Tokens {
+		v := common.BytesToLenAndBytes([]byte(ti.Name))
+		v = append(v, common.BytesToLenAndBytes([]byte(ti.Symbols))...)
+		v = append(v, ti.Decimals)
+		kvs = append(kvs, keyValue{key: append([]byte{}, a[:]...), value: v})
+	}
+	b = appendSection(b, kvs)
+	return b
+}
+
+// SetFromSnapshotBytes replaces contracts state with one encoded by GetSnapshotBytes
+func (sa *StateAccount) SetFromSnapshotBytes(b []byte) error {
+	ns := CreateStateDB()
+	var a [common.AddressLength]byte
+	var coin [common.AddressLength]byte
+	var h, h2 common.Hash
+
+	kvs, b, err := readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		acc := account.Account{}
+		if err := acc.Unmarshal(kv.value); err != nil {
+			return err
+		}
+		copy(a[:], kv.key)
+		ns.Accounts[a] = acc
+	}
+
+	kvs, b, err = readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		copy(a[:], kv.key)
+		ns.Codes[a] = kv.value
+	}
+
+	kvs, b, err = readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		copy(a[:], kv.key)
+		h.Set(kv.value)
+		ns.CodeHashes[a] = h
+	}
+
+	kvs, b, err = readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		copy(a[:], kv.key)
+		ns.Nonces[a] = uint64(common.GetInt64FromByte(kv.value))
+	}
+
+	kvs, b, err = readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		h.Set(kv.key)
+		ns.States[h] = kv.value
+	}
+
+	kvs, b, err = readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if len(kv.key) != common.AddressLength+common.HashLength {
+			return fmt.Errorf("wrong length of state key in snapshot")
+		}
+		copy(a[:], kv.key[:common.AddressLength])
+		h.Set(kv.key[common.AddressLength:])
+		h2.Set(kv.value)
+		if _, ok := ns.StatesHashes[a]; !ok {
+			ns.StatesHashes[a] = map[common.Hash]common.Hash{}
+		}
+		ns.StatesHashes[a][h] = h2
+	}
+
+	kvs, b, err = readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if len(kv.key) != 2*common.AddressLength {
+			return fmt.Errorf("wrong length of balance key in snapshot")
+		}
+		copy(a[:], kv.key[:common.AddressLength])
+		copy(coin[:], kv.key[common.AddressLength:])
+		if _, ok := ns.Balances[a]; !ok {
+			ns.Balances[a] = map[[common.AddressLength]byte]int64{}
+		}
+		ns.Balances[a][coin] = common.GetInt64FromByte(kv.value)
+	}
+
+	kvs, b, err = readSection(b)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		name, rest, err := common.BytesWithLenToBytes(kv.value)
+		if err != nil {
+			return err
+		}
+		symbols, rest, err := common.BytesWithLenToBytes(rest)
+		if err != nil || len(rest) != 1 {
+			return fmt.Errorf("wrong token info in snapshot")
+		}
+		copy(a[:], kv.key)
+		ns.Tokens[a] = TokenInfo{Name: string(name), Symbols: string(symbols), Decimals: rest[0]}
+	}
+	if len(b) > 0 {
+		return fmt.Errorf("too long contracts state snapshot")
+	}
+	*sa = ns
+	return nil
+}
@@ -1,2 +1,15 @@
 package database
 
+import "github.com/okuralabs/okura-node/common"
+
+// FirstStoredHeight returns height of restored state snapshot or 0 when node was synced from genesis
+func FirstStoredHeight() int64 {
+	if MainDB == nil {
+		return 0
+	}
+	b, err := MainDB.Get(common.SnapshotBaseHeightDBPrefix[:])
+	if err != nil || len(b) != 8 {
+		return 0
+	}
+	return common.GetInt64FromByte(b)
+}
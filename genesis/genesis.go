@@ -18,14 +18,23 @@ import (
 )
 
 type GenesisStaking struct {
-	Account            string `json:"account"`
-	Amount             int64  `json:"amount"`
-	LockedAmount       int64  `json:"locked_amount"`
-	ReleasedPerBlock   int64  `json:"released_per_block"`
-	DelegatedAccount   int16  `json:"delegated_account"`
-	OperationalAccount bool   `json:"operational_account"`
-	PubKey             string `json:"pub_key"`
-	PubKey2            string `json:"pub_key_2,omitempty"`
+	Account            string          `json:"account"`
+	Amount             int64           `json:"amount"`
+	LockedAmount       int64           `json:"locked_amount"`
+	ReleasedPerBlock   int64           `json:"released_per_block"`
+	DelegatedAccount   int16           `json:"delegated_account"`
+	OperationalAccount bool            `json:"operational_account"`
+	PubKey             string          `json:"pub_key"`
+	PubKey2            string          `json:"pub_key_2,omitempty"`
+	Vesting            *GenesisVesting `json:"vesting,omitempty"`
+}
+
+// GenesisVesting locks LockedAmount of staking with vesting schedule instead of ReleasedPerBlock
+type GenesisVesting struct {
+	CliffBlock int64  `json:"cliff_block"`
+	StepBlocks int64  `json:"step_blocks"`
+	EndBlock   int64  `json:"end_block"`
+	Grantor    string `json:"grantor,omitempty"` // grant is revocable when grantor is set
 }
 
 type GenesisTransactions struct {
@@ -63,6 +72,20 @@ type Genesis struct {
 	MinStakingUser          int64                 `json:"min_staking_user"`
 	OraclesHeightDistance   int64                 `json:"oracles_height_distance"`
 	VotingHeightDistance    int64                 `json:"voting_height_distance"`
+	UnbondingDelay          int64                 `json:"unbonding_delay,omitempty"`
+	UnbondingHeight         int64                 `json:"unbonding_height,omitempty"`
+	ValidatorJailingHeight  int64                 `json:"validator_jailing_height,omitempty"`
+	AllTxPubKeysHeight      int64                 `json:"all_tx_pubkeys_height,omitempty"`
+	MultiSignExpiryHeight   int64                 `json:"multi_sign_expiry_height,omitempty"`
+	DelegatedEscrowHeight   int64                 `json:"delegated_escrow_height,omitempty"`
+	MultiSignVoteHeight     int64                 `json:"multi_sign_vote_height,omitempty"`
+	SocialRecoveryHeight    int64                 `json:"social_recovery_height,omitempty"`
+	GovernanceHeight        int64                 `json:"governance_height,omitempty"`
+	RandCommitRevealHeight  int64                 `json:"rand_commit_reveal_height,omitempty"`
+	SlashingPerMille        int64                 `json:"slashing_per_mille,omitempty"`
+	SlashingTreasury        string                `json:"slashing_treasury,omitempty"` // slashed coins are burned to zero address when empty
+	DifficultyLWMAHeight    int64                 `json:"difficulty_lwma_height,omitempty"`
+	DifficultyWindow        int64                 `json:"difficulty_window,omitempty"`
 	StakedBalances          []GenesisStaking      `json:"staked_balances"`
 	Transactions            []GenesisTransactions `json:"transactions"`
 	Signature               string                `json:"signature"`
@@ -164,6 +187,29 @@ func CreateBlockFromGenesis(genesis Genesis) blocks.Block {
 			ReleasePerBlock:    []int64{stkTx.ReleasedPerBlock},
 			StakingDetails:     sds,
 		}
+		if stkTx.Vesting != nil {
+			v := account.VestingSchedule{
+				Amount:     stkTx.LockedAmount,
+				StartBlock: 0,
+				CliffBlock: stkTx.Vesting.CliffBlock,
+				StepBlocks: stkTx.Vesting.StepBlocks,
+				EndBlock:   stkTx.Vesting.EndBlock,
+			}
+			if v.StepBlocks <= 0 || v.EndBlock <= 0 || v.CliffBlock < 0 || v.CliffBlock > v.EndBlock {
+				logger.GetLogger().Fatal("wrong vesting schedule in genesis block")
+			}
+			if stkTx.Vesting.Grantor != "" {
+				gb, err := hex.DecodeString(stkTx.Vesting.Grantor)
+				if err != nil || len(gb) != common.AddressLength {
+					logger.GetLogger().Fatal("cannot decode grantor of vesting in genesis block")
+				}
+				copy(v.Grantor[:], gb)
+			}
+			as.LockedInitBlock = []int64{}
+			as.LockedAmount = []int64{}
+			as.ReleasePerBlock = []int64{}
+			as.Vestings = []account.VestingSchedule{v}
+		}
 		pk1 := storeGenesisPubKey(stkTx.PubKey, true)
 		if stkTx.PubKey2 != "" {
 			pk2 := storeGenesisPubKey(stkTx.PubKey2, false)
@@ -368,6 +414,22 @@ func InitGenesis() {
 	if err != nil {
 		logger.GetLogger().Fatal(err)
 	}
+	err = transactionsPool.StorePendingPools(0)
+	if err != nil {
+		logger.GetLogger().Fatal(err)
+	}
+	err = account.StoreRecoveries(0)
+	if err != nil {
+		logger.GetLogger().Fatal(err)
+	}
+	err = account.StoreValidators(0)
+	if err != nil {
+		logger.GetLogger().Fatal(err)
+	}
+	err = account.StoreGovernance(0)
+	if err != nil {
+		logger.GetLogger().Fatal(err)
+	}
 
 }
 
@@ -393,6 +455,56 @@ func setInitParams(genesisConfig Genesis) {
 	common.MinStakingUser = genesisConfig.MinStakingUser
 	common.OraclesHeightDistance = genesisConfig.OraclesHeightDistance
 	common.VotingHeightDistance = genesisConfig.VotingHeightDistance
+	if genesisConfig.UnbondingDelay > 0 {
+		common.UnbondingDelay = genesisConfig.UnbondingDelay
+	}
+	if genesisConfig.UnbondingHeight > 0 {
+		common.UnbondingHeight = genesisConfig.UnbondingHeight
+	}
+	if genesisConfig.ValidatorJailingHeight > 0 {
+		common.ValidatorJailingHeight = genesisConfig.ValidatorJailingHeight
+	}
+	if genesisConfig.AllTxPubKeysHeight > 0 {
+		common.AllTxPubKeysHeight = genesisConfig.AllTxPubKeysHeight
+	}
+	if genesisConfig.MultiSignExpiryHeight > 0 {
+		common.MultiSignExpiryHeight = genesisConfig.MultiSignExpiryHeight
+	}
+	if genesisConfig.DelegatedEscrowHeight > 0 {
+		common.DelegatedEscrowHeight = genesisConfig.DelegatedEscrowHeight
+	}
+	if genesisConfig.MultiSignVoteHeight > 0 {
+		common.MultiSignVoteHeight = genesisConfig.MultiSignVoteHeight
+	}
+	if genesisConfig.SocialRecoveryHeight > 0 {
+		common.SocialRecoveryHeight = genesisConfig.SocialRecoveryHeight
+	}
+	if genesisConfig.GovernanceHeight > 0 {
+		common.GovernanceHeight = genesisConfig.GovernanceHeight
+	}
+	if genesisConfig.RandCommitRevealHeight > 0 {
+		common.RandCommitRevealHeight = genesisConfig.RandCommitRevealHeight
+	}
+	if genesisConfig.SlashingPerMille > 0 {
+		if genesisConfig.SlashingPerMille > 1000 {
+			logger.GetLogger().Fatal("slashing_per_mille cannot be larger than 1000")
+		}
+		common.SlashingPerMille = genesisConfig.SlashingPerMille
+	}
+	if genesisConfig.SlashingTreasury != "" {
+		tb, err := hex.DecodeString(genesisConfig.SlashingTreasury)
+		if err != nil || len(tb) != common.AddressLength {
+			logger.GetLogger().Fatal("cannot decode slashing_treasury in genesis")
+		}
+		copy(common.SlashingTreasury.ByteValue[:], tb)
+	}
+	if genesisConfig.DifficultyLWMAHeight > 0 {
+		common.DifficultyLWMAHeight = genesisConfig.DifficultyLWMAHeight
+	}
+	if genesisConfig.DifficultyWindow > 0 {
+		common.DifficultyWindow = genesisConfig.DifficultyWindow
+	}
+	common.SaveGovernedDefaults()
 }
 
 // Load opens and consumes the genesis file.
@@ -6,8 +6,10 @@ import (
 	"github.com/okuralabs/okura-node/logger"
 )
 
-// tx - transaction, gt - get transaction, st - sync transaction, "nn" - nonce, "bl" - block, "rb" - reject block, "hi" - GetHeight, "gh" - GetHeaders, "sh" - SendHeaders
-var validHead = []string{"nn", "bl", "rb", "tx", "gt", "st", "bt", "hi", "gh", "sh"}
+// tx - transaction, gt - get transaction, st - sync transaction, "nn" - nonce, "bl" - block, "rb" - reject block, "hi" - GetHeight, "gh" - GetHeaders, "sh" - SendHeaders, "iv" - transaction hashes inventory, "cb" - compact block, "gb" - get block transactions,
+// "gs" - get snapshot manifest, "ss" - send snapshot manifest, "gc" - get snapshot chunks, "sc" - send snapshot chunks,
+// "ca" - checkpoint attestations
+var validHead = []string{"nn", "bl", "rb", "tx", "gt", "st", "bt", "hi", "gh", "sh", "iv", "cb", "gb", "gs", "ss", "gc", "sc", "ca"}
 
 type BaseMessage struct {
 	Head    []byte `json:"head"`
@@ -0,0 +1,186 @@
+package oracles
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+)
+
+// OracleRecord is oracles of block together with proposals of validators, stored for history queries
+type OracleRecord struct {
+	Height         int64           `json:"height"`
+	Timestamp      int64           `json:"timestamp"`
+	Price          int64           `json:"price"`
+	Rand           int64           `json:"rand"`
+	PriceReporters int             `json:"price_reporters"`
+	RandReporters  int             `json:"rand_reporters"`
+	StakedShare    float64         `json:"staked_share"` // part of all staked coins behind price proposals
+	Reports        map[uint8]int64 `json:"reports"`      // price proposed by delegated account
+}
+
+// OracleStats summarizes price oracle in range of heights
+type OracleStats struct {
+	From       int64   `json:"from"`
+	To         int64   `json:"to"`
+	Blocks     int     `json:"blocks"`
+	Priced     int     `json:"priced"` // blocks with established price
+	TWAP       int64   `json:"twap"`
+	Min        int64   `json:"min"`
+	Max        int64   `json:"max"`
+	Volatility float64 `json:"volatility"` // standard deviation of relative price changes
+}
+
+// ValidatorReport is price proposed by validator compared with price oracle of block
+type ValidatorReport struct {
+	Height    int64   `json:"height"`
+	Reported  int64   `json:"reported"`
+	Oracle    int64   `json:"oracle"`
+	Deviation float64 `json:"deviation"` // relative to oracle
+	Trimmed   bool    `json:"trimmed"`   // lowest or highest proposal removed before median
+}
+
+// ValidatorAudit shows how often validator proposes outliers
+type ValidatorAudit struct {
+	DelegatedAccount int               `json:"delegated_account"`
+	Blocks           int               `json:"blocks"`
+	Reported         int               `json:"reported"`
+	Trimmed          int               `json:"trimmed"`
+	MeanAbsDeviation float64           `json:"mean_abs_deviation"`
+	Reports          []ValidatorReport `json:"reports"`
+}
+
+func oracleRecordKey(height int64) []byte {
+	return append(common.OracleHistoryDBPrefix[:], common.GetByteInt64(height)...)
+}
+
+func StoreOracleRecord(r OracleRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return database.MainDB.Put(oracleRecordKey(r.Height), b)
+}
+
+func LoadOracleRecord(height int64) (OracleRecord, error) {
+	r := OracleRecord{}
+	b, err := database.MainDB.Get(oracleRecordKey(height))
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(b, &r)
+	return r, err
+}
+
+func RemoveOracleRecordFromDB(height int64) error {
+	return database.MainDB.Delete(oracleRecordKey(height))
+}
+
+// LoadOracleRecords returns stored records in range of heights, heights without record are skipped
+func LoadOracleRecords(from, to int64) ([]OracleRecord, error) {
+	if from < 0 || to < from {
+		return nil, fmt.Errorf("wrong range of heights: LoadOracleRecords")
+	}
+	if to-from >= common.MaxOracleHistoryRange {
+		return nil, fmt.Errorf("range of heights larger than %v: LoadOracleRecords", common.MaxOracleHistoryRange)
+	}
+	rs := []OracleRecord{}
+	for h := from; h <= to; h++ {
+		r, err := LoadOracleRecord(h)
+		if err != nil {
+			continue
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// CalculateOracleStats weights price of block by time since previous record, blocks without price are skipped
+func CalculateOracleStats(rs []OracleRecord, from, to int64) OracleStats {
+	st := OracleStats{From: from, To: to, Blocks: len(rs)}
+	var weighted, weights, sum float64
+	changes := []float64{}
+	prevPrice := int64(0)
+	for i, r := range rs {
+		if r.Price <= 0 {
+			continue
+		}
+		if st.Priced == 0 || r.Price < st.Min {
+			st.Min = r.Price
+		}
+		if r.Price > st.Max {
+			st.Max = r.Price
+		}
+		st.Priced++
+		sum += float64(r.Price)
+		if i > 0 && r.Timestamp > rs[i-1].Timestamp {
+			dt := float64(r.Timestamp - rs[i-1].Timestamp)
+			weighted += float64(r.Price) * dt
+			weights += dt
+		}
+		if prevPrice > 0 {
+			changes = append(changes, float64(r.Price-prevPrice)/float64(prevPrice))
+		}
+		prevPrice = r.Price
+	}
+	if st.Priced == 0 {
+		return st
+	}
+	if weights > 0 {
+		st.TWAP = int64(weighted / weights)
+	} else {
+		st.TWAP = int64(sum / float64(st.Priced))
+	}
+	if len(changes) > 1 {
+		mean := 0.0
+		for _, c := range changes {
+			mean += c
+		}
+		mean /= float64(len(changes))
+		variance := 0.0
+		for _, c := range changes {
+			variance += (c - mean) * (c - mean)
+		}
+		st.Volatility = math.Sqrt(variance / float64(len(changes)-1))
+	}
+	return st
+}
+
+// AuditValidator compares proposals of delegated account n with established price oracle
+func AuditValidator(rs []OracleRecord, n int) ValidatorAudit {
+	a := ValidatorAudit{DelegatedAccount: n, Blocks: len(rs), Reports: []ValidatorReport{}}
+	sumDeviation, priced := 0.0, 0
+	for _, r := range rs {
+		reported, ok := r.Reports[uint8(n)]
+		if !ok {
+			continue
+		}
+		vr := ValidatorReport{Height: r.Height, Reported: reported, Oracle: r.Price}
+		if r.Price > 0 {
+			vr.Deviation = float64(reported-r.Price) / float64(r.Price)
+			sumDeviation += math.Abs(vr.Deviation)
+			priced++
+		}
+		// the same trimming as in CalculatePriceOracle
+		if len(r.Reports) > 2 {
+			prices := make([]int64, 0, len(r.Reports))
+			for _, p := range r.Reports {
+				prices = append(prices, p)
+			}
+			sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+			vr.Trimmed = reported == prices[0] || reported == prices[len(prices)-1]
+		}
+		if vr.Trimmed {
+			a.Trimmed++
+		}
+		a.Reported++
+		a.Reports = append(a.Reports, vr)
+	}
+	if priced > 0 {
+		a.MeanAbsDeviation = sumDeviation / float64(priced)
+	}
+	return a
+}
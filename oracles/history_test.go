@@ -0,0 +1,87 @@
+package oracles
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+func TestCalculateOracleStats_TWAPAndVolatility(t *testing.T) {
+	rs := []OracleRecord{
+		{Height: 1, Timestamp: 0, Price: 100},
+		{Height: 2, Timestamp: 10, Price: 200},
+		{Height: 3, Timestamp: 20, Price: 0},
+		{Height: 4, Timestamp: 50, Price: 300},
+	}
+	st := CalculateOracleStats(rs, 1, 4)
+	// (200*10 + 300*30) / 40, block without price is skipped
+	if st.TWAP != 275 || st.Blocks != 4 || st.Priced != 3 || st.Min != 100 || st.Max != 300 {
+		t.Errorf("Expected twap 275 of 3 priced blocks in 100..300, got %+v", st)
+	}
+	// relative changes 1.0 and 0.5
+	if math.Abs(st.Volatility-math.Sqrt(0.125)) > 1e-9 {
+		t.Errorf("Expected volatility %v, got %v", math.Sqrt(0.125), st.Volatility)
+	}
+
+	sameTime := []OracleRecord{{Height: 1, Timestamp: 5, Price: 100}, {Height: 2, Timestamp: 5, Price: 300}}
+	if st = CalculateOracleStats(sameTime, 1, 2); st.TWAP != 200 {
+		t.Errorf("Expected plain average when blocks have the same time, got %v", st.TWAP)
+	}
+	if st = CalculateOracleStats([]OracleRecord{{Height: 1, Price: 0}}, 1, 1); st.Priced != 0 || st.TWAP != 0 {
+		t.Errorf("Expected no price without priced blocks, got %+v", st)
+	}
+}
+
+func TestAuditValidator_TrimmedOutliers(t *testing.T) {
+	rs := []OracleRecord{
+		{Height: 1, Price: 110, Reports: map[uint8]int64{1: 100, 2: 110, 3: 200}},
+		{Height: 2, Price: 100, Reports: map[uint8]int64{1: 100, 2: 100}},
+		{Height: 3, Price: 120, Reports: map[uint8]int64{1: 120, 2: 120, 3: 240, 4: 60}},
+	}
+	tests := []struct {
+		n        int
+		reported int
+		trimmed  int
+		mad      float64
+	}{
+		{1, 3, 1, (10.0/110 + 0 + 0) / 3},
+		{2, 3, 0, 0},
+		{3, 2, 2, (90.0/110 + 1) / 2},
+		{5, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		a := AuditValidator(rs, tt.n)
+		if a.Reported != tt.reported || a.Trimmed != tt.trimmed || math.Abs(a.MeanAbsDeviation-tt.mad) > 1e-9 {
+			t.Errorf("Expected validator %v reported %v, trimmed %v, deviation %v, got %+v", tt.n, tt.reported, tt.trimmed, tt.mad, a)
+		}
+	}
+}
+
+func TestOracleRecords_StoreAndRange(t *testing.T) {
+	const height = int64(1) << 40
+	defer RemoveOracleRecordFromDB(height)
+	defer RemoveOracleRecordFromDB(height + 2)
+	r1 := OracleRecord{Height: height, Price: 10, Rand: 5, PriceReporters: 2, Reports: map[uint8]int64{1: 10, 2: 11}}
+	r2 := OracleRecord{Height: height + 2, Price: 12, Reports: map[uint8]int64{}}
+	for _, r := range []OracleRecord{r1, r2} {
+		if err := StoreOracleRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rs, err := LoadOracleRecords(height, height+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 2 || !reflect.DeepEqual(rs[0], r1) || !reflect.DeepEqual(rs[1], r2) {
+		t.Errorf("Expected stored records without missing height, got %+v", rs)
+	}
+	if _, err = LoadOracleRecords(height, height+common.MaxOracleHistoryRange); err == nil {
+		t.Errorf("Expected too large range rejected")
+	}
+	if _, err = LoadOracleRecords(height, height-1); err == nil {
+		t.Errorf("Expected reversed range rejected")
+	}
+}
@@ -1,6 +1,7 @@
 package oracles
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/okuralabs/okura-node/account"
@@ -16,10 +17,15 @@ type PriceOracle struct {
 	Staked int64 `json:"staked"`
 }
 
+// RandOracle is proposal of validator for height. From common.RandCommitRevealHeight Reveal opens
+// commit of validator from previous block and Commit binds secret revealed in next block, before
+// it validator proposes Rand number.
 type RandOracle struct {
-	Rand   int64 `json:"rand"`
-	Height int64 `json:"height"`
-	Staked int64 `json:"staked"`
+	Rand   int64                   `json:"rand,omitempty"`
+	Reveal [common.HashLength]byte `json:"reveal"`
+	Commit [common.HashLength]byte `json:"commit"`
+	Height int64                   `json:"height"`
+	Staked int64                   `json:"staked"`
 }
 
 var (
@@ -56,7 +62,8 @@ func SavePriceOracle(price int64, height int64, delegatedAccount common.Address,
 	return nil
 }
 
-func SaveRandOracle(rand int64, height int64, delegatedAccount common.Address, staked int64) error {
+// SaveRandOracle stores rand proposal of validator from nonce for height
+func SaveRandOracle(proposal []byte, height int64, delegatedAccount common.Address, staked int64) error {
 	id, err := common.GetIDFromDelegatedAccountAddress(delegatedAccount)
 	if err != nil {
 		return err
@@ -65,16 +72,15 @@ func SaveRandOracle(rand int64, height int64, delegatedAccount common.Address, s
 	if (id <= 0) || (id >= 256) {
 		return fmt.Errorf("delegated account is invalid: %d", id)
 	}
+	if len(proposal) != RandProposalLength(height) {
+		return errors.New("invalid length of rand proposal")
+	}
 	RandOraclesRWMutex.Lock()
 	defer RandOraclesRWMutex.Unlock()
 
 	po, exists := RandOracles[uint8(id)]
 	if !exists || po.Height <= height {
-		RandOracles[uint8(id)] = RandOracle{
-			Rand:   rand,
-			Height: height,
-			Staked: staked,
-		}
+		RandOracles[uint8(id)] = randOracleFromProposal(proposal, height, staked)
 	} else {
 		return errors.New("invalid height in rand oracle")
 	}
@@ -128,75 +134,265 @@ func ParsePriceData(priceData []byte) (map[uint8]PriceOracle, []int64, int64, er
 	return parsedData, prices, allStaked, nil
 }
 
-func ParseRandData(randData []byte) (map[uint8]RandOracle, []byte, int64, error) {
+func randOracleFromProposal(proposal []byte, height int64, staked int64) RandOracle {
+	ro := RandOracle{Height: height, Staked: staked}
+	if !IsCommitReveal(height) {
+		ro.Rand = common.GetInt64FromByte(proposal)
+		return ro
+	}
+	copy(ro.Reveal[:], proposal[:common.HashLength])
+	copy(ro.Commit[:], proposal[common.HashLength:])
+	return ro
+}
+
+func (ro RandOracle) proposal() []byte {
+	if !IsCommitReveal(ro.Height) {
+		return common.GetByteInt64(ro.Rand)
+	}
+	return append(append([]byte{}, ro.Reveal[:]...), ro.Commit[:]...)
+}
+
+// ParseRandData parses rand oracle data of block at height. Entry is id(1) height(8) proposal,
+// see RandProposalLength.
+func ParseRandData(randData []byte, height int64) (map[uint8]RandOracle, []uint8, int64, error) {
 	parsedData := make(map[uint8]RandOracle)
 	dataLen := len(randData)
-	rands := make([]byte, 0)
+	ids := []uint8{}
 	allStaked := int64(0)
+	entryLength := 9 + RandProposalLength(height)
 
-	if dataLen%17 != 0 {
+	if dataLen%entryLength != 0 {
 		return nil, nil, 0, fmt.Errorf("invalid randData length: %d", dataLen)
 	}
 
-	for i := 0; i < dataLen; i += 17 {
+	for i := 0; i < dataLen; i += entryLength {
 		id := randData[i]
-		height := common.GetInt64FromByte(randData[i+1 : i+9])
-		rand := common.GetInt64FromByte(randData[i+9 : i+17])
-		rands = append(rands, randData[i+9:i+17]...)
+		if _, ok := parsedData[id]; ok && IsCommitReveal(height) {
+			return nil, nil, 0, fmt.Errorf("duplicated delegated account %d in randData", id)
+		}
 		_, staked, _ := account.GetStakedInDelegatedAccount(int(id))
 		allStaked += int64(staked)
-		parsedData[id] = RandOracle{
-			Rand:   rand,
-			Height: height,
-			Staked: int64(staked),
-		}
+		ro := randOracleFromProposal(randData[i+9:i+entryLength], height, int64(staked))
+		ro.Height = common.GetInt64FromByte(randData[i+1 : i+9])
+		parsedData[id] = ro
+		ids = append(ids, id)
 	}
 
-	return parsedData, rands, allStaked, nil
+	return parsedData, ids, allStaked, nil
 }
 
-func GenerateRandData(height int64) ([]byte, []byte, int64) {
+// GenerateRandData returns rand oracle data of block at height and sum of stakes of proposals
+func GenerateRandData(height int64) ([]byte, int64) {
 	randData := make([]byte, 0)
-	rands := make([]byte, 0)
 	staked := int64(0)
 	RandOraclesRWMutex.RLock()
 	defer RandOraclesRWMutex.RUnlock()
-	for i, po := range RandOracles {
-		if height <= po.Height+common.OraclesHeightDistance && po.Rand > 0 {
-			randData = append(randData, i)
-			randData = append(randData, common.GetByteInt64(po.Height)...)
-			randData = append(randData, common.GetByteInt64(po.Rand)...)
-			rands = append(rands, common.GetByteInt64(po.Rand)...)
-			staked += po.Staked
+	ids := []uint8{}
+	for i, ro := range RandOracles {
+		if IsCommitReveal(height) {
+			// reveal and commit are bound to height so only proposals for this block are used
+			if ro.Height == height {
+				ids = append(ids, i)
+			}
+		} else if height <= ro.Height+common.OraclesHeightDistance && ro.Rand > 0 {
+			ids = append(ids, i)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, i := range ids {
+		ro := RandOracles[i]
+		randData = append(randData, i)
+		randData = append(randData, common.GetByteInt64(ro.Height)...)
+		randData = append(randData, ro.proposal()...)
+		staked += ro.Staked
+	}
+	return randData, staked
+}
+
+// ValidReveals returns in order of data validators whose reveal opens commit from previous block.
+// Only validators which committed in previous block and whose nonce is included in block are counted.
+func ValidReveals(randData []byte, prevRandData []byte, height int64) ([]uint8, [][]byte, int64, error) {
+	parsed, ids, _, err := ParseRandData(randData, height)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	prev, _, _, err := ParseRandData(prevRandData, height-1)
+	if err != nil || !IsCommitReveal(height-1) {
+		// first block with commit-reveal has no commits to open
+		prev = map[uint8]RandOracle{}
+	}
+	revealed := []uint8{}
+	reveals := [][]byte{}
+	staked := int64(0)
+	for _, id := range ids {
+		p, ok := prev[id]
+		if !ok {
+			continue
+		}
+		ro := parsed[id]
+		if !bytes.Equal(CommitOfSecret(ro.Reveal[:]), p.Commit[:]) {
+			continue
+		}
+		revealed = append(revealed, id)
+		reveals = append(reveals, ro.Reveal[:])
+		staked += ro.Staked
+	}
+	return revealed, reveals, staked, nil
+}
+
+// UnopenedCommits returns validators with commit in commitData of block at commitHeight, whose first
+// entry in laterData does not open the commit. laterData are rand data of next common.RandRevealWindow
+// blocks in order of heights. Validator whose nonce producer did not include can open commit in later
+// block, such reveal clears penalty but is not combined into rand oracle.
+func UnopenedCommits(commitData []byte, commitHeight int64, laterData [][]byte) []uint8 {
+	if !IsCommitReveal(commitHeight) {
+		return nil
+	}
+	committed, ids, _, err := ParseRandData(commitData, commitHeight)
+	if err != nil {
+		return nil
+	}
+	later := make([]map[uint8]RandOracle, 0, len(laterData))
+	for i, data := range laterData {
+		parsed, _, _, err := ParseRandData(data, commitHeight+1+int64(i))
+		if err != nil {
+			parsed = map[uint8]RandOracle{}
+		}
+		later = append(later, parsed)
+	}
+	unopened := []uint8{}
+	for _, id := range ids {
+		c := committed[id]
+		opened := false
+		for _, parsed := range later {
+			ro, ok := parsed[id]
+			if !ok {
+				continue
+			}
+			opened = bytes.Equal(CommitOfSecret(ro.Reveal[:]), c.Commit[:])
+			break
 		}
+		if !opened {
+			unopened = append(unopened, id)
+		}
+	}
+	return unopened
+}
+
+// LastCommitHeight returns height of the latest block below height in common.RandRevealWindow
+// containing entry of validator id, which has to be opened by next reveal of validator.
+// getRandData returns rand data of block at height. Without such block height-1 is returned.
+func LastCommitHeight(id uint8, height int64, getRandData func(int64) ([]byte, error)) int64 {
+	for h := height - 1; h >= height-common.RandRevealWindow && h >= 0 && IsCommitReveal(h); h-- {
+		data, err := getRandData(h)
+		if err != nil {
+			break
+		}
+		parsed, _, _, err := ParseRandData(data, h)
+		if err != nil {
+			continue
+		}
+		if _, ok := parsed[id]; ok {
+			return h
+		}
+	}
+	return height - 1
+}
+
+func randFromReveals(reveals [][]byte) (int64, error) {
+	b := []byte{}
+	for _, r := range reveals {
+		b = append(b, r...)
 	}
-	return randData, rands, staked
+	// Calculate hash from all revealed secrets
+	hash, err := common.CalcHashFromBytes(b)
+	if err != nil {
+		return 0, err
+	}
+	return common.GetInt64FromByte(hash[24:]), nil
 }
 
-func CalculateRandOracle(height int64, totalStaked int64) (int64, []byte, error) {
-	var rand int64
-	randData, rands, staked := GenerateRandData(height)
+// randFromProposals combines rand numbers proposed before common.RandCommitRevealHeight in order of data
+func randFromProposals(randData []byte) (int64, error) {
+	rands := []byte{}
+	for i := 0; i+17 <= len(randData); i += 17 {
+		rands = append(rands, randData[i+9:i+17]...)
+	}
+	// Calculate hash from all rand numbers propositions
+	hash, err := common.CalcHashFromBytes(rands)
+	if err != nil {
+		return 0, err
+	}
+	return common.GetInt64FromByte(hash[24:]), nil
+}
+
+// CalculateRandOracle combines secrets revealed for height. Secrets were committed in previous block
+// so the last validator can only withhold its reveal, not choose it. Withheld reveal is penalized
+// as missed round, see UnopenedCommits. Producer chooses which nonces are included in block, so it
+// can still pick any subset of reveals holding more than 2/3 of stake. Before common.RandCommitRevealHeight
+// rand numbers proposed by validators are combined.
+func CalculateRandOracle(height int64, totalStaked int64, prevRandData []byte) (int64, []byte, error) {
+	randData, staked := GenerateRandData(height)
+	if !IsCommitReveal(height) {
+		if staked <= 2*totalStaked/3 {
+			return 0, randData, errors.New("in rand, there is not enough staked value for 2/3")
+		}
+		if len(randData) == 0 {
+			return 0, randData, errors.New("not enough rands propositions")
+		}
+		rand, err := randFromProposals(randData)
+		if err != nil {
+			return 0, nil, err
+		}
+		return rand, randData, nil
+	}
+	_, reveals, staked, err := ValidReveals(randData, prevRandData, height)
+	if err != nil {
+		return 0, randData, err
+	}
 
 	if staked <= 2*totalStaked/3 {
 		return 0, randData, errors.New("in rand, there is not enough staked value for 2/3")
 	}
 
-	if len(rands) == 0 {
+	if len(reveals) == 0 {
 		return 0, randData, errors.New("not enough rands propositions")
 	}
-
-	// Calculate hash from all rand numbers propositions
-	bytes, err := common.CalcHashFromBytes(rands)
+	rand, err := randFromReveals(reveals)
 	if err != nil {
 		return 0, nil, err
 	}
-	rand = common.GetInt64FromByte(bytes[24:])
 	return rand, randData, nil
 }
 
-func VerifyRandOracle(height int64, totalStaked int64, randBlock int64, randData []byte) bool {
-
-	_, rands, staked, err := ParseRandData(randData)
+func VerifyRandOracle(height int64, totalStaked int64, randBlock int64, randData []byte, prevRandData []byte) bool {
+	parsed, ids, allStaked, err := ParseRandData(randData, height)
+	if err != nil {
+		return false
+	}
+	if !IsCommitReveal(height) {
+		if allStaked <= 2*totalStaked/3 {
+			if randBlock == 0 {
+				logger.GetLogger().Println("rand oracle is 0 , cannot be established")
+				return true
+			}
+			return false
+		}
+		if len(ids) == 0 {
+			return false
+		}
+		rand, err := randFromProposals(randData)
+		if err != nil {
+			return false
+		}
+		return rand == randBlock
+	}
+	for _, ro := range parsed {
+		if ro.Height != height {
+			return false
+		}
+	}
+	_, reveals, staked, err := ValidReveals(randData, prevRandData, height)
 	if err != nil {
 		return false
 	}
@@ -209,16 +405,13 @@ func VerifyRandOracle(height int64, totalStaked int64, randBlock int64, randData
 		return false
 	}
 
-	if len(rands) == 0 {
+	if len(reveals) == 0 {
 		return false
 	}
-
-	// Calculate hash from all rand numbers propositions
-	bytes, err := common.CalcHashFromBytes(rands)
+	rand, err := randFromReveals(reveals)
 	if err != nil {
 		return false
 	}
-	rand := common.GetInt64FromByte(bytes[24:])
 	return rand == randBlock
 }
 
@@ -0,0 +1,48 @@
+package oracles
+
+import (
+	"sync"
+	"time"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/oracles/pricefeed"
+)
+
+var (
+	priceAggregator     *pricefeed.Aggregator
+	priceAggregatorOnce sync.Once
+)
+
+// initPriceAggregator creates aggregator of sources from PRICE_SOURCES. Without sources nothing
+// is proposed (price 0), simulated price is used only when sim: source is configured.
+func initPriceAggregator() {
+	sources, err := pricefeed.ParseSources(common.PriceSources)
+	if err != nil {
+		logger.GetLogger().Println("wrong PRICE_SOURCES, price oracle proposes nothing", err)
+		sources = nil
+	}
+	if len(sources) == 0 {
+		logger.GetLogger().Println("error: no price sources configured in PRICE_SOURCES, price oracle proposes nothing")
+	}
+	priceAggregator = pricefeed.NewAggregator(sources, time.Duration(common.PriceMaxAgeSeconds)*time.Second, common.PriceMinSources)
+}
+
+// SetPriceSources replaces configured sources, used by tests and local feeders
+func SetPriceSources(sources []pricefeed.Source) {
+	priceAggregatorOnce.Do(func() {})
+	priceAggregator = pricefeed.NewAggregator(sources, time.Duration(common.PriceMaxAgeSeconds)*time.Second, common.PriceMinSources)
+}
+
+// GetPriceProposal returns price proposed by validator in nonce, 0 when sources do not give fresh price
+func GetPriceProposal() int64 {
+	priceAggregatorOnce.Do(initPriceAggregator)
+	if len(priceAggregator.Sources) == 0 {
+		return 0
+	}
+	price, errs := priceAggregator.Price()
+	for _, err := range errs {
+		logger.GetLogger().Println("price source:", err)
+	}
+	return price
+}
@@ -0,0 +1,94 @@
+package pricefeed
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Aggregator proposes median of fresh quotes of its sources. Quote older than MaxAge is stale,
+// at least MinSources fresh quotes are required.
+type Aggregator struct {
+	Sources    []Source
+	MaxAge     time.Duration
+	MinSources int
+	now        func() time.Time
+}
+
+func NewAggregator(sources []Source, maxAge time.Duration, minSources int) *Aggregator {
+	if minSources < 1 {
+		minSources = 1
+	}
+	return &Aggregator{Sources: sources, MaxAge: maxAge, MinSources: minSources, now: time.Now}
+}
+
+// Price returns median of fresh quotes and errors of sources which failed or were stale
+func (a *Aggregator) Price() (int64, []error) {
+	errs := []error{}
+	prices := []int64{}
+	now := a.now()
+	for _, s := range a.Sources {
+		q, err := s.Quote()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", s.Name(), err))
+			continue
+		}
+		if a.MaxAge > 0 && now.Sub(q.Time) > a.MaxAge {
+			errs = append(errs, fmt.Errorf("%v: quote from %v is stale", s.Name(), q.Time.Unix()))
+			continue
+		}
+		prices = append(prices, q.Price)
+	}
+	if len(prices) < a.MinSources {
+		return 0, append(errs, fmt.Errorf("only %v of %v required fresh prices", len(prices), a.MinSources))
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	mid := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return (prices[mid-1] + prices[mid]) / 2, errs
+	}
+	return prices[mid], errs
+}
+
+// ParseSources creates sources from comma separated list of:
+// http:URL|field|timefield, file:path, unix:path, sim:base
+func ParseSources(spec string) ([]Source, error) {
+	sources := []Source{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, value, ok := strings.Cut(entry, ":")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("wrong price source %v", entry)
+		}
+		switch kind {
+		case "http":
+			parts := strings.Split(value, "|")
+			s := HTTPSource{URL: parts[0], Field: "price"}
+			if len(parts) > 1 {
+				s.Field = parts[1]
+			}
+			if len(parts) > 2 {
+				s.TimeField = parts[2]
+			}
+			sources = append(sources, s)
+		case "file":
+			sources = append(sources, FileSource{Path: value})
+		case "unix":
+			sources = append(sources, SocketSource{Path: value})
+		case "sim":
+			base, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || base <= 0 {
+				return nil, fmt.Errorf("wrong base price of simulated source %v", entry)
+			}
+			sources = append(sources, NewSimulatedSource(base, base/20, time.Now().UnixNano()))
+		default:
+			return nil, fmt.Errorf("unknown kind of price source %v", kind)
+		}
+	}
+	return sources, nil
+}
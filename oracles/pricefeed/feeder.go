@@ -0,0 +1,66 @@
+package pricefeed
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"time"
+)
+
+// LocalFeeder writes quotes of source to file read by FileSource, it stands in for real feeder
+// in tests and local networks
+type LocalFeeder struct {
+	Source Source
+	Path   string
+	quit   chan struct{}
+}
+
+func NewLocalFeeder(source Source, path string) *LocalFeeder {
+	return &LocalFeeder{Source: source, Path: path, quit: make(chan struct{})}
+}
+
+// WriteQuote writes current quote of source, file is replaced atomically
+func (f *LocalFeeder) WriteQuote() error {
+	q, err := f.Source.Quote()
+	if err != nil {
+		return err
+	}
+	return WriteFeedFile(f.Path, q)
+}
+
+// Start writes quotes every interval until Stop
+func (f *LocalFeeder) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			_ = f.WriteQuote()
+			select {
+			case <-ticker.C:
+			case <-f.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (f *LocalFeeder) Stop() {
+	close(f.quit)
+}
+
+// WriteFeedFile stores quote in format read by FileSource
+func WriteFeedFile(path string, q Quote) error {
+	b, err := json.Marshal(feedMessage{
+		Price:     float64(q.Price) / math.Pow10(Decimals),
+		Timestamp: q.Time.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,107 @@
+package pricefeed
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fixedSource struct {
+	price int64
+	time  time.Time
+	err   error
+}
+
+func (s fixedSource) Name() string { return "fixed" }
+
+func (s fixedSource) Quote() (Quote, error) {
+	return Quote{Price: s.price, Time: s.time}, s.err
+}
+
+func TestAggregator_MedianOfFreshQuotes(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := NewAggregator([]Source{
+		fixedSource{price: 101000000, time: now},
+		fixedSource{price: 99000000, time: now.Add(-10 * time.Second)},
+		fixedSource{price: 500000000, time: now.Add(-time.Hour)}, // stale
+		fixedSource{price: 100000000, time: now},
+		fixedSource{err: errors.New("unreachable")},
+	}, time.Minute, 2)
+	a.now = func() time.Time { return now }
+
+	price, errs := a.Price()
+	if price != 100000000 {
+		t.Errorf("Expected median 100000000, got %v", price)
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected stale and failed source reported, got %v", errs)
+	}
+}
+
+func TestAggregator_NotEnoughSources(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := NewAggregator([]Source{
+		fixedSource{price: 100000000, time: now},
+		fixedSource{price: 100000000, time: now.Add(-time.Hour)},
+	}, time.Minute, 2)
+	a.now = func() time.Time { return now }
+
+	price, errs := a.Price()
+	if price != 0 || len(errs) == 0 {
+		t.Errorf("Expected no price when fresh sources are missing, got %v %v", price, errs)
+	}
+}
+
+func TestHTTPSource_Quote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"rates":[{"usd":"1.2345"}],"ts":1700000000}}`))
+	}))
+	defer srv.Close()
+
+	q, err := HTTPSource{URL: srv.URL, Field: "data.rates.0.usd", TimeField: "data.ts"}.Quote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Price != 123450000 || q.Time.Unix() != 1700000000 {
+		t.Errorf("Expected 123450000 at 1700000000, got %v at %v", q.Price, q.Time.Unix())
+	}
+}
+
+func TestLocalFeeder_FileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "price.json")
+	f := NewLocalFeeder(NewSimulatedSource(100000000, 0, 1), path)
+	err := f.WriteQuote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := FileSource{Path: path}.Quote()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Price != 100000000 {
+		t.Errorf("Expected 100000000, got %v", q.Price)
+	}
+	if time.Since(q.Time) > time.Minute {
+		t.Errorf("Expected fresh quote, got %v", q.Time)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	sources, err := ParseSources("http:https://example.com/p|a.b, file:/tmp/price.json,unix:/tmp/feed.sock,sim:100000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 4 {
+		t.Fatalf("Expected 4 sources, got %v", len(sources))
+	}
+	if s, ok := sources[0].(HTTPSource); !ok || s.URL != "https://example.com/p" || s.Field != "a.b" {
+		t.Errorf("Wrong http source %v", sources[0])
+	}
+	_, err = ParseSources("ftp:host")
+	if err == nil {
+		t.Errorf("Expected error for unknown source")
+	}
+}
@@ -0,0 +1,205 @@
+package pricefeed
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decimals of prices returned by sources, the same as of coins
+const Decimals = 8
+
+// Quote is price of OKU in USD with Decimals and time when it was observed
+type Quote struct {
+	Price int64     `json:"price"`
+	Time  time.Time `json:"time"`
+}
+
+// Source is external provider of price configured by validator
+type Source interface {
+	Name() string
+	Quote() (Quote, error)
+}
+
+// feedMessage is format of price written by feeders to file or socket
+type feedMessage struct {
+	Price     float64 `json:"price"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+func toPrice(v float64) (int64, error) {
+	if v <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid price %v", v)
+	}
+	return int64(math.Round(v * math.Pow10(Decimals))), nil
+}
+
+func quoteFromFeed(b []byte) (Quote, error) {
+	fm := feedMessage{}
+	err := json.Unmarshal(b, &fm)
+	if err != nil {
+		return Quote{}, err
+	}
+	p, err := toPrice(fm.Price)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{Price: p, Time: time.Unix(fm.Timestamp, 0)}, nil
+}
+
+// HTTPSource reads price from JSON returned by URL. Field is dot separated path to price,
+// TimeField optional path to unix timestamp, without it time of response is used.
+type HTTPSource struct {
+	URL       string
+	Field     string
+	TimeField string
+	Client    *http.Client
+}
+
+func (s HTTPSource) Name() string {
+	return "http:" + s.URL
+}
+
+func (s HTTPSource) Quote() (Quote, error) {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		return Quote{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Quote{}, fmt.Errorf("price source %v returned status %v", s.URL, resp.StatusCode)
+	}
+	var doc interface{}
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	if err != nil {
+		return Quote{}, err
+	}
+	v, err := numberAtPath(doc, s.Field)
+	if err != nil {
+		return Quote{}, err
+	}
+	p, err := toPrice(v)
+	if err != nil {
+		return Quote{}, err
+	}
+	q := Quote{Price: p, Time: time.Now()}
+	if s.TimeField != "" {
+		ts, err := numberAtPath(doc, s.TimeField)
+		if err != nil {
+			return Quote{}, err
+		}
+		q.Time = time.Unix(int64(ts), 0)
+	}
+	return q, nil
+}
+
+// numberAtPath finds number or numeric string in decoded JSON, numbers in path index arrays
+func numberAtPath(doc interface{}, path string) (float64, error) {
+	cur := doc
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch c := cur.(type) {
+			case map[string]interface{}:
+				cur = c[key]
+			case []interface{}:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(c) {
+					return 0, fmt.Errorf("wrong index %v in path %v", key, path)
+				}
+				cur = c[i]
+			default:
+				return 0, fmt.Errorf("no field %v in path %v", key, path)
+			}
+		}
+	}
+	switch v := cur.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("no number at path %v", path)
+}
+
+// FileSource reads price written to file by local feeder as {"price": 1.02, "timestamp": 1700000000}
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Name() string {
+	return "file:" + s.Path
+}
+
+func (s FileSource) Quote() (Quote, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return Quote{}, err
+	}
+	return quoteFromFeed(b)
+}
+
+// SocketSource reads one line with price in feeder format from unix socket
+type SocketSource struct {
+	Path string
+}
+
+func (s SocketSource) Name() string {
+	return "unix:" + s.Path
+}
+
+func (s SocketSource) Quote() (Quote, error) {
+	conn, err := net.DialTimeout("unix", s.Path, 2*time.Second)
+	if err != nil {
+		return Quote{}, err
+	}
+	defer conn.Close()
+	err = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err != nil {
+		return Quote{}, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return Quote{}, err
+	}
+	return quoteFromFeed(line)
+}
+
+// SimulatedSource is stand-in for tests and local networks, price walks randomly around Base
+// by at most Spread
+type SimulatedSource struct {
+	Base   int64
+	Spread int64
+	mutex  sync.Mutex
+	rnd    *rand.Rand
+}
+
+func NewSimulatedSource(base int64, spread int64, seed int64) *SimulatedSource {
+	return &SimulatedSource{Base: base, Spread: spread, rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (s *SimulatedSource) Name() string {
+	return fmt.Sprintf("sim:%v", s.Base)
+}
+
+func (s *SimulatedSource) Quote() (Quote, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p := s.Base
+	if s.Spread > 0 {
+		p += s.rnd.Int63n(2*s.Spread+1) - s.Spread
+	}
+	return Quote{Price: p, Time: time.Now()}, nil
+}
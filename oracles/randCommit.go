@@ -0,0 +1,86 @@
+package oracles
+
+import (
+	"crypto/rand"
+	"math"
+	"sync"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+)
+
+var (
+	randSeed     []byte
+	randSeedOnce sync.Once
+)
+
+// IsCommitReveal tells whether rand oracle at height is combined from revealed secrets committed
+// in previous block
+func IsCommitReveal(height int64) bool {
+	return common.RandCommitRevealHeight > 0 && height >= common.RandCommitRevealHeight
+}
+
+// RandProposalLength is length of rand proposal in nonce for height: reveal(32) commit(32),
+// before common.RandCommitRevealHeight rand number(8)
+func RandProposalLength(height int64) int {
+	if IsCommitReveal(height) {
+		return 2 * common.HashLength
+	}
+	return 8
+}
+
+// loadRandSeed reads seed of secrets of validator from DB, so secrets committed before restart
+// can be revealed. New seed is generated and stored when there is none.
+func loadRandSeed() {
+	key := common.RandSeedDBPrefix[:]
+	seed, err := database.MainDB.Get(key)
+	if err == nil && len(seed) == common.HashLength {
+		randSeed = seed
+		return
+	}
+	randSeed = make([]byte, common.HashLength)
+	_, err = rand.Read(randSeed)
+	if err != nil {
+		logger.GetLogger().Fatal("cannot generate seed of rand oracle", err)
+	}
+	err = database.MainDB.Put(key, randSeed)
+	if err != nil {
+		logger.GetLogger().Println("cannot store seed of rand oracle, commits are lost after restart", err)
+	}
+}
+
+// randSecret is secret of validator for height derived from seed
+func randSecret(height int64) []byte {
+	randSeedOnce.Do(loadRandSeed)
+	s, err := common.CalcHashToByte(append(append([]byte{}, randSeed...), common.GetByteInt64(height)...))
+	if err != nil {
+		logger.GetLogger().Println(err)
+		return make([]byte, common.HashLength)
+	}
+	return s
+}
+
+func CommitOfSecret(secret []byte) []byte {
+	c, err := common.CalcHashToByte(secret)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// GetRandProposal returns proposal sent in nonce for height: reveal of secret committed for
+// revealHeight and commit of secret for height, before common.RandCommitRevealHeight random number.
+// revealHeight is height of the latest block with commit of validator, see LastCommitHeight.
+func GetRandProposal(height int64, revealHeight int64) []byte {
+	if !IsCommitReveal(height) {
+		b := make([]byte, 8)
+		_, err := rand.Read(b)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		return common.GetByteInt64(common.GetInt64FromByte(b) & math.MaxInt64)
+	}
+	b := append([]byte{}, randSecret(revealHeight)...)
+	return append(b, CommitOfSecret(randSecret(height))...)
+}
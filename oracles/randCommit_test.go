@@ -0,0 +1,195 @@
+package oracles
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+func randEntry(id uint8, height int64, proposal []byte) []byte {
+	b := append([]byte{id}, common.GetByteInt64(height)...)
+	return append(b, proposal...)
+}
+
+func secretOf(b byte) []byte {
+	return bytes.Repeat([]byte{b}, common.HashLength)
+}
+
+func commitReveal(reveal []byte, commit []byte) []byte {
+	return append(append([]byte{}, reveal...), commit...)
+}
+
+func TestRandProposalLength(t *testing.T) {
+	defer func(h int64) { common.RandCommitRevealHeight = h }(common.RandCommitRevealHeight)
+	common.RandCommitRevealHeight = 100
+	tests := []struct {
+		height int64
+		length int
+	}{
+		{99, 8},
+		{100, 2 * common.HashLength},
+		{101, 2 * common.HashLength},
+	}
+	for _, tt := range tests {
+		if l := RandProposalLength(tt.height); l != tt.length {
+			t.Errorf("Expected proposal length %v at height %v, got %v", tt.length, tt.height, l)
+		}
+		if l := len(GetRandProposal(tt.height, tt.height-1)); l != tt.length {
+			t.Errorf("Expected generated proposal length %v at height %v, got %v", tt.length, tt.height, l)
+		}
+	}
+	common.RandCommitRevealHeight = 0
+	if l := RandProposalLength(1000); l != 8 {
+		t.Errorf("Expected rand numbers without activation height, got length %v", l)
+	}
+}
+
+func TestRandProposal_RevealOpensPreviousCommit(t *testing.T) {
+	defer func(h int64) { common.RandCommitRevealHeight = h }(common.RandCommitRevealHeight)
+	common.RandCommitRevealHeight = 100
+	prev := GetRandProposal(101, 100)
+	next := GetRandProposal(102, 101)
+	if !bytes.Equal(CommitOfSecret(next[:common.HashLength]), prev[common.HashLength:]) {
+		t.Errorf("Expected reveal at height 102 to open commit from height 101")
+	}
+	late := GetRandProposal(103, 101)
+	if !bytes.Equal(CommitOfSecret(late[:common.HashLength]), prev[common.HashLength:]) {
+		t.Errorf("Expected late reveal at height 103 to open commit from height 101")
+	}
+}
+
+func TestParseRandData_FormatByHeight(t *testing.T) {
+	defer func(h int64) { common.RandCommitRevealHeight = h }(common.RandCommitRevealHeight)
+	common.RandCommitRevealHeight = 100
+	old := append(randEntry(1, 99, common.GetByteInt64(7)), randEntry(2, 99, common.GetByteInt64(8))...)
+	parsed, ids, _, err := ParseRandData(old, 99)
+	if err != nil || parsed[1].Rand != 7 || parsed[2].Rand != 8 || !reflect.DeepEqual(ids, []uint8{1, 2}) {
+		t.Errorf("Expected rand numbers before activation, got %+v, %v, %v", parsed, ids, err)
+	}
+	if _, _, _, err = ParseRandData(old, 100); err == nil {
+		t.Errorf("Expected old format rejected after activation")
+	}
+	proposal := commitReveal(secretOf(1), secretOf(2))
+	parsed, _, _, err = ParseRandData(randEntry(3, 100, proposal), 100)
+	ro := parsed[3]
+	if err != nil || !bytes.Equal(ro.Reveal[:], secretOf(1)) || !bytes.Equal(ro.Commit[:], secretOf(2)) {
+		t.Errorf("Expected reveal and commit after activation, got %+v, %v", parsed, err)
+	}
+	duplicated := append(randEntry(3, 100, proposal), randEntry(3, 100, proposal)...)
+	if _, _, _, err = ParseRandData(duplicated, 100); err == nil {
+		t.Errorf("Expected duplicated validator rejected")
+	}
+}
+
+func TestValidReveals(t *testing.T) {
+	defer func(h int64) { common.RandCommitRevealHeight = h }(common.RandCommitRevealHeight)
+	common.RandCommitRevealHeight = 100
+	prevData := append(randEntry(1, 100, commitReveal(secretOf(0), CommitOfSecret(secretOf(1)))),
+		randEntry(2, 100, commitReveal(secretOf(0), CommitOfSecret(secretOf(2))))...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		height   int64
+		prevData []byte
+		revealed []uint8
+	}{
+		{"all revealed",
+			append(randEntry(1, 101, commitReveal(secretOf(1), secretOf(9))), randEntry(2, 101, commitReveal(secretOf(2), secretOf(9)))...),
+			101, prevData, []uint8{1, 2}},
+		{"wrong reveal",
+			append(randEntry(1, 101, commitReveal(secretOf(1), secretOf(9))), randEntry(2, 101, commitReveal(secretOf(5), secretOf(9)))...),
+			101, prevData, []uint8{1}},
+		{"validator without commit",
+			randEntry(4, 101, commitReveal(secretOf(4), secretOf(9))),
+			101, prevData, []uint8{}},
+		{"first block with commit-reveal",
+			randEntry(1, 100, commitReveal(secretOf(1), secretOf(9))),
+			100, randEntry(1, 99, common.GetByteInt64(7)), []uint8{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revealed, reveals, _, err := ValidReveals(tt.data, tt.prevData, tt.height)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(revealed, tt.revealed) || len(reveals) != len(tt.revealed) {
+				t.Errorf("Expected revealed %v, got %v", tt.revealed, revealed)
+			}
+		})
+	}
+}
+
+func TestUnopenedCommits(t *testing.T) {
+	defer func(h int64) { common.RandCommitRevealHeight = h }(common.RandCommitRevealHeight)
+	common.RandCommitRevealHeight = 100
+	commitData := append(randEntry(1, 100, commitReveal(secretOf(0), CommitOfSecret(secretOf(1)))),
+		randEntry(2, 100, commitReveal(secretOf(0), CommitOfSecret(secretOf(2))))...)
+	opens := func(id uint8, height int64, secret byte) []byte {
+		return randEntry(id, height, commitReveal(secretOf(secret), secretOf(9)))
+	}
+
+	tests := []struct {
+		name     string
+		later    [][]byte
+		unopened []uint8
+	}{
+		{"opened in next block",
+			[][]byte{append(opens(1, 101, 1), opens(2, 101, 2)...), {}, {}},
+			[]uint8{}},
+		{"reveal withheld",
+			[][]byte{opens(1, 101, 1), {}, {}},
+			[]uint8{2}},
+		{"late reveal after censored nonce",
+			[][]byte{opens(1, 101, 1), opens(2, 102, 2), {}},
+			[]uint8{}},
+		{"wrong first reveal is not corrected later",
+			[][]byte{append(opens(1, 101, 1), opens(2, 101, 5)...), opens(2, 102, 2), {}},
+			[]uint8{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnopenedCommits(commitData, 100, tt.later); !reflect.DeepEqual(got, tt.unopened) {
+				t.Errorf("Expected unopened commits %v, got %v", tt.unopened, got)
+			}
+		})
+	}
+	if got := UnopenedCommits(randEntry(1, 99, common.GetByteInt64(7)), 99, nil); got != nil {
+		t.Errorf("Expected no commits before activation height, got %v", got)
+	}
+}
+
+func TestLastCommitHeight(t *testing.T) {
+	defer func(h, w int64) {
+		common.RandCommitRevealHeight, common.RandRevealWindow = h, w
+	}(common.RandCommitRevealHeight, common.RandRevealWindow)
+	common.RandCommitRevealHeight = 100
+	common.RandRevealWindow = 3
+	entry := commitReveal(secretOf(0), secretOf(1))
+	chain := map[int64][]byte{
+		100: randEntry(1, 100, entry),
+		101: randEntry(2, 101, entry),
+		102: {},
+	}
+	getRandData := func(h int64) ([]byte, error) {
+		return chain[h], nil
+	}
+	tests := []struct {
+		id     uint8
+		height int64
+		want   int64
+	}{
+		{2, 102, 101},
+		{2, 103, 101},
+		{1, 103, 100},
+		{1, 104, 103},
+		{3, 103, 102},
+	}
+	for _, tt := range tests {
+		if got := LastCommitHeight(tt.id, tt.height, getRandData); got != tt.want {
+			t.Errorf("Expected commit height %v of %v below %v, got %v", tt.want, tt.id, tt.height, got)
+		}
+	}
+}
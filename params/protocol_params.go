@@ -153,6 +153,11 @@ const (
 	Bls12381MapG1Gas          uint64 = 5500   // Gas price for BLS12-381 mapping field element to G1 operation
 	Bls12381MapG2Gas          uint64 = 110000 // Gas price for BLS12-381 mapping field element to G2 operation
 
+	OracleReadGas         uint64 = 2100 // Price for reading price or rand oracle of one block
+	OracleTWAPBaseGas     uint64 = 2100 // Base price for time weighted average price of oracle
+	OracleTWAPPerBlockGas uint64 = 200  // Per-block price for time weighted average price of oracle
+	OracleTWAPMaxBlocks   uint64 = 1024 // Maximal number of blocks in time weighted average price of oracle
+
 	// The Refund Quotient is the cap on how much of the used gas can be refunded. Before EIP-3529,
 	// up to half the consumed gas could be refunded. Redefined as 1/5th in EIP-3529
 	RefundQuotient        uint64 = 2
@@ -0,0 +1,46 @@
+package pubkeys
+
+import (
+	"github.com/okuralabs/okura-node/common"
+)
+
+// KeyStatus tells whether key registered for account in primary or secondary scheme can still sign
+type KeyStatus struct {
+	Primary        bool           `json:"primary"`
+	SigName        string         `json:"sig_name"` // current scheme
+	Registered     bool           `json:"registered"`
+	Address        common.Address `json:"address"`
+	Paused         bool           `json:"paused"`   // scheme is paused by voting
+	Outdated       bool           `json:"outdated"` // registered key does not fit current scheme
+	Revoked        bool           `json:"revoked"`
+	Usable         bool           `json:"usable"`
+	NeedsMigration bool           `json:"needs_migration"` // new key has to be registered with key of other scheme
+}
+
+func GetKeyStatus(mainAddress common.Address, primary bool) KeyStatus {
+	ks := KeyStatus{Primary: primary}
+	pubKeyLength := common.PubKeyLength2()
+	if primary {
+		ks.SigName = common.SigName()
+		ks.Paused = common.IsPaused()
+		pubKeyLength = common.PubKeyLength()
+	} else {
+		ks.SigName = common.SigName2()
+		ks.Paused = common.IsPaused2()
+	}
+	pk, err := LoadPubKeyWithPrimary(mainAddress, primary)
+	if err == nil {
+		ks.Registered = true
+		ks.Address = pk.Address
+		ks.Outdated = len(pk.GetBytes()) != pubKeyLength
+		ks.Revoked = IsPubKeyRevoked(pk.Address.GetBytes())
+	}
+	ks.Usable = ks.Registered && !ks.Paused && !ks.Outdated && !ks.Revoked
+	ks.NeedsMigration = ks.Registered && ks.Outdated && !ks.Paused
+	return ks
+}
+
+// GetKeyStatuses returns status of keys of account in both schemes
+func GetKeyStatuses(mainAddress common.Address) []KeyStatus {
+	return []KeyStatus{GetKeyStatus(mainAddress, true), GetKeyStatus(mainAddress, false)}
+}
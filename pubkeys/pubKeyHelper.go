@@ -95,3 +95,147 @@ func LoadPubKeyWithPrimary(mainAddress common.Address, primary bool) (common.Pub
 	}
 	return common.PubKey{}, fmt.Errorf("no pubkey found")
 }
+
+// LoadPubKeyAtHeight returns last pubkey of main address which was registered and not revoked at height.
+// Pubkeys without recorded height of registration are registered from the beginning.
+func LoadPubKeyAtHeight(mainAddress common.Address, primary bool, height int64) (common.PubKey, error) {
+	addresses, err := LoadAddresses(mainAddress)
+	if err != nil {
+		return common.PubKey{}, err
+	}
+	for i := len(addresses) - 1; i >= 0; i-- {
+		addr := addresses[i]
+		if addr.Primary != primary {
+			continue
+		}
+		added, err := database.MainDB.Get(append(common.PubKeyAddedHeightDBPrefix[:], addr.GetBytes()...))
+		if err == nil && len(added) == 8 && common.GetInt64FromByte(added) > height {
+			continue
+		}
+		revoked, err := database.MainDB.Get(append(common.RevokedPubKeyDBPrefix[:], addr.GetBytes()...))
+		if err == nil && len(revoked) == 8 && common.GetInt64FromByte(revoked) < height {
+			continue
+		}
+		return LoadPubKey(addr.GetBytes())
+	}
+	return common.PubKey{}, fmt.Errorf("no pubkey valid at height %v found", height)
+}
+
+// RevokePubKey marks address of pubkey which cannot sign transactions after rotation in social recovery
+func RevokePubKey(address common.Address, height int64) error {
+	err := database.MainDB.Put(append(common.RevokedPubKeyDBPrefix[:], address.GetBytes()...), common.GetByteInt64(height))
+	if err != nil {
+		return err
+	}
+	key := append(common.RevokedPubKeysByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	addresses, err := database.MainDB.Get(key)
+	if err != nil {
+		addresses = []byte{}
+	}
+	return database.MainDB.Put(key, append(addresses, address.GetBytes()...))
+}
+
+func IsPubKeyRevoked(address []byte) bool {
+	isKey, err := database.MainDB.IsKey(append(common.RevokedPubKeyDBPrefix[:], address...))
+	return err == nil && isKey
+}
+
+// RemoveRevokedPubKeysFromDB restores pubkeys revoked at height, used when chain is reset
+func RemoveRevokedPubKeysFromDB(height int64) error {
+	key := append(common.RevokedPubKeysByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	addresses, err := database.MainDB.Get(key)
+	if err != nil || len(addresses) == 0 {
+		return nil
+	}
+	for i := 0; i+common.AddressLength <= len(addresses); i += common.AddressLength {
+		err = database.MainDB.Delete(append(common.RevokedPubKeyDBPrefix[:], addresses[i:i+common.AddressLength]...))
+		if err != nil {
+			return err
+		}
+	}
+	return database.MainDB.Delete(key)
+}
+
+// HasAddress tells whether address of pubkey is registered for main address
+func HasAddress(mainAddress common.Address, address common.Address) bool {
+	addresses, err := LoadAddresses(mainAddress)
+	if err != nil {
+		return false
+	}
+	for _, a := range addresses {
+		if a.ByteValue == address.ByteValue && a.Primary == address.Primary {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordAddedPubKey remembers pubkey registered in block at height, so registration can be undone when chain is reset
+func RecordAddedPubKey(mainAddress common.Address, address common.Address, height int64) error {
+	err := database.MainDB.Put(append(common.PubKeyAddedHeightDBPrefix[:], address.GetBytes()...), common.GetByteInt64(height))
+	if err != nil {
+		return err
+	}
+	key := append(common.PubKeysByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	entries, err := database.MainDB.Get(key)
+	if err != nil {
+		entries = []byte{}
+	}
+	entries = append(entries, mainAddress.GetBytes()...)
+	return database.MainDB.Put(key, append(entries, address.GetBytesWithPrimary()...))
+}
+
+// RemoveAddedPubKeysFromDB unregisters pubkeys added at height, used when chain is reset
+func RemoveAddedPubKeysFromDB(height int64) error {
+	key := append(common.PubKeysByHeightDBPrefix[:], common.GetByteInt64(height)...)
+	entries, err := database.MainDB.Get(key)
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	n := 2*common.AddressLength + 1
+	for i := len(entries) - n; i >= 0; i -= n {
+		mainAddress, err := common.BytesToAddress(entries[i : i+common.AddressLength])
+		if err != nil {
+			return err
+		}
+		address, err := common.BytesToAddress(entries[i+common.AddressLength : i+n])
+		if err != nil {
+			return err
+		}
+		err = removeAddress(mainAddress, address)
+		if err != nil {
+			return err
+		}
+		err = database.MainDB.Delete(append(common.PubKeyAddedHeightDBPrefix[:], address.GetBytes()...))
+		if err != nil {
+			return err
+		}
+	}
+	return database.MainDB.Delete(key)
+}
+
+func removeAddress(mainAddress common.Address, address common.Address) error {
+	addresses, err := LoadAddresses(mainAddress)
+	if err != nil {
+		return err
+	}
+	left := []common.Address{}
+	for _, a := range addresses {
+		if a.ByteValue != address.ByteValue || a.Primary != address.Primary {
+			left = append(left, a)
+		}
+	}
+	if len(left) == 0 {
+		err = RemoveMerkleTrieFromDB(mainAddress)
+	} else {
+		tree, err2 := BuildMerkleTree(mainAddress, left, GlobalMerkleTree.DB)
+		if err2 != nil {
+			return err2
+		}
+		err = tree.StoreTree(mainAddress)
+	}
+	if err != nil {
+		return err
+	}
+	return database.MainDB.Delete(append(common.PubKeyMarshalDBPrefix[:], address.GetBytes()...))
+}
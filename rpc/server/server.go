@@ -11,6 +11,7 @@ import (
 	"github.com/okuralabs/okura-node/core/stateDB"
 	"github.com/okuralabs/okura-node/crypto/oqs"
 	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/oracles"
 	"github.com/okuralabs/okura-node/pubkeys"
 	nonceServices "github.com/okuralabs/okura-node/services/nonceService"
 	"github.com/okuralabs/okura-node/services/transactionServices"
@@ -18,6 +19,7 @@ import (
 	"github.com/okuralabs/okura-node/tcpip"
 	"github.com/okuralabs/okura-node/transactionsDefinition"
 	"github.com/okuralabs/okura-node/transactionsPool"
+	"github.com/okuralabs/okura-node/voting"
 	"github.com/okuralabs/okura-node/wallet"
 	"net"
 	"net/rpc"
@@ -130,6 +132,24 @@ func (l *Listener) Send(lineBeg []byte, reply *[]byte) error {
 		handleESCR(byt, reply)
 	case "MULT":
 		handleMULT(byt, reply)
+	case "RCVR":
+		handleRCVR(byt, reply)
+	case "VEST":
+		handleVEST(byt, reply)
+	case "VALI":
+		handleVALI(byt, reply)
+	case "ORCL":
+		handleORCL(byt, reply)
+	case "GOVN":
+		handleGOVN(byt, reply)
+	case "EVOT":
+		handleEVOT(byt, reply)
+	case "KEYS":
+		handleKEYS(byt, reply)
+	case "MIGR":
+		handleMIGR(byt, reply)
+	case "FINL":
+		handleFINL(byt, reply)
 	default:
 		*reply = []byte("Invalid operation")
 	}
@@ -162,17 +182,251 @@ func handleCHECK(line []byte, reply *[]byte) {
 	}
 }
 
+// handleESCR answers 'L' + address with transfers waiting in escrow sent from account or guarded by it.
+// Escrowed transfer is cancelled with transaction sent by TRAN, see blocks.ProcessEscrowCancellation.
 func handleESCR(line []byte, reply *[]byte) {
-	logger.GetLogger().Println(string(line))
 	*reply = nil
-	//primary := line[0] == 0
-	//delay := common.GetInt64FromByte(line[1:9])
-
+	if len(line) != 1+common.AddressLength || line[0] != 'L' {
+		*reply = []byte("Invalid query ESCR")
+		return
+	}
+	am, err := json.Marshal(blocks.GetEscrowTransfers(line[1:]))
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
 }
 
+// handleMULT answers queries about multi signature proposals:
+// 'L' + address lists pending proposals of account or waiting for co-signer,
+// 'S' + hash returns pending proposal or result of closed one
 func handleMULT(line []byte, reply *[]byte) {
-	logger.GetLogger().Println(string(line))
 	*reply = nil
+	if len(line) < 1 {
+		*reply = []byte("Invalid query MULT")
+		return
+	}
+	var r any
+	switch {
+	case line[0] == 'L' && len(line) == 1+common.AddressLength:
+		r = blocks.GetMultiSignProposals(line[1:])
+	case line[0] == 'S' && len(line) == 1+common.HashLength:
+		p, err := blocks.GetMultiSignProposal(line[1:])
+		if err != nil {
+			*reply = []byte(fmt.Sprint(err))
+			return
+		}
+		r = p
+	case line[0] == 'H' && len(line) == 1+common.AddressLength:
+		// configuration versions of escrow or multi signature account
+		r = blocks.GetAccountConfigHistory(line[1:])
+	default:
+		*reply = []byte("Invalid query MULT")
+		return
+	}
+	am, err := json.Marshal(r)
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleRCVR returns guardians and pending rotation of social recovery of account
+func handleRCVR(line []byte, reply *[]byte) {
+	if len(line) != common.AddressLength {
+		*reply = []byte("Invalid query RCVR")
+		return
+	}
+	r := account.GetRecoveryByAddressBytes(line)
+	copy(r.Address[:], line)
+	am, err := json.Marshal(r)
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleVEST returns vested, unvested and next release of vesting schedules of account
+func handleVEST(line []byte, reply *[]byte) {
+	if len(line) != common.AddressLength {
+		*reply = []byte("Invalid query VEST")
+		return
+	}
+	am, err := json.Marshal(account.GetVestingStatus(line, common.GetHeight()))
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleVALI returns registry of validators with commission history, uptime and jailing
+func handleVALI(line []byte, reply *[]byte) {
+	type validatorStatus struct {
+		account.Validator
+		Uptime float64 `json:"uptime"`
+	}
+	vs := []validatorStatus{}
+	for _, v := range account.GetAllValidators() {
+		vs = append(vs, validatorStatus{Validator: v, Uptime: v.Uptime()})
+	}
+	am, err := json.Marshal(vs)
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleORCL returns oracle history in range of heights: 'R' records, 'S' TWAP and volatility,
+// 'V' n(1) proposals of validator of delegated account n. Range is from(8) to(8) after query type.
+func handleORCL(line []byte, reply *[]byte) {
+	if len(line) < 17 || (line[0] == 'V' && len(line) != 18) {
+		*reply = []byte("Invalid query ORCL")
+		return
+	}
+	from := common.GetInt64FromByte(line[1:9])
+	to := common.GetInt64FromByte(line[9:17])
+	rs, err := oracles.LoadOracleRecords(from, to)
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	var r any
+	switch line[0] {
+	case 'R':
+		r = rs
+	case 'S':
+		r = oracles.CalculateOracleStats(rs, from, to)
+	case 'V':
+		r = oracles.AuditValidator(rs, int(line[17]))
+	default:
+		*reply = []byte("Invalid query ORCL")
+		return
+	}
+	am, err := json.Marshal(r)
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleGOVN returns governance proposals, passed parameter changes and current values of governed parameters
+func handleGOVN(line []byte, reply *[]byte) {
+	type governanceStatus struct {
+		Proposals []account.GovernanceProposal `json:"proposals"`
+		Changes   []common.ParamChange         `json:"changes"`
+		Params    map[string]int64             `json:"params"`
+	}
+	am, err := json.Marshal(governanceStatus{
+		Proposals: account.GetAllProposals(),
+		Changes:   account.GetParamChanges(),
+		Params:    common.GovernedParamValues(),
+	})
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleEVOT audits voting for encryption schemes: 'T' current tally of both schemes against thresholds,
+// 'H' history of applied scheme changes, 'R' from(8) to(8) recorded votes in range of heights
+func handleEVOT(line []byte, reply *[]byte) {
+	if len(line) < 1 {
+		*reply = []byte("Invalid query EVOT")
+		return
+	}
+	var r any
+	var err error
+	switch {
+	case line[0] == 'T' && len(line) == 1:
+		h := common.GetHeight()
+		totalStaked := account.GetStakedInAllDelegatedAccounts()
+		r = []voting.EncryptionTally{voting.Tally(h, totalStaked, true), voting.Tally(h, totalStaked, false)}
+	case line[0] == 'H' && len(line) == 1:
+		r, err = voting.GetSchemeChanges()
+	case line[0] == 'R' && len(line) == 17:
+		r, err = voting.LoadVoteRecordsInRange(common.GetInt64FromByte(line[1:9]), common.GetInt64FromByte(line[9:17]))
+	default:
+		*reply = []byte("Invalid query EVOT")
+		return
+	}
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	am, err := json.Marshal(r)
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleKEYS returns status of keys of account in primary and secondary scheme
+func handleKEYS(line []byte, reply *[]byte) {
+	if len(line) != common.AddressLength {
+		*reply = []byte("Invalid query KEYS")
+		return
+	}
+	a := common.Address{}
+	err := a.Init(line)
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	am, err := json.Marshal(pubkeys.GetKeyStatuses(a))
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleFINL returns finality of block at height(8), last block when height is not given
+func handleFINL(line []byte, reply *[]byte) {
+	height := common.GetHeight()
+	switch len(line) {
+	case 0:
+	case 8:
+		height = common.GetInt64FromByte(line)
+	default:
+		*reply = []byte("Invalid query FINL")
+		return
+	}
+	am, err := json.Marshal(blocks.GetFinalityStatus(height))
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	*reply = am
+}
+
+// handleMIGR replaces key of active wallet in replaced scheme, 0 primary or 1 secondary, and sends
+// transaction registering new key
+func handleMIGR(line []byte, reply *[]byte) {
+	if len(line) != 1 || line[0] > 1 {
+		*reply = []byte("Invalid query MIGR")
+		return
+	}
+	w := wallet.GetActiveWallet()
+	tx, err := transactionsDefinition.MigrateKey(w, line[0] == 0, common.GetHeight())
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	msg, err := transactionServices.GenerateTransactionMsg([]transactionsDefinition.Transaction{tx}, []byte("tx"), [2]byte{'T', 'T'})
+	if err != nil {
+		*reply = []byte(fmt.Sprint(err))
+		return
+	}
+	transactionServices.OnMessage([4]byte{0, 0, 0, 0}, msg.GetBytes())
+	*reply = []byte("Key migrated, registration tx hash: " + tx.Hash.GetHex())
 }
 
 func handleENCR(line []byte, reply *[]byte) {
@@ -421,23 +675,15 @@ func handleCNCL(byt []byte, reply *[]byte) {
 			}
 			transactionsPool.PoolsTx.BanTransactionByHash(byt)
 		}
+		// escrow and multi signature pools are part of chain state, transactions there
+		// are cancelled only with escrow cancellation or multi signature rejection
 		if transactionsPool.PoolTxEscrow.TransactionExists(byt) {
-			tx := transactionsPool.PoolTxEscrow.PopTransactionByHash(byt)
-			if bytes.Equal(tx.TxParam.Sender.GetBytes(), activeWallet.MainAddress.GetBytes()) == false {
-				transactionsPool.PoolTxEscrow.AddTransaction(tx, tx.Hash)
-				*reply = []byte("you are not the owner of transaction")
-				return
-			}
-			transactionsPool.PoolTxEscrow.BanTransactionByHash(byt)
+			*reply = []byte("escrowed transaction can be cancelled only by guardian or with secondary key")
+			return
 		}
 		if transactionsPool.PoolTxMultiSign.TransactionExists(byt) {
-			tx := transactionsPool.PoolTxMultiSign.PopTransactionByHash(byt)
-			if bytes.Equal(tx.TxParam.Sender.GetBytes(), activeWallet.MainAddress.GetBytes()) == false {
-				transactionsPool.PoolTxMultiSign.AddTransaction(tx, tx.Hash)
-				*reply = []byte("you are not the owner of transaction")
-				return
-			}
-			transactionsPool.PoolTxMultiSign.BanTransactionByHash(byt)
+			*reply = []byte("multi signature transaction can be only rejected by co-signers")
+			return
 		}
 		//TODO to prune DB from bad transactions from time to time
 		*reply = []byte("transaction cancelled")
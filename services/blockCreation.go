@@ -52,7 +52,7 @@ func CreateBlockFromNonceMessage(nonceTx []transactionsDefinition.Transaction,
 		if heightTransaction != heightLastBlocktransaction+1 {
 			return blocks.Block{}, fmt.Errorf("last block height and nonce height do not match")
 		}
-		encryption1, b, err = common.BytesWithLenToBytes(at.GetData().GetOptData()[56:])
+		encryption1, b, err = common.BytesWithLenToBytes(at.GetData().GetOptData()[48+oracles.RandProposalLength(heightTransaction):])
 		if err != nil {
 			return blocks.Block{}, err
 		}
@@ -74,13 +74,19 @@ func CreateBlockFromNonceMessage(nonceTx []transactionsDefinition.Transaction,
 		}
 	}
 
+	myDelegated, err := account.IntDelegatedAccountFromAddress(common.GetDelegatedAccount())
+	if err != nil {
+		return blocks.Block{}, err
+	}
+	if account.IsValidatorJailed(myDelegated) {
+		return blocks.Block{}, fmt.Errorf("validator of delegated account %v is jailed", myDelegated)
+	}
 	reward := account.GetReward(lastBlock.GetBlockSupply())
 	supply := lastBlock.GetBlockSupply() + reward
 
 	sendingTimeTransaction := nonceTx[0].GetParam().SendingTime
 	ti := sendingTimeTransaction - lastBlock.GetBlockTimeStamp()
-	bblock := lastBlock.GetBaseBlock()
-	diff := blocks.AdjustDifficulty(bblock.BaseHeader.Difficulty, ti)
+	diff := blocks.NextDifficulty(lastBlock, ti)
 	sendingTimeMessage := common.GetByteInt64(nonceTx[0].GetParam().SendingTime)
 	rootMerkleTrie := common.Hash{}
 	rootMerkleTrie.Set(merkleTrie.GetRootHash())
@@ -111,7 +117,7 @@ func CreateBlockFromNonceMessage(nonceTx []transactionsDefinition.Transaction,
 	if err != nil {
 		logger.GetLogger().Println("could not establish price oracle", err)
 	}
-	randOracle, randOracleData, err := oracles.CalculateRandOracle(heightTransaction, totalStaked)
+	randOracle, randOracleData, err := oracles.CalculateRandOracle(heightTransaction, totalStaked, lastBlock.BaseBlock.RandOracleData)
 	if err != nil {
 		logger.GetLogger().Println("could not establish rand oracle", err)
 	}
@@ -119,7 +125,7 @@ func CreateBlockFromNonceMessage(nonceTx []transactionsDefinition.Transaction,
 		BaseHeader:       bh,
 		BlockHeaderHash:  bhHash,
 		BlockTimeStamp:   common.GetCurrentTimeStampInSecond(),
-		RewardPercentage: common.GetMyRewardPercentage(),
+		RewardPercentage: account.GetValidatorCommission(myDelegated, common.GetMyRewardPercentage()),
 		Supply:           supply,
 		PriceOracle:      priceOracle,
 		RandOracle:       randOracle,
@@ -165,8 +171,10 @@ func SendNonce(ip [4]byte, nb []byte) {
 	SendMutexNonce.Unlock()
 }
 
+// BroadcastBlock sends compact block, peers rebuild it from their transactions pools
 func BroadcastBlock(bl blocks.Block) {
-	atm := GenerateBlockMessage(bl)
+	rememberBlock(bl)
+	atm := GenerateCompactBlockMessage(bl)
 	nb := atm.GetBytes()
 	var ip [4]byte
 	var peers = tcpip.GetPeersConnected(tcpip.NonceTopic)
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/okuralabs/okura-node/blocks"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/message"
+	"github.com/okuralabs/okura-node/tcpip"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+// number of last broadcasted blocks kept to answer requests for missing transactions
+const maxRecentBlocks = 16
+
+var (
+	compactBlockKey = [2]byte{'C', 0}
+	shortIDsKey     = [2]byte{'S', 'I'}
+	blockHashKey    = [2]byte{'B', 'H'}
+	blockHeightKey  = [2]byte{'L', 'H'}
+
+	recentBlocks      = map[common.Hash]blocks.Block{}
+	recentBlocksOrder []common.Hash
+	recentBlocksMutex sync.RWMutex
+)
+
+func rememberBlock(bl blocks.Block) {
+	recentBlocksMutex.Lock()
+	defer recentBlocksMutex.Unlock()
+	h := bl.GetBlockHash()
+	if _, ok := recentBlocks[h]; ok {
+		return
+	}
+	if len(recentBlocksOrder) >= maxRecentBlocks {
+		delete(recentBlocks, recentBlocksOrder[0])
+		recentBlocksOrder = recentBlocksOrder[1:]
+	}
+	recentBlocks[h] = bl
+	recentBlocksOrder = append(recentBlocksOrder, h)
+}
+
+// GetBlockByHash returns recently broadcasted block or block stored in DB at given height
+func GetBlockByHash(hash common.Hash, height int64) (blocks.Block, error) {
+	recentBlocksMutex.RLock()
+	bl, ok := recentBlocks[hash]
+	recentBlocksMutex.RUnlock()
+	if ok {
+		return bl, nil
+	}
+	bl, err := blocks.LoadBlock(height)
+	if err != nil {
+		return blocks.Block{}, err
+	}
+	if !bytes.Equal(bl.GetBlockHash().GetBytes(), hash.GetBytes()) {
+		return blocks.Block{}, fmt.Errorf("block not found: GetBlockByHash")
+	}
+	return bl, nil
+}
+
+// ShortTxID is short id of transaction in compact block, salted with hash of block
+func ShortTxID(hash common.Hash, blockHash common.Hash) common.ShortHash {
+	return transactionsPool.SaltedShortHash(hash.GetBytes(), blockHash.GetBytes())
+}
+
+// GenerateCompactBlockMessage builds "cb" message with block without transaction hashes and short ids of transactions
+func GenerateCompactBlockMessage(bl blocks.Block) message.TransactionsMessage {
+	bm := message.BaseMessage{
+		Head:    []byte("cb"),
+		ChainID: common.GetChainID(),
+	}
+	header := bl
+	header.TransactionsHashes = nil
+	ids := make([][]byte, 0, len(bl.TransactionsHashes))
+	for _, h := range bl.TransactionsHashes {
+		ids = append(ids, ShortTxID(h, bl.GetBlockHash()).GetBytes())
+	}
+	atm := message.TransactionsMessage{
+		BaseMessage: bm,
+		TransactionsBytes: map[[2]byte][][]byte{
+			compactBlockKey: {header.GetBytes()},
+			shortIDsKey:     ids,
+		},
+	}
+	return atm
+}
+
+// ParseCompactBlockMessage returns block without transaction hashes and short ids from "cb" message
+func ParseCompactBlockMessage(amsg message.AnyMessage) (blocks.Block, []common.ShortHash, error) {
+	txnbytes := amsg.GetTransactionsBytes()
+	bb, ok := txnbytes[compactBlockKey]
+	if !ok || len(bb) != 1 {
+		return blocks.Block{}, nil, fmt.Errorf("no block in message: ParseCompactBlockMessage")
+	}
+	bl, err := blocks.Block{}.GetFromBytes(bb[0])
+	if err != nil {
+		return blocks.Block{}, nil, err
+	}
+	if len(bl.TransactionsHashes) > 0 {
+		return blocks.Block{}, nil, fmt.Errorf("compact block should not have transactions hashes: ParseCompactBlockMessage")
+	}
+	ids := []common.ShortHash{}
+	for _, id := range txnbytes[shortIDsKey] {
+		if len(id) != common.ShortHashLength {
+			return blocks.Block{}, nil, fmt.Errorf("wrong length of short id: ParseCompactBlockMessage")
+		}
+		s := common.ShortHash{}
+		s.Set(id)
+		ids = append(ids, s)
+	}
+	return bl, ids, nil
+}
+
+// RebuildCompactBlock fills transactions hashes of block from transactions pool.
+// Returns short ids which could not be resolved.
+func RebuildCompactBlock(bl blocks.Block, ids []common.ShortHash) (blocks.Block, []common.ShortHash) {
+	found := transactionsPool.PoolsTx.HashesByShortHashes(ids, bl.GetBlockHash().GetBytes())
+	missing := []common.ShortHash{}
+	hashes := make([]common.Hash, 0, len(ids))
+	for _, id := range ids {
+		h, ok := found[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		hashes = append(hashes, h)
+	}
+	if len(missing) > 0 {
+		return bl, missing
+	}
+	bl.TransactionsHashes = hashes
+	return bl, nil
+}
+
+// SendGetBlockTransactions asks ip for block with transactions of given short ids, "gb" message
+func SendGetBlockTransactions(ip [4]byte, bl blocks.Block, missing []common.ShortHash) {
+	bm := message.BaseMessage{
+		Head:    []byte("gb"),
+		ChainID: common.GetChainID(),
+	}
+	ids := make([][]byte, 0, len(missing))
+	for _, id := range missing {
+		ids = append(ids, id.GetBytes())
+	}
+	atm := message.TransactionsMessage{
+		BaseMessage: bm,
+		TransactionsBytes: map[[2]byte][][]byte{
+			blockHashKey:   {bl.GetBlockHash().GetBytes()},
+			blockHeightKey: {common.GetByteInt64(bl.GetHeader().Height)},
+			shortIDsKey:    ids,
+		},
+	}
+	SendNonce(ip, atm.GetBytes())
+}
+
+// SendBlockWithTransactions answers "gb" message with full block and requested transactions in one "bl" message
+func SendBlockWithTransactions(ip [4]byte, amsg message.AnyMessage) error {
+	txnbytes := amsg.GetTransactionsBytes()
+	if len(txnbytes[blockHashKey]) != 1 || len(txnbytes[blockHeightKey]) != 1 ||
+		len(txnbytes[blockHashKey][0]) != common.HashLength || len(txnbytes[blockHeightKey][0]) != 8 {
+		return fmt.Errorf("wrong get block message: SendBlockWithTransactions")
+	}
+	hash := common.GetHashFromBytes(txnbytes[blockHashKey][0])
+	height := common.GetInt64FromByte(txnbytes[blockHeightKey][0])
+	bl, err := GetBlockByHash(hash, height)
+	if err != nil {
+		return err
+	}
+	wanted := map[common.ShortHash]struct{}{}
+	for _, id := range txnbytes[shortIDsKey] {
+		s := common.ShortHash{}
+		s.Set(id)
+		wanted[s] = struct{}{}
+	}
+	txs := [][]byte{}
+	for _, h := range bl.TransactionsHashes {
+		if _, ok := wanted[ShortTxID(h, hash)]; !ok {
+			continue
+		}
+		t, err := transactionsDefinition.LoadFromDBPoolTx(common.TransactionPoolHashesDBPrefix[:], h.GetBytes())
+		if err != nil {
+			t, err = transactionsDefinition.LoadFromDBPoolTx(common.TransactionDBPrefix[:], h.GetBytes())
+			if err != nil {
+				logger.GetLogger().Println("cannot load transaction requested in block", err)
+				continue
+			}
+		}
+		txs = append(txs, t.GetBytes())
+	}
+	atm := GenerateBlockMessage(bl)
+	atm.TransactionsBytes[tcpip.TransactionTopic] = txs
+	SendNonce(ip, atm.GetBytes())
+	return nil
+}
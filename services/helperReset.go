@@ -5,26 +5,12 @@ import (
 	"github.com/okuralabs/okura-node/blocks"
 	"github.com/okuralabs/okura-node/common"
 	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/oracles"
+	"github.com/okuralabs/okura-node/pubkeys"
 	"github.com/okuralabs/okura-node/transactionsPool"
+	"github.com/okuralabs/okura-node/voting"
 )
 
-func AdjustShiftInPastInReset(height int64) {
-	common.ShiftToPastMutex.Lock()
-	defer common.ShiftToPastMutex.Unlock()
-	h := common.GetHeight()
-	if height-h <= 0 {
-		common.ShiftToPastInReset = 1
-		return
-	}
-	common.ShiftToPastInReset *= 2
-	if common.ShiftToPastInReset > height {
-		common.ShiftToPastInReset = height
-	}
-	if common.ShiftToPastInReset < 1 {
-		common.ShiftToPastInReset = 1
-	}
-}
-
 func RevertVMToBlockHeight(height int64) bool {
 	blocks.StateMutex.Lock()
 	defer blocks.StateMutex.Unlock()
@@ -52,12 +38,12 @@ func ResetAccountsAndBlocksSync(height int64) {
 			return
 		}
 	}
-
-	err := account.LoadAccounts(height)
-	if err != nil {
-		return
+	if finalized := blocks.LastFinalizedHeight(); height < finalized {
+		logger.GetLogger().Println("chain cannot be reset below finalized checkpoint at", finalized)
+		height = finalized
 	}
-	err = account.LoadStakingAccounts(height)
+
+	err := LoadStateAtHeight(height)
 	if err != nil {
 		return
 	}
@@ -92,6 +78,38 @@ func ResetAccountsAndBlocksSync(height int64) {
 		if err != nil {
 			logger.GetLogger().Println(err)
 		}
+		err = blocks.RemoveMultiSignResultsFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		err = blocks.RemoveAccountConfigsFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		err = pubkeys.RemoveRevokedPubKeysFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		err = pubkeys.RemoveAddedPubKeysFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		err = blocks.RemoveEvidenceFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		err = oracles.RemoveOracleRecordFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		err = voting.RemoveSchemeChangesFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		err = blocks.RemoveCheckpointAttestationsFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
 	}
 	for i := ha; i > height; i-- {
 		err := account.RemoveAccountsFromDB(i)
@@ -112,6 +130,50 @@ func ResetAccountsAndBlocksSync(height int64) {
 		}
 	}
 
+	hp, err := transactionsPool.LastHeightStoredInPendingPools()
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	for i := hp; i > height; i-- {
+		err := transactionsPool.RemovePendingPoolsFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+
+	hr, err := account.LastHeightStoredInRecoveries()
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	for i := hr; i > height; i-- {
+		err := account.RemoveRecoveriesFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+
+	hv, err := account.LastHeightStoredInValidators()
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	for i := hv; i > height; i-- {
+		err := account.RemoveValidatorsFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+
+	hg, err := account.LastHeightStoredInGovernance()
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	for i := hg; i > height; i-- {
+		err := account.RemoveGovernanceFromDB(i)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+
 	hm, err := transactionsPool.LastHeightStoredInMerleTrie()
 	if err != nil {
 		logger.GetLogger().Println(err)
@@ -0,0 +1,91 @@
+package nonceServices
+
+import (
+	"encoding/json"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/blocks"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/message"
+	"github.com/okuralabs/okura-node/tcpip"
+	"github.com/okuralabs/okura-node/wallet"
+)
+
+// sendCheckpointAttestations signs last checkpoint when node operates delegated account and
+// broadcasts known attestations of checkpoint until it is finalized
+func sendCheckpointAttestations() {
+	if common.IsSyncing.Load() {
+		return
+	}
+	h := common.GetHeight()
+	cp := h - h%common.CheckpointInterval
+	if cp <= 0 || cp <= blocks.LastFinalizedHeight() {
+		return
+	}
+	as, err := blocks.LoadCheckpointAttestations(cp)
+	if err != nil {
+		as = []blocks.CheckpointAttestation{}
+	}
+	n, err := account.IntDelegatedAccountFromAddress(common.GetDelegatedAccount())
+	attested := false
+	for _, a := range as {
+		attested = attested || a.DelegatedAccount == n
+	}
+	if err == nil && !attested {
+		a, err := blocks.AttestCheckpoint(cp, n, wallet.GetActiveWallet())
+		if err == nil {
+			_, err = blocks.AddCheckpointAttestation(a)
+		}
+		if err != nil {
+			logger.GetLogger().Println("cannot attest checkpoint", cp, err)
+		} else {
+			as = append(as, a)
+		}
+	}
+	if len(as) == 0 {
+		return
+	}
+	ab := [][]byte{}
+	for _, a := range as {
+		b, err := json.Marshal(a)
+		if err != nil {
+			continue
+		}
+		ab = append(ab, b)
+	}
+	bm := message.BaseMessage{
+		Head:    []byte("ca"),
+		ChainID: common.GetChainID(),
+	}
+	msg := message.TransactionsMessage{
+		BaseMessage:       bm,
+		TransactionsBytes: map[[2]byte][][]byte{{'C', 'A'}: ab},
+	}
+	if !Send([4]byte{0, 0, 0, 0}, msg.GetBytes()) {
+		logger.GetLogger().Println("could not send checkpoint attestations")
+	}
+}
+
+// onCheckpointAttestations stores attestations of checkpoint received in "ca" message
+func onCheckpointAttestations(addr [4]byte, amsg message.AnyMessage) {
+	txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
+	for _, b := range txn[[2]byte{'C', 'A'}] {
+		a := blocks.CheckpointAttestation{}
+		err := json.Unmarshal(b, &a)
+		if err != nil {
+			logger.GetLogger().Println("wrong checkpoint attestation", err)
+			tcpip.ReduceAndCheckIfBanIP(addr)
+			return
+		}
+		if !a.HasValidSignatureLength() {
+			logger.GetLogger().Println("wrong length of signature of checkpoint attestation")
+			tcpip.ReduceAndCheckIfBanIP(addr)
+			return
+		}
+		_, err = blocks.AddCheckpointAttestation(a)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/okuralabs/okura-node/oracles"
 	"github.com/okuralabs/okura-node/services"
 	"github.com/okuralabs/okura-node/services/transactionServices"
+	"github.com/okuralabs/okura-node/snapshots"
 	"github.com/okuralabs/okura-node/statistics"
 	"github.com/okuralabs/okura-node/tcpip"
 	"github.com/okuralabs/okura-node/transactionsDefinition"
@@ -19,6 +20,10 @@ import (
 	"github.com/okuralabs/okura-node/voting"
 )
 
+// compactBlockRetries keeps hash of compact block of peer, for which all transactions were requested
+// after check failed. Guarded by common.BlockMutex.
+var compactBlockRetries = map[[4]byte]common.Hash{}
+
 func OnMessage(addr [4]byte, m []byte) {
 	if common.IsSyncing.Load() {
 		return
@@ -97,12 +102,13 @@ func OnMessage(addr [4]byte, m []byte) {
 		if err != nil {
 			logger.GetLogger().Println("could not save price oracle", err)
 		}
-		err = oracles.SaveRandOracle(common.GetInt64FromByte(optData[8:16]), nonceHeight, txDelAcc, stakedInDelAccInt)
+		randProposal := optData[8 : 8+oracles.RandProposalLength(nonceHeight)]
+		err = oracles.SaveRandOracle(randProposal, nonceHeight, txDelAcc, stakedInDelAccInt)
 		if err != nil {
 			logger.GetLogger().Println("could not save rand oracle", err)
 		}
 
-		vb, b2, err := common.BytesWithLenToBytes(optData[16:])
+		vb, b2, err := common.BytesWithLenToBytes(optData[8+oracles.RandProposalLength(nonceHeight):])
 		if err != nil {
 			logger.GetLogger().Println("could not save voting, parse bytes fails, 1", err)
 		}
@@ -184,77 +190,130 @@ func OnMessage(addr [4]byte, m []byte) {
 			logger.GetLogger().Println(err)
 			return
 		}
+		// transactions requested after compact block are attached to block message
+		txn, err := amsg.(message.TransactionsMessage).GetTransactionsFromBytes()
+		if err == nil {
+			if _, ok := txn[tcpip.TransactionTopic]; ok {
+				transactionServices.AddReceivedTransactions(addr, map[[2]byte][]transactionsDefinition.Transaction{tcpip.TransactionTopic: txn[tcpip.TransactionTopic]})
+			}
+		}
 		txnbytes := amsg.GetTransactionsBytes()
-		bls := map[[2]byte]blocks.Block{}
 		for k, v := range txnbytes {
 			if k[0] == byte('N') {
-
-				bls[k], err = bls[k].GetFromBytes(v[0])
-				newBlock := bls[k]
+				newBlock, err := blocks.Block{}.GetFromBytes(v[0])
 				if err != nil {
 					logger.GetLogger().Println(err)
 					logger.GetLogger().Println("cannot load blocks from bytes")
 					tcpip.ReduceAndCheckIfBanIP(addr)
 					return
 				}
+				processBlock(addr, newBlock, lastBlock, nil)
+			}
+		}
+	case "cb": //compact block
 
-				// Special logging for second block in nonce service
-				if newBlock.GetHeader().Height == 1 {
-					logger.GetLogger().Printf("=== Processing second block in nonce service ===")
-					logger.GetLogger().Printf("Current height: %d", h)
-					logger.GetLogger().Printf("Second block hash: %x", newBlock.BlockHash.GetBytes())
-					logger.GetLogger().Printf("Second block previous hash: %x", newBlock.GetHeader().PreviousHash.GetBytes())
-					logger.GetLogger().Printf("Genesis block hash: %x", lastBlock.BlockHash.GetBytes())
-					logger.GetLogger().Printf("Is initial sync: %v", h == 0)
-				}
-
-				if newBlock.GetHeader().Height != h+1 {
-					logger.GetLogger().Println("block of too short chain")
-					return
-				}
-				merkleTrie, err := blocks.CheckBaseBlock(newBlock, lastBlock)
-				defer merkleTrie.Destroy()
-				if err != nil {
-					logger.GetLogger().Println(err)
-					tcpip.ReduceAndCheckIfBanIP(addr)
-					return
-				}
-				hashesMissing := blocks.IsAllTransactions(newBlock)
-				if len(hashesMissing) > 0 {
-					transactionServices.SendGT(addr, hashesMissing, "st")
-					return
-				}
+		common.BlockMutex.Lock()
+		defer common.BlockMutex.Unlock()
 
-				err = blocks.CheckBlockAndTransferFunds(&newBlock, lastBlock, merkleTrie)
-				if err != nil {
-					services.ResetAccountsAndBlocksSync(lastBlock.GetHeader().Height)
-					logger.GetLogger().Println("check transfer transactions in block fails", err)
-					return
-				}
-				err = newBlock.StoreBlock()
-				if err != nil {
-					logger.GetLogger().Println(err)
-					logger.GetLogger().Println("cannot store block")
-					services.ResetAccountsAndBlocksSync(lastBlock.GetHeader().Height)
-					return
-				}
+		lastBlock, err := blocks.LoadBlock(h)
+		if err != nil {
+			logger.GetLogger().Println(err)
+			return
+		}
+		compactBlock, ids, err := services.ParseCompactBlockMessage(amsg)
+		if err != nil {
+			logger.GetLogger().Println(err)
+			tcpip.ReduceAndCheckIfBanIP(addr)
+			return
+		}
+		if compactBlock.GetHeader().Height != h+1 {
+			logger.GetLogger().Println("block of too short chain")
+			return
+		}
+		newBlock, missing := services.RebuildCompactBlock(compactBlock, ids)
+		if len(missing) > 0 {
+			services.SendGetBlockTransactions(addr, compactBlock, missing)
+			return
+		}
+		processBlock(addr, newBlock, lastBlock, ids)
+	case "ca": //checkpoint attestations
+		onCheckpointAttestations(addr, amsg)
+	case "gb": //get block transactions
+		err := services.SendBlockWithTransactions(addr, amsg)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	default:
+	}
+}
 
-				logger.GetLogger().Println("New Block success -------------------------------------", h+1)
-				err = account.StoreAccounts(newBlock.GetHeader().Height)
-				if err != nil {
-					logger.GetLogger().Println(err)
-				}
+// processBlock checks new block, transfers funds and stores it, needs common.BlockMutex locked.
+// Block rebuilt from compact block has its short ids, when check fails short id could match other
+// transaction in pool, so all transactions are requested instead of banning peer. Peer is penalized
+// when block with all transactions fails or when next compact block fails before the retry is answered.
+func processBlock(addr [4]byte, newBlock blocks.Block, lastBlock blocks.Block, ids []common.ShortHash) {
+	h := lastBlock.GetHeader().Height
+	// Special logging for second block in nonce service
+	if newBlock.GetHeader().Height == 1 {
+		logger.GetLogger().Printf("=== Processing second block in nonce service ===")
+		logger.GetLogger().Printf("Current height: %d", h)
+		logger.GetLogger().Printf("Second block hash: %x", newBlock.BlockHash.GetBytes())
+		logger.GetLogger().Printf("Second block previous hash: %x", newBlock.GetHeader().PreviousHash.GetBytes())
+		logger.GetLogger().Printf("Genesis block hash: %x", lastBlock.BlockHash.GetBytes())
+		logger.GetLogger().Printf("Is initial sync: %v", h == 0)
+	}
 
-				err = account.StoreStakingAccounts(newBlock.GetHeader().Height)
-				if err != nil {
-					logger.GetLogger().Println(err)
-				}
-				common.SetHeight(h + 1)
-				sm := statistics.GetStatsManager()
-				sm.UpdateStatistics(newBlock, lastBlock)
-				logger.GetLogger().Println("TPS: ", sm.Stats.Tps)
+	if newBlock.GetHeader().Height != h+1 {
+		logger.GetLogger().Println("block of too short chain")
+		return
+	}
+	if retried, ok := compactBlockRetries[addr]; ok && ids == nil && retried == newBlock.GetBlockHash() {
+		delete(compactBlockRetries, addr)
+	}
+	merkleTrie, err := blocks.CheckBaseBlock(newBlock, lastBlock)
+	defer merkleTrie.Destroy()
+	if err != nil {
+		logger.GetLogger().Println(err)
+		if ids != nil {
+			// peer which did not prove previous failed compact block with all transactions is penalized
+			if _, ok := compactBlockRetries[addr]; ok {
+				tcpip.ReduceAndCheckIfBanIP(addr)
+				return
 			}
+			compactBlockRetries[addr] = newBlock.GetBlockHash()
+			compactBlock := newBlock
+			compactBlock.TransactionsHashes = nil
+			services.SendGetBlockTransactions(addr, compactBlock, ids)
+			return
 		}
-	default:
+		tcpip.ReduceAndCheckIfBanIP(addr)
+		return
 	}
+	hashesMissing := blocks.IsAllTransactions(newBlock)
+	if len(hashesMissing) > 0 {
+		transactionServices.SendGT(addr, hashesMissing, "st")
+		return
+	}
+
+	err = blocks.CheckBlockAndTransferFunds(&newBlock, lastBlock, merkleTrie)
+	if err != nil {
+		services.ResetAccountsAndBlocksSync(lastBlock.GetHeader().Height)
+		logger.GetLogger().Println("check transfer transactions in block fails", err)
+		return
+	}
+	err = newBlock.StoreBlock()
+	if err != nil {
+		logger.GetLogger().Println(err)
+		logger.GetLogger().Println("cannot store block")
+		services.ResetAccountsAndBlocksSync(lastBlock.GetHeader().Height)
+		return
+	}
+
+	logger.GetLogger().Println("New Block success -------------------------------------", h+1)
+	services.StoreStateAtHeight(newBlock.GetHeader().Height)
+	common.SetHeight(h + 1)
+	sm := statistics.GetStatsManager()
+	sm.UpdateStatistics(newBlock, lastBlock)
+	logger.GetLogger().Println("TPS: ", sm.Stats.Tps)
+	snapshots.CreateSnapshotIfCheckpoint(newBlock.GetHeader().Height)
 }
@@ -6,13 +6,13 @@ import (
 	"github.com/okuralabs/okura-node/common"
 	"github.com/okuralabs/okura-node/logger"
 	"github.com/okuralabs/okura-node/message"
+	"github.com/okuralabs/okura-node/oracles"
 	"github.com/okuralabs/okura-node/pubkeys"
 	"github.com/okuralabs/okura-node/services"
 	"github.com/okuralabs/okura-node/tcpip"
 	"github.com/okuralabs/okura-node/transactionsDefinition"
 	"github.com/okuralabs/okura-node/voting"
 	"github.com/okuralabs/okura-node/wallet"
-	"golang.org/x/exp/rand"
 	"sync"
 	"time"
 )
@@ -106,11 +106,18 @@ func generateNonceMsg(topic [2]byte) (message.TransactionsMessage, error) {
 	optData := common.GetByteInt64(h)
 	optData = append(optData, lastBlockHash...)
 
-	//TODO Price oracle currently is random: 0.9 - 1.1 OKU/USD
-	priceOracle := int64(rand.Intn(10000000) - 5000000 + 100000000)
-	randOracle := rand.Int63()
+	// price from sources configured in PRICE_SOURCES, 0 is not counted in price oracle
+	priceOracle := oracles.GetPriceProposal()
 	optData = append(optData, common.GetByteInt64(priceOracle)...)
-	optData = append(optData, common.GetByteInt64(randOracle)...)
+	// reveal of secret committed in the latest included nonce and commit of secret for this height
+	revealHeight := h
+	if id, err := common.GetIDFromDelegatedAccountAddress(common.GetDelegatedAccount()); err == nil && id > 0 && id < 256 {
+		revealHeight = oracles.LastCommitHeight(uint8(id), h+1, func(height int64) ([]byte, error) {
+			b, err := blocks.LoadBlock(height)
+			return b.BaseBlock.RandOracleData, err
+		})
+	}
+	optData = append(optData, oracles.GetRandProposal(h+1, revealHeight)...)
 
 	voting.VotesEncryptionMutex.Lock()
 	if voting.AfterReset {
@@ -223,6 +230,7 @@ func sendNonceMsgInLoop() {
 	for range time.Tick(time.Second * 5) {
 		var topic = [2]byte{'N', 'N'}
 		sendNonceMsg([4]byte{0, 0, 0, 0}, topic)
+		sendCheckpointAttestations()
 	}
 }
 
@@ -232,7 +240,7 @@ func startPublishingNonceMsg() {
 }
 
 func StartSubscribingNonceMsg(ip [4]byte) {
-	recvChan := make(chan []byte, 10) // Use a buffered channel
+	recvChan := make(chan []byte, common.PeerReceiveBufferSize)
 	quit := false
 	var ipr [4]byte
 	go tcpip.StartNewConnection(ip, recvChan, tcpip.NonceTopic)
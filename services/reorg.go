@@ -0,0 +1,224 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/blocks"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/pubkeys"
+	"github.com/okuralabs/okura-node/snapshots"
+	"github.com/okuralabs/okura-node/statistics"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+// StoreStateAtHeight stores state of accounts, staking, DEX, pools, recoveries, validators
+// and governance after block at height was applied
+func StoreStateAtHeight(height int64) {
+	err := account.StoreAccounts(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = account.StoreStakingAccounts(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = account.StoreDexAccounts(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = transactionsPool.StorePendingPools(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = account.StoreRecoveries(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = account.StoreValidators(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = account.StoreGovernance(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+}
+
+// LoadStateAtHeight restores state stored by StoreStateAtHeight, state missing for height because
+// it was stored before upgrade is cleared
+func LoadStateAtHeight(height int64) error {
+	err := account.LoadAccounts(height)
+	if err != nil {
+		return err
+	}
+	err = account.LoadStakingAccounts(height)
+	if err != nil {
+		return err
+	}
+	err = account.LoadDexAccounts(height)
+	if err != nil {
+		// DEX accounts were not stored per block before upgrade
+		logger.GetLogger().Println(err)
+	}
+	err = transactionsPool.LoadPendingPools(height)
+	if err != nil {
+		// pools stored before upgrade are unknown, pending transactions are dropped
+		logger.GetLogger().Println(err)
+		transactionsPool.PoolTxEscrow.Clear()
+		transactionsPool.PoolTxMultiSign.Clear()
+	}
+	err = account.LoadRecoveries(height)
+	if err != nil {
+		// recoveries stored before upgrade are unknown
+		logger.GetLogger().Println(err)
+		account.ClearRecoveries()
+	}
+	err = account.LoadValidators(height)
+	if err != nil {
+		// validators stored before upgrade are unknown
+		logger.GetLogger().Println(err)
+		account.ClearValidators()
+	}
+	err = account.LoadGovernance(height)
+	if err != nil {
+		// governance stored before upgrade is unknown
+		logger.GetLogger().Println(err)
+		account.ClearGovernance()
+	}
+	err = account.ApplyGovernedParams(height)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	return nil
+}
+
+// applyBlocks verifies and applies consecutive blocks on top of last block, returns number of applied blocks
+func applyBlocks(bls []blocks.Block) (int, error) {
+	for i, block := range bls {
+		oldBlock, err := blocks.LoadBlock(common.GetHeight())
+		if err != nil {
+			return i, err
+		}
+		if missing := blocks.IsAllTransactions(block); len(missing) > 0 {
+			return i, fmt.Errorf("%v transactions of block %v are missing: applyBlocks", len(missing), block.GetHeader().Height)
+		}
+		merkleTrie, err := blocks.CheckBaseBlock(block, oldBlock)
+		if err != nil {
+			merkleTrie.Destroy()
+			return i, err
+		}
+		err = blocks.CheckBlockAndTransferFunds(&block, oldBlock, merkleTrie)
+		merkleTrie.Destroy()
+		if err != nil {
+			return i, err
+		}
+		err = block.StoreBlock()
+		if err != nil {
+			return i, err
+		}
+		StoreStateAtHeight(block.GetHeader().Height)
+		common.SetHeight(block.GetHeader().Height)
+		statistics.GetStatsManager().UpdateStatistics(block, oldBlock)
+		snapshots.CreateSnapshotIfCheckpoint(block.GetHeader().Height)
+	}
+	return len(bls), nil
+}
+
+// rollBack resets chain to height, state of block which failed to apply above last block is forgotten as well
+func rollBack(height int64) {
+	err := pubkeys.RemoveAddedPubKeysFromDB(common.GetHeight() + 1)
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	ResetAccountsAndBlocksSync(height)
+}
+
+// Reorg switches chain to branch following our block at forkHeight when branch has higher
+// cumulative difficulty. Accounts, staking, DEX, pubkeys, pools and EVM state are rolled back
+// to fork point and branch is replayed. Replaced blocks are kept as competing branch and
+// restored when branch fails to verify. Needs common.BlockMutex.
+func Reorg(forkHeight int64, branch []blocks.Block) error {
+	h := common.GetHeight()
+	if len(branch) == 0 {
+		return fmt.Errorf("empty branch: Reorg")
+	}
+	if forkHeight < 0 || forkHeight > h {
+		return fmt.Errorf("wrong fork height %v: Reorg", forkHeight)
+	}
+	if h-forkHeight > common.MaxReorgDepth {
+		return fmt.Errorf("fork at %v is deeper than %v blocks: Reorg", forkHeight, common.MaxReorgDepth)
+	}
+	if forkHeight < blocks.LastFinalizedHeight() {
+		return fmt.Errorf("fork at %v is below finalized checkpoint: Reorg", forkHeight)
+	}
+	err := blocks.CheckBranchLinks(forkHeight, branch)
+	if err != nil {
+		return err
+	}
+	better, err := blocks.IsBetterBranch(forkHeight, branch)
+	if err != nil {
+		return err
+	}
+	if !better {
+		return fmt.Errorf("branch has not higher cumulative difficulty: Reorg")
+	}
+	blocks.ReturnTransactionsToPool(branch)
+	for _, bl := range branch {
+		if missing := blocks.IsAllTransactions(bl); len(missing) > 0 {
+			return fmt.Errorf("transactions of block %v in branch are missing: Reorg", bl.GetHeader().Height)
+		}
+	}
+
+	replaced := []blocks.Block{}
+	for i := forkHeight + 1; i <= h; i++ {
+		bl, err := blocks.LoadBlock(i)
+		if err != nil {
+			return err
+		}
+		err = blocks.StoreBranchBlock(bl)
+		if err != nil {
+			return err
+		}
+		replaced = append(replaced, bl)
+	}
+	blocks.ReturnTransactionsToPool(replaced)
+
+	logger.GetLogger().Println("reorg from height", h, "to branch from fork at", forkHeight, "with", len(branch), "blocks")
+	common.IsSyncing.Store(true)
+	defer func() {
+		if common.GetHeight() > common.CurrentHeightOfNetwork {
+			common.IsSyncing.Store(false)
+		}
+	}()
+	ResetAccountsAndBlocksSync(forkHeight)
+	if common.GetHeight() != forkHeight {
+		return fmt.Errorf("cannot reset chain to fork height %v: Reorg", forkHeight)
+	}
+	n, err := applyBlocks(branch)
+	if err != nil {
+		logger.GetLogger().Println("branch fails at height", forkHeight+int64(n)+1, err, "restore previous chain")
+		rollBack(forkHeight)
+		_, err2 := applyBlocks(replaced)
+		if err2 != nil {
+			logger.GetLogger().Println("cannot restore previous chain", err2)
+			return err
+		}
+		for _, bl := range replaced {
+			blocks.RemoveBranchBlock(bl)
+		}
+		return err
+	}
+	for _, bl := range branch {
+		err = blocks.RemoveBranchBlock(bl)
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+	err = blocks.PruneBranches(common.GetHeight())
+	if err != nil {
+		logger.GetLogger().Println(err)
+	}
+	return nil
+}
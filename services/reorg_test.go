@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+type reorgTestState struct {
+	Accounts    account.AccountsType
+	Dex         account.DexAccountsType
+	Escrow      [][]byte
+	Validators  []account.Validator
+	Proposals   []account.GovernanceProposal
+	ParamChange []common.ParamChange
+}
+
+// captureState returns copy of state which has to be the same on nodes at the same block
+func captureState(t *testing.T) reorgTestState {
+	s := reorgTestState{}
+	if err := s.Accounts.Unmarshal(account.Accounts.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Dex.Unmarshal(account.DexAccounts.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range transactionsPool.PoolTxEscrow.GetAllTransactions() {
+		s.Escrow = append(s.Escrow, tx.Hash.GetBytes())
+	}
+	sort.Slice(s.Escrow, func(i, j int) bool { return bytes.Compare(s.Escrow[i], s.Escrow[j]) < 0 })
+	s.Validators = account.GetAllValidators()
+	s.Proposals = account.GetAllProposals()
+	s.ParamChange = account.GetParamChanges()
+	return s
+}
+
+func escrowTestTx(sender byte, height int64) transactionsDefinition.Transaction {
+	tx := transactionsDefinition.Transaction{Height: height, GasPrice: 1, GasUsage: 21000}
+	tx.TxParam.ChainID = common.GetChainID()
+	tx.TxParam.Sender = common.Address{ByteValue: [common.AddressLength]byte{sender}, Primary: true}
+	tx.TxParam.SendingTime = height
+	tx.TxData.Amount = height
+	tx.Signature = common.Signature{ByteValue: append([]byte{0}, make([]byte, common.SignatureLength())...), Primary: true}
+	_ = tx.CalcHashAndSet()
+	return tx
+}
+
+// applyBranchBlock changes accounts, DEX, escrow pool, validators and governance as block of branch
+// at height would and stores state like applyBlocks does
+func applyBranchBlock(branch byte, height int64) {
+	addr := [common.AddressLength]byte{branch}
+	account.SetBalance(addr, account.GetBalance(addr)+height)
+	dex := account.GetDexAccountByAddressBytes(addr[:])
+	dex.CoinPool += height
+	account.SetDexAccountByAddressBytes(addr[:], dex)
+	v, _ := account.GetValidator(int(branch))
+	v.DelegatedAccount = int(branch)
+	v.BlocksProduced++
+	account.SetValidator(v)
+	account.AddProposal(account.GovernanceProposal{Name: "MaxGasPrice", Value: height, Height: height,
+		VotingEnd: height + 10, Status: account.ProposalVoting, Votes: map[int]bool{int(branch): true}})
+	transactionsPool.PoolTxEscrow.AddTransaction(escrowTestTx(branch, height), common.Hash{})
+	StoreStateAtHeight(height)
+}
+
+func TestReorg_StateMatchesNodeSyncedBranchDirectly(t *testing.T) {
+	const forkHeight = int64(1000)
+	account.Accounts = account.AccountsType{AllAccounts: map[[common.AddressLength]byte]account.Account{}}
+	account.DexAccounts = account.DexAccountsType{AllDexAccounts: map[[common.AddressLength]byte]account.DexAccount{}}
+	account.ClearValidators()
+	account.ClearGovernance()
+	transactionsPool.PoolTxEscrow.Clear()
+	applyBranchBlock(1, forkHeight)
+
+	// node which synced heavier branch 2 directly
+	if err := LoadStateAtHeight(forkHeight); err != nil {
+		t.Fatal(err)
+	}
+	for h := forkHeight + 1; h <= forkHeight+3; h++ {
+		applyBranchBlock(2, h)
+	}
+	direct := captureState(t)
+
+	// node which followed branch 3 and reorganizes to branch 2
+	if err := LoadStateAtHeight(forkHeight); err != nil {
+		t.Fatal(err)
+	}
+	for h := forkHeight + 1; h <= forkHeight+2; h++ {
+		applyBranchBlock(3, h)
+	}
+	if err := LoadStateAtHeight(forkHeight); err != nil {
+		t.Fatal(err)
+	}
+	for h := forkHeight + 1; h <= forkHeight+3; h++ {
+		applyBranchBlock(2, h)
+	}
+	reorged := captureState(t)
+
+	if !reflect.DeepEqual(direct, reorged) {
+		t.Errorf("Expected state after reorg equal to state of node synced branch directly, got\n%+v\n%+v", reorged, direct)
+	}
+	if len(reorged.Escrow) != 4 || len(reorged.Validators) != 2 || len(reorged.Proposals) != 4 {
+		t.Errorf("Expected state of fork block and branch only, got %v escrowed, %v validators, %v proposals",
+			len(reorged.Escrow), len(reorged.Validators), len(reorged.Proposals))
+	}
+}
@@ -0,0 +1,94 @@
+package syncServices
+
+import (
+	"bytes"
+
+	"github.com/okuralabs/okura-node/blocks"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/services"
+	"github.com/okuralabs/okura-node/services/transactionServices"
+)
+
+// hasMoreWork tells whether peer announced chain with higher cumulative difficulty than ours,
+// peers which do not announce it are compared by height
+func hasMoreWork(txn map[[2]byte][][]byte, otherHeight int64, h int64) bool {
+	cd := txn[[2]byte{'C', 'D'}]
+	if len(cd) != 1 || len(cd[0]) != 8 {
+		return otherHeight >= h
+	}
+	ours, err := blocks.LoadCumulativeDifficulty(h)
+	if err != nil {
+		return otherHeight >= h
+	}
+	return common.GetInt64FromByte(cd[0]) > ours
+}
+
+// onFork handles blocks of peer which differ from ours starting with first one. Blocks are kept as
+// competing branch, fork point is searched in earlier blocks of peer and chain is reorganized when
+// branch has higher cumulative difficulty.
+func onFork(addr [4]byte, indices []int64, blcks []blocks.Block) {
+	h := common.GetHeight()
+	forkHeight := indices[0] - 1
+	if h-forkHeight > common.MaxReorgDepth {
+		logger.GetLogger().Println("fork at", forkHeight, "is deeper than", common.MaxReorgDepth, "blocks, ignored")
+		return
+	}
+	if forkHeight < blocks.LastFinalizedHeight() {
+		logger.GetLogger().Println("fork at", forkHeight, "is below finalized checkpoint, ignored")
+		return
+	}
+	for _, bl := range blcks {
+		err := blocks.StoreBranchBlock(bl)
+		if err != nil {
+			logger.GetLogger().Println(err)
+			return
+		}
+	}
+	prevHash, err := blocks.LoadHashOfBlock(forkHeight)
+	if err != nil {
+		logger.GetLogger().Println(err)
+		return
+	}
+	if !bytes.Equal(blcks[0].GetHeader().PreviousHash.GetBytes(), prevHash) {
+		// fork point is below received blocks
+		bHeight := forkHeight - common.NumberOfHashesInBucket + 1
+		if bHeight < h-common.MaxReorgDepth {
+			bHeight = h - common.MaxReorgDepth
+		}
+		if bHeight < blocks.LastFinalizedHeight() {
+			bHeight = blocks.LastFinalizedHeight()
+		}
+		SendGetHeadersRange(addr, bHeight, forkHeight)
+		return
+	}
+	branch, err := blocks.BestBranch(forkHeight)
+	if err != nil || len(branch) == 0 {
+		return
+	}
+	better, err := blocks.IsBetterBranch(forkHeight, branch)
+	if err != nil || !better {
+		logger.GetLogger().Println("competing branch from fork at", forkHeight, "has not higher cumulative difficulty")
+		return
+	}
+	blocks.ReturnTransactionsToPool(branch)
+	hashesMissing := [][]byte{}
+	for _, bl := range branch {
+		hashesMissing = append(hashesMissing, blocks.IsAllTransactions(bl)...)
+	}
+	if len(hashesMissing) > 0 {
+		logger.GetLogger().Printf("Competing branch is missing %d transactions, requesting from peer", len(hashesMissing))
+		transactionServices.SendGT(addr, hashesMissing, "bt")
+		return
+	}
+	common.BlockMutex.Lock()
+	err = services.Reorg(forkHeight, branch)
+	common.BlockMutex.Unlock()
+	if err != nil {
+		logger.GetLogger().Println(err)
+		return
+	}
+	syncMgr.mutex.Lock()
+	syncMgr.reset()
+	syncMgr.mutex.Unlock()
+}
@@ -6,13 +6,13 @@ import (
 
 	"github.com/okuralabs/okura-node/logger"
 
-	"github.com/okuralabs/okura-node/account"
 	"github.com/okuralabs/okura-node/blocks"
 	"github.com/okuralabs/okura-node/common"
 	"github.com/okuralabs/okura-node/message"
 	"github.com/okuralabs/okura-node/services"
 	nonceServices "github.com/okuralabs/okura-node/services/nonceService"
 	"github.com/okuralabs/okura-node/services/transactionServices"
+	"github.com/okuralabs/okura-node/snapshots"
 	"github.com/okuralabs/okura-node/statistics"
 	"github.com/okuralabs/okura-node/tcpip"
 	"github.com/okuralabs/okura-node/transactionsPool"
@@ -85,31 +85,37 @@ func OnMessage(addr [4]byte, m []byte) {
 		if lastOtherHeight > hMax {
 			common.SetHeightMax(lastOtherHeight)
 		}
+		syncMgr.UpdatePeer(addr, lastOtherHeight)
+		if IsFastSyncing() {
+			snapshotSync.onPeer(addr, lastOtherHeight)
+			return
+		}
 		lastOtherBlockHashBytes := txn[[2]byte{'L', 'B'}][0]
-		if lastOtherHeight == h {
-			services.AdjustShiftInPastInReset(lastOtherHeight)
-			lastBlockHashBytes, err := blocks.LoadHashOfBlock(h)
+		if lastOtherHeight <= h {
+			lastBlockHashBytes, err := blocks.LoadHashOfBlock(lastOtherHeight)
 			if err != nil {
-				panic(err)
-			}
-			if !bytes.Equal(lastOtherBlockHashBytes, lastBlockHashBytes) {
-				SendGetHeaders(addr, lastOtherHeight)
+				// blocks below base of snapshot are not stored, peer so far behind has no better chain
+				logger.GetLogger().Println("cannot compare last block of peer at height", lastOtherHeight, err)
+				return
 			}
-			if lastOtherHeight > hMax {
-				common.IsSyncing.Store(false)
+			// competing chain is downloaded only when it has higher cumulative difficulty
+			if !bytes.Equal(lastOtherBlockHashBytes, lastBlockHashBytes) && hasMoreWork(txn, lastOtherHeight, h) {
+				if lastOtherHeight == h {
+					SendGetHeaders(addr, lastOtherHeight)
+				} else {
+					SendGetHeadersRange(addr, lastOtherHeight-common.NumberOfHashesInBucket+1, lastOtherHeight)
+				}
 			}
-			return
-		} else if lastOtherHeight < h {
-			services.AdjustShiftInPastInReset(lastOtherHeight)
 			if lastOtherHeight > hMax {
 				common.IsSyncing.Store(false)
 			}
 			return
 		}
-		// when others have longer chain
-		SendGetHeaders(addr, lastOtherHeight)
+		// when others have longer chain windows of blocks are downloaded in parallel by sync manager
+		syncMgr.Tick()
 		return
 	case "sh":
+		tcpip.FinishRequest(addr)
 
 		txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
 		blcks := []blocks.Block{}
@@ -133,15 +139,45 @@ func OnMessage(addr [4]byte, m []byte) {
 				}
 			}
 		}
-		hmax := common.GetHeightMax()
-		if indices[len(indices)-1] <= h {
-			logger.GetLogger().Println("shorter other chain")
+		if len(indices) == 0 || len(indices) != len(blcks) {
+			logger.GetLogger().Println("wrong headers message")
+			return
+		}
+		if syncMgr.OnHeaders(addr, indices, blcks) {
 			return
 		}
 		if indices[0] > h {
 			logger.GetLogger().Println("too far blocks of other")
 			return
 		}
+		if !checkHeaders(indices, blcks) {
+			logger.GetLogger().Println("wrong headers from", addr)
+			tcpip.ReduceAndCheckIfBanIP(addr)
+			return
+		}
+		// blocks which differ from ours are competing branch
+		for i, index := range indices {
+			if index > h {
+				break
+			}
+			if index <= 0 {
+				continue
+			}
+			hashOfMyBlockBytes, err := blocks.LoadHashOfBlock(index)
+			if err != nil {
+				logger.GetLogger().Printf("ERROR: Failed to load block hash for index %d: %v", index, err)
+				return
+			}
+			if !bytes.Equal(blcks[i].BlockHash.GetBytes(), hashOfMyBlockBytes) {
+				logger.GetLogger().Printf("Block hash mismatch at index %d - fork detected", index)
+				onFork(addr, indices[i:], blcks[i:])
+				return
+			}
+		}
+		if indices[len(indices)-1] <= h {
+			logger.GetLogger().Println("shorter other chain")
+			return
+		}
 		// check blocks
 		was := false
 		incompleteTxn := false
@@ -161,26 +197,9 @@ func OnMessage(addr [4]byte, m []byte) {
 			block := blcks[i]
 			oldBlock := blocks.Block{}
 			if index <= h {
-				hashOfMyBlockBytes, err := blocks.LoadHashOfBlock(index)
-				if err != nil {
-					logger.GetLogger().Printf("ERROR: Failed to load block hash for index %d: %v", index, err)
-					defer services.AdjustShiftInPastInReset(hmax)
-					common.ShiftToPastMutex.RLock()
-					defer common.ShiftToPastMutex.RUnlock()
-					services.ResetAccountsAndBlocksSync(index - common.ShiftToPastInReset)
-					panic("cannot load block hash")
-				}
-				if bytes.Equal(block.BlockHash.GetBytes(), hashOfMyBlockBytes) {
-					logger.GetLogger().Printf("Block %d matches existing block, marking as lastGoodBlock", index)
-					lastGoodBlock = index
-					continue
-				}
-				logger.GetLogger().Printf("Block hash mismatch at index %d - potential fork detected", index)
-				defer services.AdjustShiftInPastInReset(hmax)
-				common.ShiftToPastMutex.RLock()
-				defer common.ShiftToPastMutex.RUnlock()
-				services.ResetAccountsAndBlocksSync(index - common.ShiftToPastInReset)
-				panic("potential fork detected")
+				// blocks up to our height match ours
+				lastGoodBlock = index
+				continue
 			}
 			if was {
 				oldBlock = blcks[i-1]
@@ -238,27 +257,13 @@ func OnMessage(addr [4]byte, m []byte) {
 					block.GetHeader().PreviousHash.GetBytes())
 			}
 
-			if header.Height != index {
-				logger.GetLogger().Printf("ERROR: Height mismatch - Block header height: %d, Expected index: %d", header.Height, index)
-				defer services.AdjustShiftInPastInReset(hmax)
-				common.ShiftToPastMutex.RLock()
-				defer common.ShiftToPastMutex.RUnlock()
-				services.ResetAccountsAndBlocksSync(index - common.ShiftToPastInReset)
-				panic("not relevant height vs index")
-			}
-
 			logger.GetLogger().Printf("Performing base block verification for block %d", index)
 			merkleTrie, err := blocks.CheckBaseBlock(block, oldBlock)
 			defer merkleTrie.Destroy()
 			if err != nil {
 				logger.GetLogger().Printf("ERROR: Base block verification failed for block %d: %v", index, err)
 				tcpip.ReduceAndCheckIfBanIP(addr)
-				services.AdjustShiftInPastInReset(hmax)
-				common.ShiftToPastMutex.RLock()
-				defer common.ShiftToPastMutex.RUnlock()
-				services.ResetAccountsAndBlocksSync(index - common.ShiftToPastInReset)
-				panic(err)
-
+				return
 			}
 			merkleTries[index] = merkleTrie
 			hashesMissing := blocks.IsAllTransactions(block)
@@ -332,22 +337,35 @@ func OnMessage(addr [4]byte, m []byte) {
 			}
 
 			logger.GetLogger().Println("Sync New Block success -------------------------------------", block.GetHeader().Height)
-			err = account.StoreAccounts(block.GetHeader().Height)
-			if err != nil {
-				logger.GetLogger().Println(err)
-			}
-
-			err = account.StoreStakingAccounts(block.GetHeader().Height)
-			if err != nil {
-				logger.GetLogger().Println(err)
-			}
+			services.StoreStateAtHeight(block.GetHeader().Height)
 			common.SetHeight(block.GetHeader().Height)
 
 			sm := statistics.GetStatsManager()
 			sm.UpdateStatistics(block, oldBlock)
+			snapshots.CreateSnapshotIfCheckpoint(block.GetHeader().Height)
 
 		}
 
+	case "gs":
+		txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
+		if len(txn[[2]byte{'L', 'H'}]) != 1 {
+			return
+		}
+		SendSnapshotManifest(addr, common.GetInt64FromByte(txn[[2]byte{'L', 'H'}][0]))
+	case "ss":
+		tcpip.FinishRequest(addr)
+		txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
+		if len(txn[[2]byte{'S', 'M'}]) != 1 {
+			return
+		}
+		snapshotSync.onManifest(addr, txn[[2]byte{'S', 'M'}][0])
+	case "gc":
+		txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
+		SendSnapshotChunks(addr, txn[[2]byte{'C', 'H'}])
+	case "sc":
+		tcpip.FinishRequest(addr)
+		txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
+		snapshotSync.onChunks(addr, txn[[2]byte{'S', 'C'}])
 	case "gh":
 
 		txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
@@ -38,6 +38,12 @@ func generateSyncMsgHeight() []byte {
 		return []byte("")
 	}
 	n.TransactionsBytes[[2]byte{'L', 'B'}] = [][]byte{lastBlockHash}
+	cumulativeDifficulty, err := blocks.LoadCumulativeDifficulty(h)
+	if err != nil {
+		logger.GetLogger().Println("Can not obtain cumulative difficulty from DB", err)
+	} else {
+		n.TransactionsBytes[[2]byte{'C', 'D'}] = [][]byte{common.GetByteInt64(cumulativeDifficulty)}
+	}
 
 	peers := tcpip.GetIPsConnected()
 
@@ -128,12 +134,32 @@ func SendHeaders(addr [4]byte, bHeight int64, height int64) {
 }
 
 func SendGetHeaders(addr [4]byte, height int64) {
+	if !tcpip.StartRequest(addr) {
+		// too many headers requests to this peer are waiting for answer
+		return
+	}
 	n := generateSyncMsgGetHeaders(height)
 	if !Send(addr, n) {
 		logger.GetLogger().Println("could not send get headers")
 	}
 }
 
+// SendGetHeadersRange asks peer for blocks from bHeight to eHeight, used to find fork point
+func SendGetHeadersRange(addr [4]byte, bHeight int64, eHeight int64) {
+	if bHeight < 0 {
+		bHeight = 0
+	}
+	if eHeight < bHeight {
+		return
+	}
+	if !tcpip.StartRequest(addr) {
+		return
+	}
+	if !Send(addr, generateSyncMsgGetHeadersRange(bHeight, eHeight)) {
+		logger.GetLogger().Println("could not send get headers")
+	}
+}
+
 func Send(addr [4]byte, nb []byte) bool {
 	nb = append(addr[:], nb...)
 	if services.SendMutexSync.TryLock() {
@@ -150,6 +176,7 @@ func sendSyncMsgInLoop() {
 		if !Send([4]byte{0, 0, 0, 0}, n) {
 			logger.GetLogger().Println("could not send 'hi' message")
 		}
+		syncMgr.Tick()
 	}
 }
 
@@ -160,7 +187,7 @@ func startPublishingSyncMsg() {
 
 func StartSubscribingSyncMsg(ip [4]byte) {
 
-	recvChan := make(chan []byte, 10) // Use a buffered channel
+	recvChan := make(chan []byte, common.PeerReceiveBufferSize)
 	var ipr [4]byte
 	quit := false
 	go tcpip.StartNewConnection(ip, recvChan, tcpip.SyncTopic)
@@ -0,0 +1,176 @@
+package syncServices
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/message"
+	"github.com/okuralabs/okura-node/snapshots"
+	"github.com/okuralabs/okura-node/statistics"
+	"github.com/okuralabs/okura-node/tcpip"
+)
+
+// number of chunks requested from one peer in one message
+const chunksPerRequest = 4
+
+// snapshotDownload downloads state snapshot at trusted checkpoint before blocks are synced
+type snapshotDownload struct {
+	manifest          *snapshots.Manifest
+	manifestRequested map[[4]byte]time.Time
+	chunksRequested   map[common.Hash]time.Time
+	done              bool
+	mutex             sync.Mutex
+}
+
+var snapshotSync = &snapshotDownload{
+	manifestRequested: map[[4]byte]time.Time{},
+	chunksRequested:   map[common.Hash]time.Time{},
+}
+
+// IsFastSyncing is true when node waits for state snapshot of trusted checkpoint
+func IsFastSyncing() bool {
+	if common.TrustedCheckpointHeight <= 0 {
+		return false
+	}
+	snapshotSync.mutex.Lock()
+	defer snapshotSync.mutex.Unlock()
+	return !snapshotSync.done && common.GetHeight() < common.TrustedCheckpointHeight
+}
+
+// onPeer requests manifest or next chunks from peer which has checkpoint height
+func (sd *snapshotDownload) onPeer(addr [4]byte, peerHeight int64) {
+	if peerHeight < common.TrustedCheckpointHeight {
+		return
+	}
+	sd.mutex.Lock()
+	defer sd.mutex.Unlock()
+	if sd.manifest == nil {
+		if t, ok := sd.manifestRequested[addr]; ok && time.Since(t) < requestTimeout() {
+			return
+		}
+		if !tcpip.StartRequest(addr) {
+			return
+		}
+		sd.manifestRequested[addr] = time.Now()
+		n := generateSnapshotMsg("gs", [2]byte{'L', 'H'}, [][]byte{common.GetByteInt64(common.TrustedCheckpointHeight)})
+		Send(addr, n)
+		return
+	}
+	if !tcpip.StartRequest(addr) {
+		return
+	}
+	hashes := [][]byte{}
+	for _, h := range snapshots.MissingChunks(*sd.manifest) {
+		if t, ok := sd.chunksRequested[h]; ok && time.Since(t) < requestTimeout() {
+			continue
+		}
+		sd.chunksRequested[h] = time.Now()
+		hashes = append(hashes, h.GetBytes())
+		if len(hashes) >= chunksPerRequest {
+			break
+		}
+	}
+	if len(hashes) == 0 {
+		tcpip.FinishRequest(addr)
+		return
+	}
+	Send(addr, generateSnapshotMsg("gc", [2]byte{'C', 'H'}, hashes))
+}
+
+// onManifest accepts manifest only when its hash is equal to trusted checkpoint hash
+func (sd *snapshotDownload) onManifest(addr [4]byte, b []byte) {
+	m, err := snapshots.Manifest{}.GetFromBytes(b)
+	if err != nil {
+		tcpip.ReduceAndCheckIfBanIP(addr)
+		return
+	}
+	hash, err := m.CalcHash()
+	if err != nil || m.Height != common.TrustedCheckpointHeight || !bytes.Equal(hash.GetBytes(), common.TrustedCheckpointHash) {
+		logger.GetLogger().Println("snapshot manifest does not match trusted checkpoint", addr)
+		tcpip.ReduceAndCheckIfBanIP(addr)
+		return
+	}
+	sd.mutex.Lock()
+	if sd.manifest == nil {
+		sd.manifest = &m
+		logger.GetLogger().Printf("Snapshot manifest accepted, height %d, chunks %d", m.Height, len(m.ChunkHashes))
+	}
+	sd.mutex.Unlock()
+	sd.onPeer(addr, m.Height)
+}
+
+// onChunks stores verified chunks and restores state when all are downloaded
+func (sd *snapshotDownload) onChunks(addr [4]byte, chunks [][]byte) {
+	sd.mutex.Lock()
+	defer sd.mutex.Unlock()
+	if sd.manifest == nil || sd.done {
+		return
+	}
+	for _, c := range chunks {
+		hash, err := snapshots.StoreChunk(*sd.manifest, c)
+		if err != nil {
+			logger.GetLogger().Println(err)
+			tcpip.ReduceAndCheckIfBanIP(addr)
+			continue
+		}
+		delete(sd.chunksRequested, hash)
+	}
+	missing := len(snapshots.MissingChunks(*sd.manifest))
+	if sm := statistics.GetStatsManager(); sm != nil {
+		sm.UpdateSyncProgress(sd.manifest.Height, 0, 0, missing)
+	}
+	if missing > 0 {
+		return
+	}
+	common.BlockMutex.Lock()
+	defer common.BlockMutex.Unlock()
+	err := snapshots.RestoreSnapshot(*sd.manifest)
+	if err != nil {
+		logger.GetLogger().Println("cannot restore state snapshot", err)
+		return
+	}
+	sd.done = true
+	logger.GetLogger().Println("State restored from snapshot at height", sd.manifest.Height)
+}
+
+// SendSnapshotManifest answers "gs" message
+func SendSnapshotManifest(addr [4]byte, height int64) {
+	m, err := snapshots.LoadManifest(height)
+	if err != nil {
+		return
+	}
+	Send(addr, generateSnapshotMsg("ss", [2]byte{'S', 'M'}, [][]byte{m.GetBytes()}))
+}
+
+// SendSnapshotChunks answers "gc" message
+func SendSnapshotChunks(addr [4]byte, hashes [][]byte) {
+	chunks := [][]byte{}
+	for i, hb := range hashes {
+		if i >= chunksPerRequest {
+			break
+		}
+		c, err := snapshots.LoadChunk(common.GetHashFromBytes(hb))
+		if err != nil {
+			continue
+		}
+		chunks = append(chunks, c)
+	}
+	if len(chunks) > 0 {
+		Send(addr, generateSnapshotMsg("sc", [2]byte{'S', 'C'}, chunks))
+	}
+}
+
+func generateSnapshotMsg(head string, key [2]byte, values [][]byte) []byte {
+	bm := message.BaseMessage{
+		Head:    []byte(head),
+		ChainID: common.GetChainID(),
+	}
+	n := message.TransactionsMessage{
+		BaseMessage:       bm,
+		TransactionsBytes: map[[2]byte][][]byte{key: values},
+	}
+	return n.GetBytes()
+}
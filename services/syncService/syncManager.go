@@ -0,0 +1,358 @@
+package syncServices
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/okuralabs/okura-node/blocks"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/message"
+	"github.com/okuralabs/okura-node/services"
+	"github.com/okuralabs/okura-node/services/transactionServices"
+	"github.com/okuralabs/okura-node/snapshots"
+	"github.com/okuralabs/okura-node/statistics"
+	"github.com/okuralabs/okura-node/tcpip"
+)
+
+// syncWindow is range of blocks downloaded from one peer. Headers (blocks with transactions hashes)
+// are downloaded first, then missing transactions are requested, then window is applied to chain.
+type syncWindow struct {
+	start           int64
+	end             int64
+	peer            [4]byte
+	assigned        bool
+	requested       time.Time
+	blocks          []blocks.Block
+	bodiesRequested time.Time
+	failedPeers     map[[4]byte]bool
+}
+
+type peerSync struct {
+	height   int64
+	lastSeen time.Time
+}
+
+// SyncManager downloads blocks in parallel windows from several peers
+type SyncManager struct {
+	peers   map[[4]byte]*peerSync
+	windows map[int64]*syncWindow
+	mutex   sync.Mutex
+}
+
+var syncMgr = &SyncManager{
+	peers:   map[[4]byte]*peerSync{},
+	windows: map[int64]*syncWindow{},
+}
+
+func requestTimeout() time.Duration {
+	return time.Duration(common.SyncRequestTimeoutSeconds) * time.Second
+}
+
+// UpdatePeer remembers last height announced by peer in "hi" message
+func (sm *SyncManager) UpdatePeer(ip [4]byte, height int64) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.peers[ip] = &peerSync{height: height, lastSeen: time.Now()}
+}
+
+// targetHeight is the highest height of active peers, needs lock
+func (sm *SyncManager) targetHeight() int64 {
+	target := int64(0)
+	for ip, p := range sm.peers {
+		if time.Since(p.lastSeen) > 3*requestTimeout() {
+			delete(sm.peers, ip)
+			continue
+		}
+		if p.height > target {
+			target = p.height
+		}
+	}
+	return target
+}
+
+// assign chooses peer with enough height and the fewest windows assigned, needs lock
+func (sm *SyncManager) assign(w *syncWindow) {
+	load := map[[4]byte]int{}
+	for _, ow := range sm.windows {
+		if ow.assigned {
+			load[ow.peer]++
+		}
+	}
+	var best [4]byte
+	found := false
+	for ip, p := range sm.peers {
+		if p.height < w.end || w.failedPeers[ip] {
+			continue
+		}
+		if !found || load[ip] < load[best] {
+			best = ip
+			found = true
+		}
+	}
+	if !found {
+		// every peer failed, try all of them once more
+		if len(w.failedPeers) > 0 {
+			w.failedPeers = map[[4]byte]bool{}
+		}
+		return
+	}
+	if !tcpip.StartRequest(best) {
+		return
+	}
+	w.peer = best
+	w.assigned = true
+	w.requested = time.Now()
+	Send(best, generateSyncMsgGetHeadersRange(w.start, w.end))
+}
+
+// schedule creates windows above current height and assigns them to peers, needs lock
+func (sm *SyncManager) schedule(h int64) {
+	target := sm.targetHeight()
+	for start, w := range sm.windows {
+		if w.end <= h || start > target {
+			delete(sm.windows, start)
+		}
+	}
+	start := h + 1
+	for start <= target {
+		if w, ok := sm.windows[start]; ok {
+			start = w.end + 1
+			continue
+		}
+		if len(sm.windows) >= common.SyncMaxParallelWindows {
+			break
+		}
+		end := start + common.NumberOfBlocksInBucket - 1
+		if end > target {
+			end = target
+		}
+		sm.windows[start] = &syncWindow{
+			start:       start,
+			end:         end,
+			failedPeers: map[[4]byte]bool{},
+		}
+		start = end + 1
+	}
+	for _, w := range sm.windows {
+		if !w.assigned && len(w.blocks) == 0 {
+			sm.assign(w)
+		}
+	}
+}
+
+// release makes window to be downloaded again from other peer, needs lock
+func (sm *SyncManager) release(w *syncWindow) {
+	if w.assigned {
+		w.failedPeers[w.peer] = true
+	}
+	w.assigned = false
+	w.blocks = nil
+	w.bodiesRequested = time.Time{}
+}
+
+// reset drops all windows, used after chain reset
+func (sm *SyncManager) reset() {
+	sm.windows = map[int64]*syncWindow{}
+}
+
+// checkHeaders validates hashes, proof of synergy and links between consecutive blocks
+func checkHeaders(indices []int64, blcks []blocks.Block) bool {
+	for i, block := range blcks {
+		if block.GetHeader().Height != indices[i] {
+			return false
+		}
+		if i > 0 && (indices[i] != indices[i-1]+1 ||
+			!bytes.Equal(block.GetHeader().PreviousHash.GetBytes(), blcks[i-1].BlockHash.GetBytes())) {
+			return false
+		}
+		hash, err := block.CalcBlockHash()
+		if err != nil || !bytes.Equal(hash.GetBytes(), block.BlockHash.GetBytes()) {
+			return false
+		}
+		if !block.CheckProofOfSynergy() {
+			return false
+		}
+	}
+	return true
+}
+
+// OnHeaders handles "sh" message which answers request of window. Returns false when message
+// was not requested by sync manager.
+func (sm *SyncManager) OnHeaders(addr [4]byte, indices []int64, blcks []blocks.Block) bool {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	w, ok := sm.windows[indices[0]]
+	if !ok || !w.assigned || w.peer != addr || len(w.blocks) > 0 {
+		return false
+	}
+	if !checkHeaders(indices, blcks) || indices[len(indices)-1] > w.end {
+		logger.GetLogger().Println("wrong headers in sync window from", addr)
+		tcpip.ReduceAndCheckIfBanIP(addr)
+		sm.release(w)
+		return true
+	}
+	h := common.GetHeight()
+	if w.start == h+1 {
+		lastBlockHash, err := blocks.LoadHashOfBlock(h)
+		if err == nil && !bytes.Equal(lastBlockHash, blcks[0].GetHeader().PreviousHash.GetBytes()) {
+			// other chain forks below our height, serial sync resolves it
+			delete(sm.windows, w.start)
+			if p, ok := sm.peers[addr]; ok {
+				SendGetHeaders(addr, p.height)
+			}
+			return true
+		}
+	}
+	// peer may have shorter chain than announced, rest is scheduled again
+	w.end = indices[len(indices)-1]
+	w.blocks = blcks
+	sm.requestBodies(w)
+	return true
+}
+
+// requestBodies asks window peer for transactions missing in pool DB, needs lock
+func (sm *SyncManager) requestBodies(w *syncWindow) {
+	hashesMissing := [][]byte{}
+	for _, block := range w.blocks {
+		hashesMissing = append(hashesMissing, blocks.IsAllTransactions(block)...)
+	}
+	if len(hashesMissing) == 0 {
+		return
+	}
+	w.bodiesRequested = time.Now()
+	transactionServices.SendGT(w.peer, hashesMissing, "bt")
+}
+
+// checkTimeouts reassigns windows which peers did not answer in time, needs lock
+func (sm *SyncManager) checkTimeouts() {
+	for _, w := range sm.windows {
+		if w.assigned && len(w.blocks) == 0 && time.Since(w.requested) > requestTimeout() {
+			logger.GetLogger().Println("sync window timeout, reassign blocks", w.start, w.end)
+			sm.release(w)
+			continue
+		}
+		if len(w.blocks) > 0 && !w.bodiesRequested.IsZero() && time.Since(w.bodiesRequested) > requestTimeout() {
+			// ask other peer which has these blocks for missing transactions
+			for ip, p := range sm.peers {
+				if p.height >= w.end && ip != w.peer {
+					w.peer = ip
+					break
+				}
+			}
+			sm.requestBodies(w)
+		}
+	}
+}
+
+// apply stores consecutive downloaded windows with all transactions, needs lock
+func (sm *SyncManager) apply() {
+	common.BlockMutex.Lock()
+	defer common.BlockMutex.Unlock()
+	for {
+		h := common.GetHeight()
+		w, ok := sm.windows[h+1]
+		if !ok || len(w.blocks) == 0 {
+			return
+		}
+		common.IsSyncing.Store(true)
+		applied := 0
+		for _, block := range w.blocks {
+			oldBlock, err := blocks.LoadBlock(h)
+			if err != nil {
+				logger.GetLogger().Println(err)
+				return
+			}
+			merkleTrie, err := blocks.CheckBaseBlock(block, oldBlock)
+			if err != nil {
+				merkleTrie.Destroy()
+				logger.GetLogger().Println("sync window block verification fails", block.GetHeader().Height, err)
+				tcpip.ReduceAndCheckIfBanIP(w.peer)
+				sm.release(w)
+				return
+			}
+			if len(blocks.IsAllTransactions(block)) > 0 {
+				merkleTrie.Destroy()
+				break
+			}
+			err = blocks.CheckBlockAndTransferFunds(&block, oldBlock, merkleTrie)
+			merkleTrie.Destroy()
+			if err != nil {
+				logger.GetLogger().Println("sync window transfer fails", block.GetHeader().Height, err)
+				services.ResetAccountsAndBlocksSync(oldBlock.GetHeader().Height)
+				sm.reset()
+				return
+			}
+			err = block.StoreBlock()
+			if err != nil {
+				logger.GetLogger().Println(err)
+				services.ResetAccountsAndBlocksSync(oldBlock.GetHeader().Height)
+				sm.reset()
+				return
+			}
+			logger.GetLogger().Println("Sync New Block success -------------------------------------", block.GetHeader().Height)
+			services.StoreStateAtHeight(block.GetHeader().Height)
+			common.SetHeight(block.GetHeader().Height)
+			statistics.GetStatsManager().UpdateStatistics(block, oldBlock)
+			snapshots.CreateSnapshotIfCheckpoint(block.GetHeader().Height)
+			h = block.GetHeader().Height
+			applied++
+		}
+		if h > common.CurrentHeightOfNetwork {
+			common.IsSyncing.Store(false)
+		}
+		delete(sm.windows, w.start)
+		if applied < len(w.blocks) {
+			// rest of window waits for transactions
+			w.blocks = w.blocks[applied:]
+			w.start = h + 1
+			sm.windows[w.start] = w
+			if w.bodiesRequested.IsZero() {
+				sm.requestBodies(w)
+			}
+			return
+		}
+	}
+}
+
+// downloadedHeight is the highest height downloaded without gaps, needs lock
+func (sm *SyncManager) downloadedHeight(h int64) int64 {
+	for {
+		w, ok := sm.windows[h+1]
+		if !ok || len(w.blocks) == 0 {
+			return h
+		}
+		h = w.end
+	}
+}
+
+// Tick applies downloaded blocks, reassigns timed out windows and schedules new ones
+func (sm *SyncManager) Tick() {
+	if IsFastSyncing() {
+		return
+	}
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.checkTimeouts()
+	sm.apply()
+	h := common.GetHeight()
+	sm.schedule(h)
+	if stm := statistics.GetStatsManager(); stm != nil {
+		stm.UpdateSyncProgress(sm.targetHeight(), sm.downloadedHeight(h), len(sm.peers), len(sm.windows))
+	}
+}
+
+func generateSyncMsgGetHeadersRange(bHeight int64, eHeight int64) []byte {
+	bm := message.BaseMessage{
+		Head:    []byte("gh"),
+		ChainID: common.GetChainID(),
+	}
+	n := message.TransactionsMessage{
+		BaseMessage:       bm,
+		TransactionsBytes: map[[2]byte][][]byte{},
+	}
+	n.TransactionsBytes[[2]byte{'B', 'H'}] = [][]byte{common.GetByteInt64(bHeight)}
+	n.TransactionsBytes[[2]byte{'E', 'H'}] = [][]byte{common.GetByteInt64(eHeight)}
+	return n.GetBytes()
+}
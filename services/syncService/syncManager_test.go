@@ -0,0 +1,123 @@
+package syncServices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/okuralabs/okura-node/blocks"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/services"
+)
+
+func newTestSyncManager(peers map[[4]byte]int64) *SyncManager {
+	sm := &SyncManager{peers: map[[4]byte]*peerSync{}, windows: map[int64]*syncWindow{}}
+	for ip, h := range peers {
+		sm.peers[ip] = &peerSync{height: h, lastSeen: time.Now()}
+	}
+	return sm
+}
+
+func linkedBlocks(t *testing.T, from int64, n int) ([]int64, []blocks.Block) {
+	indices := []int64{}
+	bls := []blocks.Block{}
+	prev := common.Hash{}
+	for i := 0; i < n; i++ {
+		bl := blocks.Block{}
+		bl.BaseBlock.BaseHeader.Height = from + int64(i)
+		bl.BaseBlock.BaseHeader.PreviousHash = prev
+		hash, err := bl.CalcBlockHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bl.BlockHash = hash
+		prev = hash
+		indices = append(indices, bl.GetHeader().Height)
+		bls = append(bls, bl)
+	}
+	return indices, bls
+}
+
+func TestSchedule_ParallelWindowsFromSeveralPeers(t *testing.T) {
+	defer func(n int) { common.SyncMaxParallelWindows = n }(common.SyncMaxParallelWindows)
+	defer func(n int) { common.PeerMaxOutstandingRequests = n }(common.PeerMaxOutstandingRequests)
+	defer func(c chan []byte) { services.SendChanSync = c }(services.SendChanSync)
+	common.SyncMaxParallelWindows = 3
+	common.PeerMaxOutstandingRequests = 100
+	services.SendChanSync = make(chan []byte, 100)
+	peerA, peerB := [4]byte{10, 2, 0, 1}, [4]byte{10, 2, 0, 2}
+	sm := newTestSyncManager(map[[4]byte]int64{peerA: 1000, peerB: 2 * common.NumberOfBlocksInBucket})
+
+	sm.schedule(0)
+	if len(sm.windows) != 3 {
+		t.Fatalf("Expected 3 parallel windows, got %v", len(sm.windows))
+	}
+	load := map[[4]byte]int{}
+	start := int64(1)
+	for i := 0; i < 3; i++ {
+		w, ok := sm.windows[start]
+		if !ok {
+			t.Fatalf("Expected window starting at %v", start)
+		}
+		if !w.assigned || sm.peers[w.peer].height < w.end {
+			t.Errorf("Expected window %v-%v assigned to peer with these blocks, got %v", w.start, w.end, w.peer)
+		}
+		load[w.peer]++
+		start = w.end + 1
+	}
+	if load[peerA] == 0 || load[peerB] == 0 {
+		t.Errorf("Expected windows downloaded from both peers, got %v", load)
+	}
+	if len(services.SendChanSync) != 3 {
+		t.Errorf("Expected request sent for every window, got %v", len(services.SendChanSync))
+	}
+}
+
+func TestCheckTimeouts_ReassignsWindowToOtherPeer(t *testing.T) {
+	defer func(n int) { common.PeerMaxOutstandingRequests = n }(common.PeerMaxOutstandingRequests)
+	defer func(c chan []byte) { services.SendChanSync = c }(services.SendChanSync)
+	common.PeerMaxOutstandingRequests = 100
+	services.SendChanSync = make(chan []byte, 100)
+	peerA, peerB := [4]byte{10, 2, 0, 3}, [4]byte{10, 2, 0, 4}
+	sm := newTestSyncManager(map[[4]byte]int64{peerA: 100, peerB: 100})
+	w := &syncWindow{start: 1, end: 20, peer: peerA, assigned: true,
+		requested: time.Now().Add(-2 * requestTimeout()), failedPeers: map[[4]byte]bool{}}
+	sm.windows[1] = w
+
+	sm.checkTimeouts()
+	if w.assigned || !w.failedPeers[peerA] {
+		t.Fatalf("Expected timed out window released and peer marked as failed")
+	}
+	sm.assign(w)
+	if !w.assigned || w.peer != peerB {
+		t.Errorf("Expected window reassigned to other peer, got %v", w.peer)
+	}
+}
+
+func TestDownloadedHeight_WithoutGaps(t *testing.T) {
+	sm := newTestSyncManager(nil)
+	_, bls := linkedBlocks(t, 1, 1)
+	sm.windows[1] = &syncWindow{start: 1, end: 20, blocks: bls}
+	sm.windows[21] = &syncWindow{start: 21, end: 40, blocks: bls}
+	sm.windows[41] = &syncWindow{start: 41, end: 60}
+	sm.windows[61] = &syncWindow{start: 61, end: 80, blocks: bls}
+
+	if h := sm.downloadedHeight(0); h != 40 {
+		t.Errorf("Expected blocks downloaded up to 40, got %v", h)
+	}
+}
+
+func TestCheckHeaders(t *testing.T) {
+	indices, bls := linkedBlocks(t, 5, 3)
+	if !checkHeaders(indices, bls) {
+		t.Errorf("Expected linked headers accepted")
+	}
+
+	wrongLink := append([]blocks.Block{}, bls...)
+	wrongLink[2].BaseBlock.BaseHeader.PreviousHash = common.Hash{1}
+	if checkHeaders(indices, wrongLink) {
+		t.Errorf("Expected header not following previous one rejected")
+	}
+	if checkHeaders([]int64{5, 6, 8}, bls) {
+		t.Errorf("Expected headers with wrong heights rejected")
+	}
+}
@@ -0,0 +1,179 @@
+package transactionServices
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/tcpip"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+// time after which hash requested from one peer can be requested from another one
+const inventoryRequestTimeout = 5 * time.Second
+
+// knownHashes is bounded FIFO set of transaction hashes which peer is known to have
+type knownHashes struct {
+	set   map[common.Hash]struct{}
+	order []common.Hash
+}
+
+var (
+	knownTxs       = map[[4]byte]*knownHashes{}
+	requestedTxs   = map[common.Hash]time.Time{}
+	inventoryMutex sync.Mutex
+)
+
+func toHashKey(hash []byte) common.Hash {
+	k := common.Hash{}
+	k.Set(hash)
+	return k
+}
+
+// MarkTxKnown remembers that peer ip has transaction with given hash, so it will not be announced back
+func MarkTxKnown(ip [4]byte, hash []byte) {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+	markTxKnown(ip, toHashKey(hash))
+}
+
+func markTxKnown(ip [4]byte, k common.Hash) {
+	kh, ok := knownTxs[ip]
+	if !ok {
+		kh = &knownHashes{set: map[common.Hash]struct{}{}}
+		knownTxs[ip] = kh
+	}
+	if _, ok := kh.set[k]; ok {
+		return
+	}
+	if len(kh.order) >= common.MaxKnownTxHashesPerPeer {
+		delete(kh.set, kh.order[0])
+		kh.order = kh.order[1:]
+	}
+	kh.set[k] = struct{}{}
+	kh.order = append(kh.order, k)
+}
+
+func isTxKnown(ip [4]byte, k common.Hash) bool {
+	kh, ok := knownTxs[ip]
+	if !ok {
+		return false
+	}
+	_, ok = kh.set[k]
+	return ok
+}
+
+// RemovePeerInventory drops known hashes cache of disconnected peer
+func RemovePeerInventory(ip [4]byte) {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+	delete(knownTxs, ip)
+}
+
+// isTxMissing checks if transaction is neither in pool nor stored in DB
+func isTxMissing(hash []byte) bool {
+	if transactionsPool.PoolsTx.TransactionExists(hash) {
+		return false
+	}
+	if transactionsDefinition.CheckFromDBPoolTx(common.TransactionPoolHashesDBPrefix[:], hash) {
+		return false
+	}
+	if transactionsDefinition.CheckFromDBPoolTx(common.TransactionDBPrefix[:], hash) {
+		return false
+	}
+	return true
+}
+
+// missingFromInventory returns hashes announced by ip which should be requested from it.
+// Hash requested recently from other peer is skipped until inventoryRequestTimeout passes.
+func missingFromInventory(ip [4]byte, hashes [][]byte) [][]byte {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+	now := time.Now()
+	for k, t := range requestedTxs {
+		if now.Sub(t) > inventoryRequestTimeout {
+			delete(requestedTxs, k)
+		}
+	}
+	missing := [][]byte{}
+	for _, h := range hashes {
+		if len(h) != common.HashLength {
+			continue
+		}
+		k := toHashKey(h)
+		markTxKnown(ip, k)
+		if _, ok := requestedTxs[k]; ok {
+			continue
+		}
+		if !isTxMissing(h) {
+			continue
+		}
+		requestedTxs[k] = now
+		missing = append(missing, h)
+	}
+	return missing
+}
+
+// filterUnknownForPeer returns hashes not known by peer ip and marks them as known
+func filterUnknownForPeer(ip [4]byte, hashes [][]byte) [][]byte {
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+	unknown := [][]byte{}
+	for _, h := range hashes {
+		k := toHashKey(h)
+		if isTxKnown(ip, k) {
+			continue
+		}
+		markTxKnown(ip, k)
+		unknown = append(unknown, h)
+	}
+	return unknown
+}
+
+// AnnounceTransactions sends inventory of hashes to all peers which do not know them yet, except ignoreAddr
+func AnnounceTransactions(ignoreAddr [4]byte, hashes [][]byte) {
+	if len(hashes) == 0 {
+		return
+	}
+	var ip [4]byte
+	peers := tcpip.GetPeersConnected(tcpip.TransactionTopic)
+	for topicip := range peers {
+		copy(ip[:], topicip[2:])
+		if bytes.Equal(ip[:], ignoreAddr[:]) || bytes.Equal(ip[:], tcpip.MyIP[:]) {
+			continue
+		}
+		unknown := filterUnknownForPeer(ip, hashes)
+		if len(unknown) == 0 {
+			continue
+		}
+		SendGT(ip, unknown, "iv")
+	}
+}
+
+// SendInventoryMsg announces hashes of transactions waiting in pool
+func SendInventoryMsg() {
+	isync := common.IsSyncing.Load()
+	if isync == true {
+		return
+	}
+	txs := transactionsPool.PoolsTx.PeekTransactions(int(common.MaxTransactionsPerBlock), 0)
+	hashes := make([][]byte, 0, len(txs))
+	for _, t := range txs {
+		hashes = append(hashes, t.Hash.GetBytes())
+	}
+	AnnounceTransactions(tcpip.MyIP, hashes)
+}
+
+// requestMissingTransactions asks ip for transactions from inventory which are not in pool nor in DB
+func requestMissingTransactions(ip [4]byte, hashes [][]byte) {
+	if transactionsPool.PoolsTx.NumberOfTransactions() > common.MaxTransactionInPool {
+		return
+	}
+	missing := missingFromInventory(ip, hashes)
+	if len(missing) == 0 {
+		return
+	}
+	SendGT(ip, missing, "st")
+}
@@ -0,0 +1,87 @@
+package transactionServices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+func resetInventory() {
+	knownTxs = map[[4]byte]*knownHashes{}
+	requestedTxs = map[common.Hash]time.Time{}
+}
+
+func TestMissingFromInventory_RequestsOnlyMissing(t *testing.T) {
+	resetInventory()
+	peer1 := [4]byte{10, 0, 1, 1}
+	peer2 := [4]byte{10, 0, 1, 2}
+	inPool := testTx(2, 1)
+	transactionsPool.PoolsTx.AddTransaction(inPool, inPool.Hash)
+	defer transactionsPool.PoolsTx.RemoveTransactionByHash(inPool.Hash.GetBytes())
+	unknown := testTx(2, 2).Hash.GetBytes()
+
+	missing := missingFromInventory(peer1, [][]byte{inPool.Hash.GetBytes(), unknown, {1, 2, 3}})
+	if len(missing) != 1 || string(missing[0]) != string(unknown) {
+		t.Fatalf("Expected only transaction missing from pool requested, got %v", len(missing))
+	}
+	if !isTxKnown(peer1, toHashKey(unknown)) || !isTxKnown(peer1, inPool.Hash) {
+		t.Errorf("Expected announced hashes marked as known by announcing peer")
+	}
+
+	// the same hash announced by other peer waits for answer of the first one
+	if missing = missingFromInventory(peer2, [][]byte{unknown}); len(missing) != 0 {
+		t.Errorf("Expected hash requested recently not requested again, got %v", len(missing))
+	}
+	requestedTxs[toHashKey(unknown)] = time.Now().Add(-2 * inventoryRequestTimeout)
+	if missing = missingFromInventory(peer2, [][]byte{unknown}); len(missing) != 1 {
+		t.Errorf("Expected hash requested from other peer after timeout, got %v", len(missing))
+	}
+}
+
+func TestFilterUnknownForPeer_NoEcho(t *testing.T) {
+	resetInventory()
+	peer := [4]byte{10, 0, 1, 3}
+	h1 := testTx(3, 1).Hash.GetBytes()
+	h2 := testTx(3, 2).Hash.GetBytes()
+
+	// peer sent h1, so it is not announced back
+	MarkTxKnown(peer, h1)
+	unknown := filterUnknownForPeer(peer, [][]byte{h1, h2})
+	if len(unknown) != 1 || string(unknown[0]) != string(h2) {
+		t.Fatalf("Expected only hash unknown to peer announced, got %v", len(unknown))
+	}
+	if unknown = filterUnknownForPeer(peer, [][]byte{h1, h2}); len(unknown) != 0 {
+		t.Errorf("Expected hashes announced once, got %v", len(unknown))
+	}
+
+	RemovePeerInventory(peer)
+	if unknown = filterUnknownForPeer(peer, [][]byte{h1, h2}); len(unknown) != 2 {
+		t.Errorf("Expected inventory of disconnected peer forgotten, got %v", len(unknown))
+	}
+}
+
+func TestMarkTxKnown_BoundedCache(t *testing.T) {
+	defer func(n int) { common.MaxKnownTxHashesPerPeer = n }(common.MaxKnownTxHashesPerPeer)
+	common.MaxKnownTxHashesPerPeer = 3
+	resetInventory()
+	peer := [4]byte{10, 0, 1, 4}
+	hashes := [][]byte{}
+	for i := int64(1); i <= 4; i++ {
+		h := testTx(4, i).Hash.GetBytes()
+		hashes = append(hashes, h)
+		MarkTxKnown(peer, h)
+	}
+	if isTxKnown(peer, toHashKey(hashes[0])) {
+		t.Errorf("Expected the oldest hash evicted from full cache")
+	}
+	for _, h := range hashes[1:] {
+		if !isTxKnown(peer, toHashKey(h)) {
+			t.Errorf("Expected recent hash kept in cache")
+		}
+	}
+	if n := len(knownTxs[peer].order); n != 3 {
+		t.Errorf("Expected cache bounded to 3 hashes, got %v", n)
+	}
+}
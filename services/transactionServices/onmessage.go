@@ -1,6 +1,8 @@
 package transactionServices
 
 import (
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/blocks"
 	"github.com/okuralabs/okura-node/common"
 	"github.com/okuralabs/okura-node/logger"
 	"github.com/okuralabs/okura-node/message"
@@ -35,43 +37,14 @@ func OnMessage(addr [4]byte, m []byte) {
 		if err != nil {
 			return
 		}
-		if transactionsPool.PoolsTx.NumberOfTransactions() > common.MaxTransactionInPool {
-			//logger.GetLogger().Println("no more transactions can be accepted to the pool")
-			return
-		}
-		// need to check transactions
-		for _, v := range txn {
-			for _, t := range v {
-				//if t.Verify() {
-				if transactionsPool.PoolsTx.TransactionExists(t.Hash.GetBytes()) {
-					//logger.GetLogger().Println("transaction just exists in Pool")
-					continue
-				}
-				if transactionsDefinition.CheckFromDBPoolTx(common.TransactionDBPrefix[:], t.Hash.GetBytes()) {
-					//logger.GetLogger().Println("transaction just exists in DB")
-					continue
-				}
+		added := AddReceivedTransactions(addr, txn)
+		// announce only hashes, peers request full transactions they are missing
+		AnnounceTransactions(addr, added)
 
-				isAdded := transactionsPool.PoolsTx.AddTransaction(t, t.Hash)
-				if isAdded {
-					err := t.StoreToDBPoolTx(common.TransactionPoolHashesDBPrefix[:])
-					if err != nil {
-						transactionsPool.PoolsTx.RemoveTransactionByHash(t.Hash.GetBytes())
-						err := transactionsDefinition.RemoveTransactionFromDBbyHash(common.TransactionPoolHashesDBPrefix[:], t.Hash.GetBytes())
-						if err != nil {
-							logger.GetLogger().Println(err)
-						}
-						//err = transactionsDefinition.RemoveTransactionFromDBbyHash(common.TransactionDBPrefix[:], t.Hash.GetBytes())
-						//if err != nil {
-						//	logger.GetLogger().Println(err)
-						//}
-						logger.GetLogger().Println(err)
-						continue
-					}
-					//maybe we should not spread automatically transactions. Third party should care about it
-					Spread(addr, m)
-				}
-			}
+	case "iv":
+		txn := amsg.(message.TransactionsMessage).GetTransactionsBytes()
+		for _, hs := range txn {
+			requestMissingTransactions(addr, hs)
 		}
 
 	case "st":
@@ -96,6 +69,7 @@ func OnMessage(addr [4]byte, m []byte) {
 				}
 				if len(t.GetBytes()) > 0 {
 					txs = append(txs, t)
+					MarkTxKnown(addr, hs)
 				}
 			}
 			transactionMsg, err := GenerateTransactionMsg(txs, []byte("tx"), topic)
@@ -140,3 +114,89 @@ func OnMessage(addr [4]byte, m []byte) {
 	default:
 	}
 }
+
+// withoutOrphanVotes drops votes on multi signature proposals which are neither pending nor waiting in pool
+func withoutOrphanVotes(txn map[[2]byte][]transactionsDefinition.Transaction) map[[2]byte][]transactionsDefinition.Transaction {
+	for k, v := range txn {
+		txs := []transactionsDefinition.Transaction{}
+		for _, t := range v {
+			if blocks.IsOrphanMultiSignVote(t) && !transactionsPool.PoolsTx.TransactionExists(t.TxParam.MultiSignTx.GetBytes()) {
+				logger.GetLogger().Println("vote on multi signature proposal which is not pending", t.Hash.GetHex())
+				continue
+			}
+			txs = append(txs, t)
+		}
+		txn[k] = txs
+	}
+	return txn
+}
+
+// withoutInvalidRecoveries drops recovery transactions which cannot be included in next block
+func withoutInvalidRecoveries(txn map[[2]byte][]transactionsDefinition.Transaction) map[[2]byte][]transactionsDefinition.Transaction {
+	height := common.GetHeight() + 1
+	for k, v := range txn {
+		txs := []transactionsDefinition.Transaction{}
+		for _, t := range v {
+			if blocks.IsRecoveryTransaction(t) {
+				err := blocks.CheckRecoveryTransaction(t, height, map[[common.AddressLength]byte]account.Recovery{})
+				if err != nil {
+					logger.GetLogger().Println("invalid recovery transaction", t.Hash.GetHex(), err)
+					continue
+				}
+			}
+			txs = append(txs, t)
+		}
+		txn[k] = txs
+	}
+	return txn
+}
+
+// AddReceivedTransactions adds transactions received from peer to pool unless pool is full,
+// orphan votes and invalid recoveries are skipped
+func AddReceivedTransactions(addr [4]byte, txn map[[2]byte][]transactionsDefinition.Transaction) [][]byte {
+	if transactionsPool.PoolsTx.NumberOfTransactions() > common.MaxTransactionInPool {
+		//logger.GetLogger().Println("no more transactions can be accepted to the pool")
+		return [][]byte{}
+	}
+	return AddTransactionsToPool(addr, withoutInvalidRecoveries(withoutOrphanVotes(txn)))
+}
+
+// AddTransactionsToPool adds verified transactions received from addr to the pool and stores them in pool DB.
+// Returns hashes of transactions which were not known before.
+func AddTransactionsToPool(addr [4]byte, txn map[[2]byte][]transactionsDefinition.Transaction) [][]byte {
+	added := [][]byte{}
+	for _, v := range txn {
+		for _, t := range v {
+			MarkTxKnown(addr, t.Hash.GetBytes())
+			//if t.Verify() {
+			if transactionsPool.PoolsTx.TransactionExists(t.Hash.GetBytes()) {
+				//logger.GetLogger().Println("transaction just exists in Pool")
+				continue
+			}
+			if transactionsDefinition.CheckFromDBPoolTx(common.TransactionDBPrefix[:], t.Hash.GetBytes()) {
+				//logger.GetLogger().Println("transaction just exists in DB")
+				continue
+			}
+
+			isAdded := transactionsPool.PoolsTx.AddTransaction(t, t.Hash)
+			if isAdded {
+				err := t.StoreToDBPoolTx(common.TransactionPoolHashesDBPrefix[:])
+				if err != nil {
+					transactionsPool.PoolsTx.RemoveTransactionByHash(t.Hash.GetBytes())
+					err := transactionsDefinition.RemoveTransactionFromDBbyHash(common.TransactionPoolHashesDBPrefix[:], t.Hash.GetBytes())
+					if err != nil {
+						logger.GetLogger().Println(err)
+					}
+					//err = transactionsDefinition.RemoveTransactionFromDBbyHash(common.TransactionDBPrefix[:], t.Hash.GetBytes())
+					//if err != nil {
+					//	logger.GetLogger().Println(err)
+					//}
+					logger.GetLogger().Println(err)
+					continue
+				}
+				added = append(added, t.Hash.GetBytes())
+			}
+		}
+	}
+	return added
+}
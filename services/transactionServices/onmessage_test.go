@@ -0,0 +1,40 @@
+package transactionServices
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/tcpip"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+func testTx(sender byte, nonce int64) transactionsDefinition.Transaction {
+	tx := transactionsDefinition.Transaction{Height: nonce, GasPrice: 1, GasUsage: 21000}
+	tx.TxParam.ChainID = common.GetChainID()
+	tx.TxParam.Sender = common.Address{ByteValue: [common.AddressLength]byte{sender}, Primary: true}
+	tx.TxParam.SendingTime = nonce
+	tx.TxParam.Nonce = int16(nonce)
+	tx.Signature = common.Signature{ByteValue: append([]byte{0}, make([]byte, common.SignatureLength())...), Primary: true}
+	_ = tx.CalcHashAndSet()
+	return tx
+}
+
+func TestAddReceivedTransactions_PoolFull(t *testing.T) {
+	defer func(n int) { common.MaxTransactionInPool = n }(common.MaxTransactionInPool)
+	peer := [4]byte{10, 0, 0, 1}
+	tx := testTx(1, 1)
+	defer transactionsPool.PoolsTx.RemoveTransactionByHash(tx.Hash.GetBytes())
+	defer transactionsDefinition.RemoveTransactionFromDBbyHash(common.TransactionPoolHashesDBPrefix[:], tx.Hash.GetBytes())
+	txn := map[[2]byte][]transactionsDefinition.Transaction{tcpip.TransactionTopic: {tx}}
+
+	common.MaxTransactionInPool = transactionsPool.PoolsTx.NumberOfTransactions() - 1
+	if added := AddReceivedTransactions(peer, txn); len(added) != 0 || transactionsPool.PoolsTx.TransactionExists(tx.Hash.GetBytes()) {
+		t.Errorf("Expected no transaction added to full pool, got %v", len(added))
+	}
+
+	common.MaxTransactionInPool = transactionsPool.PoolsTx.NumberOfTransactions() + 1
+	if added := AddReceivedTransactions(peer, txn); len(added) != 1 {
+		t.Errorf("Expected transaction added when pool has room, got %v", len(added))
+	}
+}
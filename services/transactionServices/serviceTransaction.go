@@ -10,7 +10,6 @@ import (
 	"github.com/okuralabs/okura-node/services"
 	"github.com/okuralabs/okura-node/tcpip"
 	"github.com/okuralabs/okura-node/transactionsDefinition"
-	"github.com/okuralabs/okura-node/transactionsPool"
 )
 
 func InitTransactionService() {
@@ -60,9 +59,7 @@ func broadcastTransactionsMsgInLoop(chanRecv chan []byte) {
 Q:
 	for range time.Tick(time.Second) {
 
-		topic := [2]byte{'T', 'T'}
-
-		SendTransactionMsg(tcpip.MyIP, topic)
+		SendInventoryMsg()
 
 		timeout := time.After(time.Second)
 
@@ -81,22 +78,6 @@ Q:
 	}
 }
 
-func SendTransactionMsg(ip [4]byte, topic [2]byte) {
-	isync := common.IsSyncing.Load()
-	if isync == true {
-		return
-	}
-	txs := transactionsPool.PoolsTx.PeekTransactions(int(common.MaxTransactionsPerBlock), 0)
-	n, err := GenerateTransactionMsg(txs, []byte("tx"), topic)
-	if err != nil {
-		logger.GetLogger().Println(err)
-		return
-	}
-	if !Send(ip, n.GetBytes()) {
-		logger.GetLogger().Println("could not send standard transaction")
-	}
-}
-
 func SendGT(ip [4]byte, txsHashes [][]byte, syncPre string) {
 	topic := tcpip.TransactionTopic
 	transactionMsg, err := GenerateTransactionMsgGT(txsHashes, []byte(syncPre), topic)
@@ -119,27 +100,12 @@ func Send(addr [4]byte, nb []byte) bool {
 	return false
 }
 
-func Spread(ignoreAddr [4]byte, nb []byte) {
-	var ip [4]byte
-	var peers = tcpip.GetPeersConnected(tcpip.TransactionTopic)
-	for topicip, _ := range peers {
-		copy(ip[:], topicip[2:])
-		if !bytes.Equal(ip[:], ignoreAddr[:]) && !bytes.Equal(ip[:], tcpip.MyIP[:]) {
-			//logger.GetLogger().Println("send transactions to ", int(ip[0]), int(ip[1]), int(ip[2]), int(ip[3]))
-			if !Send(ip, nb) {
-				logger.GetLogger().Println("could not broadcast transaction")
-			}
-			break
-		}
-	}
-}
-
 func startPublishingTransactionMsg() {
 	go tcpip.StartNewListener(services.SendChanTx, tcpip.TransactionTopic)
 }
 
 func StartSubscribingTransactionMsg(ip [4]byte) {
-	recvChan := make(chan []byte, 100) // Increased buffer size
+	recvChan := make(chan []byte, common.PeerReceiveBufferSize)
 	quit := false
 	var ipr [4]byte
 	logger.GetLogger().Printf("Starting transaction subscription to peer: %v", ip)
@@ -166,5 +132,6 @@ func StartSubscribingTransactionMsg(ip [4]byte) {
 			time.Sleep(time.Millisecond * 100) // Reduced sleep time
 		}
 	}
+	RemovePeerInventory(ip)
 	logger.GetLogger().Println("Exiting transaction message receiving loop for peer:", ip)
 }
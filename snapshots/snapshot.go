@@ -0,0 +1,364 @@
+package snapshots
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/okuralabs/okura-node/account"
+	"github.com/okuralabs/okura-node/blocks"
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/transactionsPool"
+)
+
+// key of contracts state entry in snapshot, DB keys never start with it
+var vmStateKey = []byte{'V', 'M'}
+
+// Manifest describes state snapshot at given height. Hash of manifest is the checkpoint hash.
+type Manifest struct {
+	Height      int64         `json:"height"`
+	BlockHash   common.Hash   `json:"block_hash"`
+	ChunkHashes []common.Hash `json:"chunk_hashes"`
+}
+
+func (m Manifest) GetBytes() []byte {
+	b := common.GetByteInt64(m.Height)
+	b = append(b, m.BlockHash.GetBytes()...)
+	for _, h := range m.ChunkHashes {
+		b = append(b, h.GetBytes()...)
+	}
+	return b
+}
+
+func (m Manifest) GetFromBytes(b []byte) (Manifest, error) {
+	if len(b) < 8+common.HashLength || (len(b)-8)%common.HashLength != 0 {
+		return Manifest{}, fmt.Errorf("wrong length of snapshot manifest")
+	}
+	m.Height = common.GetInt64FromByte(b[:8])
+	m.BlockHash = common.GetHashFromBytes(b[8 : 8+common.HashLength])
+	b = b[8+common.HashLength:]
+	m.ChunkHashes = []common.Hash{}
+	for i := 0; i < len(b); i += common.HashLength {
+		m.ChunkHashes = append(m.ChunkHashes, common.GetHashFromBytes(b[i:i+common.HashLength]))
+	}
+	return m, nil
+}
+
+// CalcHash returns hash of manifest which is compared with trusted checkpoint hash
+func (m Manifest) CalcHash() (common.Hash, error) {
+	hb, err := common.CalcHashToByte(m.GetBytes())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.GetHashFromBytes(hb), nil
+}
+
+// consensusPrefixes are DB prefixes of state which is the same on all nodes after the same block:
+// registered and revoked pubkeys with merkle tries of main addresses and used double sign evidence.
+// Indexes by height used only to revert blocks are left out, blocks below snapshot are never reverted.
+func consensusPrefixes() [][2]byte {
+	return [][2]byte{
+		common.PubKeyMarshalDBPrefix,
+		common.PubKeyMerkleTrieDBPrefix,
+		common.PubKeyRootHashMerkleTreeDBPrefix,
+		common.PubKeyBytesMerkleTrieDBPrefix,
+		common.PubKeyAddedHeightDBPrefix,
+		common.RevokedPubKeyDBPrefix,
+		common.EvidenceDBPrefix,
+	}
+}
+
+// heightKeys are DB keys of state stored per height
+func heightKeys(height int64, blockHash common.Hash) [][]byte {
+	hb := common.GetByteInt64(height)
+	keys := [][]byte{append(common.AccountsDBPrefix[:], hb...)}
+	for i := 0; i < 256; i++ {
+		keys = append(keys, append(append(common.StakingAccountsDBPrefix[:], hb...), byte(i)))
+	}
+	return append(keys,
+		append(common.DexAccountsDBPrefix[:], hb...),
+		append(common.PendingEscrowPoolDBPrefix[:], hb...),
+		append(common.PendingMultiSignPoolDBPrefix[:], hb...),
+		append(common.RecoveriesDBPrefix[:], hb...),
+		append(common.ValidatorsDBPrefix[:], hb...),
+		append(common.GovernanceDBPrefix[:], hb...),
+		append(common.OracleHistoryDBPrefix[:], hb...),
+		append(common.CumulativeDifficultyDBPrefix[:], hb...),
+		append(common.BlockByHeightDBPrefix[:], hb...),
+		append(common.BlocksDBPrefix[:], blockHash.GetBytes()...),
+	)
+}
+
+// recentBlockKeys are DB keys of blocks below height needed to check opening of rand commits
+// in blocks following snapshot
+func recentBlockKeys(height int64) [][]byte {
+	keys := [][]byte{}
+	for h := height - common.RandRevealWindow + 1; h < height; h++ {
+		if h < 0 {
+			continue
+		}
+		hash, err := blocks.LoadHashOfBlock(h)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, append(common.BlockByHeightDBPrefix[:], common.GetByteInt64(h)...))
+		keys = append(keys, append(common.BlocksDBPrefix[:], hash...))
+	}
+	return keys
+}
+
+type entry struct {
+	key   []byte
+	value []byte
+}
+
+// collectEntries returns state at height in order: entries of consensusPrefixes in order of prefixes
+// and keys, state stored per height, blocks needed after snapshot by height and state of contracts
+func collectEntries(height int64, blockHash common.Hash) ([]entry, error) {
+	entries := []entry{}
+	for _, prefix := range consensusPrefixes() {
+		keys, err := database.MainDB.LoadAllKeys(prefix[:])
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i], keys[j]) < 0
+		})
+		for _, k := range keys {
+			v, err := database.MainDB.Get(k)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{key: k, value: v})
+		}
+	}
+	for _, k := range heightKeys(height, blockHash) {
+		v, err := database.MainDB.Get(k)
+		if err != nil {
+			// dex accounts are not stored when there is no dex operation yet
+			continue
+		}
+		entries = append(entries, entry{key: k, value: v})
+	}
+	for _, k := range recentBlockKeys(height) {
+		v, err := database.MainDB.Get(k)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: k, value: v})
+	}
+	blocks.StateMutex.RLock()
+	vm := blocks.State.GetSnapshotBytes()
+	blocks.StateMutex.RUnlock()
+	entries = append(entries, entry{key: vmStateKey, value: vm})
+	return entries, nil
+}
+
+// CreateSnapshot stores state at height in chunks and returns its manifest.
+// Needs to be called just after block at height was stored.
+func CreateSnapshot(height int64) (Manifest, error) {
+	blockHashBytes, err := blocks.LoadHashOfBlock(height)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m := Manifest{
+		Height:      height,
+		BlockHash:   common.GetHashFromBytes(blockHashBytes),
+		ChunkHashes: []common.Hash{},
+	}
+	entries, err := collectEntries(height, m.BlockHash)
+	if err != nil {
+		return Manifest{}, err
+	}
+	chunk := []byte{}
+	storeChunk := func() error {
+		hb, err := common.CalcHashToByte(chunk)
+		if err != nil {
+			return err
+		}
+		err = database.MainDB.Put(append(common.SnapshotChunkDBPrefix[:], hb...), chunk)
+		if err != nil {
+			return err
+		}
+		m.ChunkHashes = append(m.ChunkHashes, common.GetHashFromBytes(hb))
+		chunk = []byte{}
+		return nil
+	}
+	for _, e := range entries {
+		eb := common.BytesToLenAndBytes(e.key)
+		eb = append(eb, common.BytesToLenAndBytes(e.value)...)
+		if len(chunk) > 0 && len(chunk)+len(eb) > common.SnapshotChunkSize {
+			if err := storeChunk(); err != nil {
+				return Manifest{}, err
+			}
+		}
+		chunk = append(chunk, eb...)
+	}
+	if len(chunk) > 0 {
+		if err := storeChunk(); err != nil {
+			return Manifest{}, err
+		}
+	}
+	err = database.MainDB.Put(append(common.SnapshotManifestDBPrefix[:], common.GetByteInt64(height)...), m.GetBytes())
+	if err != nil {
+		return Manifest{}, err
+	}
+	return m, nil
+}
+
+// RemoveSnapshot removes manifest and chunks of snapshot at height
+func RemoveSnapshot(height int64) error {
+	m, err := LoadManifest(height)
+	if err != nil {
+		return err
+	}
+	for _, h := range m.ChunkHashes {
+		err := database.MainDB.Delete(append(common.SnapshotChunkDBPrefix[:], h.GetBytes()...))
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+	}
+	return database.MainDB.Delete(append(common.SnapshotManifestDBPrefix[:], common.GetByteInt64(height)...))
+}
+
+// CreateSnapshotIfCheckpoint creates snapshot every common.SnapshotInterval blocks and removes old ones
+func CreateSnapshotIfCheckpoint(height int64) {
+	if common.SnapshotInterval <= 0 || height <= 0 || height%common.SnapshotInterval != 0 {
+		return
+	}
+	m, err := CreateSnapshot(height)
+	if err != nil {
+		logger.GetLogger().Println("cannot create state snapshot", err)
+		return
+	}
+	hash, err := m.CalcHash()
+	if err != nil {
+		logger.GetLogger().Println(err)
+		return
+	}
+	logger.GetLogger().Printf("State snapshot at height %d, chunks %d, hash %s", height, len(m.ChunkHashes), hash.GetHex())
+	old := height - common.SnapshotsKept*common.SnapshotInterval
+	if old > 0 {
+		_ = RemoveSnapshot(old)
+	}
+}
+
+func LoadManifest(height int64) (Manifest, error) {
+	b, err := database.MainDB.Get(append(common.SnapshotManifestDBPrefix[:], common.GetByteInt64(height)...))
+	if err != nil {
+		return Manifest{}, err
+	}
+	return Manifest{}.GetFromBytes(b)
+}
+
+func LoadChunk(hash common.Hash) ([]byte, error) {
+	return database.MainDB.Get(append(common.SnapshotChunkDBPrefix[:], hash.GetBytes()...))
+}
+
+// StoreChunk checks that chunk belongs to manifest and stores it. Returns hash of chunk.
+func StoreChunk(m Manifest, chunk []byte) (common.Hash, error) {
+	hb, err := common.CalcHashToByte(chunk)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	hash := common.GetHashFromBytes(hb)
+	found := false
+	for _, h := range m.ChunkHashes {
+		if h == hash {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return common.Hash{}, fmt.Errorf("chunk is not part of snapshot: StoreChunk")
+	}
+	return hash, database.MainDB.Put(append(common.SnapshotChunkDBPrefix[:], hb...), chunk)
+}
+
+// MissingChunks returns hashes of chunks of manifest not stored yet
+func MissingChunks(m Manifest) []common.Hash {
+	missing := []common.Hash{}
+	for _, h := range m.ChunkHashes {
+		isKey, err := database.MainDB.IsKey(append(common.SnapshotChunkDBPrefix[:], h.GetBytes()...))
+		if err != nil || !isKey {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
+
+// RestoreSnapshot writes state from downloaded chunks and sets node height to snapshot height
+func RestoreSnapshot(m Manifest) error {
+	if len(MissingChunks(m)) > 0 {
+		return fmt.Errorf("not all chunks downloaded: RestoreSnapshot")
+	}
+	for _, h := range m.ChunkHashes {
+		chunk, err := LoadChunk(h)
+		if err != nil {
+			return err
+		}
+		var k, v []byte
+		for len(chunk) > 0 {
+			k, chunk, err = common.BytesWithLenToBytes(chunk)
+			if err != nil {
+				return err
+			}
+			v, chunk, err = common.BytesWithLenToBytes(chunk)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(k, vmStateKey) {
+				blocks.StateMutex.Lock()
+				err = blocks.State.SetFromSnapshotBytes(v)
+				blocks.StateMutex.Unlock()
+			} else {
+				err = database.MainDB.Put(k, v)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := database.MainDB.Put(append(common.SnapshotManifestDBPrefix[:], common.GetByteInt64(m.Height)...), m.GetBytes())
+	if err != nil {
+		return err
+	}
+	err = database.MainDB.Put(common.SnapshotBaseHeightDBPrefix[:], common.GetByteInt64(m.Height))
+	if err != nil {
+		return err
+	}
+	err = account.LoadAccounts(m.Height)
+	if err != nil {
+		return err
+	}
+	err = account.LoadStakingAccounts(m.Height)
+	if err != nil {
+		return err
+	}
+	if err := account.LoadDexAccounts(m.Height); err != nil {
+		logger.GetLogger().Println("no dex accounts in snapshot", err)
+	}
+	if err := transactionsPool.LoadPendingPools(m.Height); err != nil {
+		logger.GetLogger().Println("no pending pools in snapshot", err)
+	}
+	if err := account.LoadRecoveries(m.Height); err != nil {
+		logger.GetLogger().Println("no recoveries in snapshot", err)
+	}
+	if err := account.LoadValidators(m.Height); err != nil {
+		logger.GetLogger().Println("no validators in snapshot", err)
+	}
+	if err := account.LoadGovernance(m.Height); err != nil {
+		logger.GetLogger().Println("no governance in snapshot", err)
+	}
+	if err := account.ApplyGovernedParams(m.Height); err != nil {
+		logger.GetLogger().Println(err)
+	}
+	err = blocks.SetEncryptionFromBlock(m.Height)
+	if err != nil {
+		return err
+	}
+	common.SetHeight(m.Height)
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/okuralabs/okura-node/blocks"
 	"github.com/okuralabs/okura-node/common"
 	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/tcpip"
 	"github.com/okuralabs/okura-node/transactionsDefinition"
 	"github.com/okuralabs/okura-node/transactionsPool"
 )
@@ -26,6 +27,11 @@ type Stats struct {
 	Difficulty              int32   `json:"difficulty"`
 	PriceOracle             float32 `json:"priceOracle"`
 	RandOracle              int64   `json:"randOracle"`
+	DroppedMessages         int64   `json:"droppedMessages"`
+	SyncTargetHeight        int64   `json:"syncTargetHeight"`
+	SyncDownloadedHeight    int64   `json:"syncDownloadedHeight"`
+	SyncPeers               int     `json:"syncPeers"`
+	SyncPendingWindows      int     `json:"syncPendingWindows"`
 	db                      *database.BlockchainDB
 }
 
@@ -56,6 +62,11 @@ func InitStatsManager() {
 			Difficulty:              0,
 			PriceOracle:             1,
 			RandOracle:              0,
+			DroppedMessages:         0,
+			SyncTargetHeight:        0,
+			SyncDownloadedHeight:    0,
+			SyncPeers:               0,
+			SyncPendingWindows:      0,
 			db:                      database.MainDB,
 		},
 	}
@@ -111,8 +122,19 @@ func (sm *StatsManager) UpdateStatistics(newBlock blocks.Block, lastBlock blocks
 	sm.Stats.Tps = float32(ntxs) / float32(sm.Stats.TimeInterval)
 	sm.Stats.TransactionsPending = nt
 	sm.Stats.TransactionsPendingSize = nt * len(empt.GetBytes())
+	sm.Stats.DroppedMessages = tcpip.GetDroppedMessagesCount()
 	sm.Mu.Unlock()
 	if err := sm.Save(); err != nil {
 		logger.GetLogger().Println(err)
 	}
 }
+
+// UpdateSyncProgress updates progress of parallel sync
+func (sm *StatsManager) UpdateSyncProgress(target int64, downloaded int64, peers int, pendingWindows int) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.Stats.SyncTargetHeight = target
+	sm.Stats.SyncDownloadedHeight = downloaded
+	sm.Stats.SyncPeers = peers
+	sm.Stats.SyncPendingWindows = pendingWindows
+}
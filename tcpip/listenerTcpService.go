@@ -233,7 +233,7 @@ func StartNewConnection(ip [4]byte, receiveChan chan []byte, topic [2]byte) {
 
 	logger.GetLogger().Printf("Starting message processing loop for connection to %v", ip)
 
-	rTopic := map[[2]byte][]byte{}
+	mr := newMessageReader(ip, topic, receiveChan)
 
 	for {
 		resetNumber++
@@ -283,45 +283,18 @@ func StartNewConnection(ip [4]byte, receiveChan chan []byte, topic [2]byte) {
 				continue
 			}
 
-			rt, ok := rTopic[topic]
-			if ok {
-				r = append(rt, r...)
-			}
-			if !bytes.Equal(r[len(r)-7:], []byte("<-END->")) {
-				rTopic[topic] = r
-			} else {
-				rTopic[topic] = []byte{}
-			}
-
-			if int32(len(r)) > common.MaxMessageSizeBytes {
-				logger.GetLogger().Println("error: too long message received: ", len(r))
+			err = mr.read(r)
+			if err != nil {
+				logger.GetLogger().Println("error:", err, ip)
 				PeersMutex.Lock()
 				ReduceTrustRegisterPeer(ip)
+				trust, ok := validPeersConnected[ip]
 				PeersMutex.Unlock()
-				rTopic[topic] = []byte{}
-				if trust, ok := validPeersConnected[ip]; ok && trust <= 0 {
+				if ok && trust <= 0 {
 					BanIP(ip)
 					receiveChan <- []byte("EXIT")
 					return
 				}
-				continue
-			}
-			if bytes.Equal(r[len(r)-7:], []byte("<-END->")) {
-				if len(r) > 4 {
-					if bytes.Equal(r[:4], common.MessageInitialization[:]) {
-						receiveChan <- append(ip[:], r[4:]...)
-					} else {
-						logger.GetLogger().Println("wrong MessageInitialization", r[:4], "should be", common.MessageInitialization[:])
-						PeersMutex.Lock()
-						ReduceTrustRegisterPeer(ip)
-						PeersMutex.Unlock()
-						if trust, ok := validPeersConnected[ip]; ok && trust <= 0 {
-							BanIP(ip)
-							receiveChan <- []byte("EXIT")
-							return
-						}
-					}
-				}
 			}
 		}
 	}
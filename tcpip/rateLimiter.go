@@ -0,0 +1,287 @@
+package tcpip
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+const (
+	// time after which unanswered request is not counted as outstanding
+	requestTimeout = 10 * time.Second
+	// bucket not used for this time is full again and can be forgotten
+	bucketIdleTimeout = time.Minute
+	// time block or nonce message waits for space in full receive buffer
+	criticalMessageTimeout = 2 * time.Second
+)
+
+// blocks and nonces are not dropped when buffer is filled by other messages,
+// they wait for space and 1/criticalBufferShare of buffer is reserved for them
+var criticalHeads = map[[2]byte]bool{{'n', 'n'}: true, {'b', 'l'}: true, {'c', 'b'}: true}
+
+// sync responses are critical when they answer outstanding request to peer, otherwise
+// dropped answer would keep request slot taken until requestTimeout
+var responseHeads = map[[2]byte]bool{{'s', 'h'}: true, {'s', 's'}: true, {'s', 'c'}: true}
+
+const criticalBufferShare = 4
+
+// tokenBucket refills rate tokens per second up to capacity
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	return &tokenBucket{
+		tokens:   float64(2 * rate),
+		capacity: float64(2 * rate),
+		rate:     float64(rate),
+		last:     time.Now(),
+	}
+}
+
+// allow takes cost tokens. Message larger than capacity passes only when bucket is full and leaves debt.
+func (tb *tokenBucket) allow(cost float64) bool {
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.capacity {
+		tb.tokens = tb.capacity
+	}
+	tb.last = now
+	need := cost
+	if need > tb.capacity {
+		need = tb.capacity
+	}
+	if tb.tokens < need {
+		return false
+	}
+	tb.tokens -= cost
+	return true
+}
+
+type headKey struct {
+	ip   [4]byte
+	head [2]byte
+}
+
+type topicKey struct {
+	ip    [4]byte
+	topic [2]byte
+}
+
+var (
+	bytesBuckets       = map[topicKey]*tokenBucket{}
+	messagesBuckets    = map[headKey]*tokenBucket{}
+	outstandingRequest = map[[4]byte][]time.Time{}
+	droppedMessages    = map[string]int64{}
+	lastPruned         = time.Now()
+	rateLimitMutex     sync.Mutex
+)
+
+func isExemptFromLimits(ip [4]byte) bool {
+	if bytes.Equal(ip[:], MyIP[:]) || bytes.Equal(ip[:], InternalIP[:]) {
+		return true
+	}
+	_, ok := whiteListIPs[ip]
+	return ok
+}
+
+// AllowMessage checks per peer limits of bytes per second on topic and messages per second of given head
+func AllowMessage(ip [4]byte, topic [2]byte, head []byte, size int) bool {
+	if isExemptFromLimits(ip) {
+		return true
+	}
+	var h [2]byte
+	copy(h[:], head)
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	pruneIdleBuckets()
+	tk := topicKey{ip: ip, topic: topic}
+	bb, ok := bytesBuckets[tk]
+	if !ok {
+		bb = newTokenBucket(common.PeerMaxBytesPerSecond)
+		bytesBuckets[tk] = bb
+	}
+	hk := headKey{ip: ip, head: h}
+	mb, ok := messagesBuckets[hk]
+	if !ok {
+		mb = newTokenBucket(common.PeerMaxMessagesPerSecond)
+		messagesBuckets[hk] = mb
+	}
+	if !mb.allow(1) {
+		droppedMessages[string(topic[:])+"/"+string(h[:])+"/rate"]++
+		return false
+	}
+	if !bb.allow(float64(size)) {
+		droppedMessages[string(topic[:])+"/"+string(h[:])+"/bytes"]++
+		return false
+	}
+	return true
+}
+
+// pruneIdleBuckets forgets buckets and requests of peers not seen for bucketIdleTimeout,
+// needs rateLimitMutex locked
+func pruneIdleBuckets() {
+	now := time.Now()
+	if now.Sub(lastPruned) < bucketIdleTimeout {
+		return
+	}
+	lastPruned = now
+	for k, b := range bytesBuckets {
+		if now.Sub(b.last) > bucketIdleTimeout {
+			delete(bytesBuckets, k)
+		}
+	}
+	for k, b := range messagesBuckets {
+		if now.Sub(b.last) > bucketIdleTimeout {
+			delete(messagesBuckets, k)
+		}
+	}
+	for ip, ts := range outstandingRequest {
+		if len(ts) == 0 || now.Sub(ts[len(ts)-1]) > requestTimeout {
+			delete(outstandingRequest, ip)
+		}
+	}
+}
+
+// EnqueueMessage puts received message from ip to buffer. Block and nonce messages and answers
+// to outstanding requests wait for space, other messages are dropped when buffer is filled above
+// share reserved for critical messages.
+func EnqueueMessage(receiveChan chan []byte, ip [4]byte, topic [2]byte, head []byte, msg []byte) {
+	var h [2]byte
+	copy(h[:], head)
+	response := responseHeads[h] && hasOutstandingRequest(ip)
+	if criticalHeads[h] || response {
+		select {
+		case receiveChan <- msg:
+		case <-time.After(criticalMessageTimeout):
+			RecordDroppedMessage(topic, head)
+			if response {
+				FinishRequest(ip)
+			}
+		}
+		return
+	}
+	if len(receiveChan) >= cap(receiveChan)-cap(receiveChan)/criticalBufferShare {
+		RecordDroppedMessage(topic, head)
+		return
+	}
+	select {
+	case receiveChan <- msg:
+	default:
+		RecordDroppedMessage(topic, head)
+	}
+}
+
+// RecordDroppedMessage counts message dropped because of full receive buffer
+func RecordDroppedMessage(topic [2]byte, head []byte) {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	droppedMessages[string(topic[:])+"/"+string(head)+"/buffer"]++
+}
+
+// GetDroppedMessages returns number of dropped messages by topic/head/reason
+func GetDroppedMessages() map[string]int64 {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	dm := make(map[string]int64, len(droppedMessages))
+	for k, v := range droppedMessages {
+		dm[k] = v
+	}
+	return dm
+}
+
+// GetDroppedMessagesCount returns total number of dropped messages
+func GetDroppedMessagesCount() int64 {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	sum := int64(0)
+	for _, v := range droppedMessages {
+		sum += v
+	}
+	return sum
+}
+
+// StartRequest registers request sent to ip. Returns false when too many requests wait for answer.
+func StartRequest(ip [4]byte) bool {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	now := time.Now()
+	pending := []time.Time{}
+	for _, t := range outstandingRequest[ip] {
+		if now.Sub(t) < requestTimeout {
+			pending = append(pending, t)
+		}
+	}
+	if len(pending) >= common.PeerMaxOutstandingRequests {
+		outstandingRequest[ip] = pending
+		return false
+	}
+	outstandingRequest[ip] = append(pending, now)
+	return true
+}
+
+// hasOutstandingRequest checks if request to ip waits for answer
+func hasOutstandingRequest(ip [4]byte) bool {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	for _, t := range outstandingRequest[ip] {
+		if time.Since(t) < requestTimeout {
+			return true
+		}
+	}
+	return false
+}
+
+// FinishRequest removes the oldest outstanding request to ip
+func FinishRequest(ip [4]byte) {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+	if len(outstandingRequest[ip]) > 0 {
+		outstandingRequest[ip] = outstandingRequest[ip][1:]
+	}
+}
+
+// messageReader assembles messages from data read from connection with peer. Every complete message
+// passes per peer limits before it gets to receive buffer.
+type messageReader struct {
+	ip          [4]byte
+	topic       [2]byte
+	receiveChan chan []byte
+	partial     []byte
+}
+
+func newMessageReader(ip [4]byte, topic [2]byte, receiveChan chan []byte) *messageReader {
+	return &messageReader{ip: ip, topic: topic, receiveChan: receiveChan, partial: []byte{}}
+}
+
+// read appends data to message being received and enqueues message when it is complete.
+// Returns error when peer sends too long message or message with wrong initialization.
+func (mr *messageReader) read(r []byte) error {
+	r = append(mr.partial, r...)
+	if int32(len(r)) > common.MaxMessageSizeBytes {
+		mr.partial = []byte{}
+		return fmt.Errorf("too long message received: %v", len(r))
+	}
+	if !bytes.HasSuffix(r, []byte("<-END->")) {
+		mr.partial = r
+		return nil
+	}
+	mr.partial = []byte{}
+	if len(r) <= 4 {
+		return nil
+	}
+	if !bytes.Equal(r[:4], common.MessageInitialization[:]) {
+		return fmt.Errorf("wrong MessageInitialization %v should be %v", r[:4], common.MessageInitialization[:])
+	}
+	if len(r) < 6 || !AllowMessage(mr.ip, mr.topic, r[4:6], len(r)) {
+		return nil
+	}
+	EnqueueMessage(mr.receiveChan, mr.ip, mr.topic, r[4:6], append(mr.ip[:], r[4:]...))
+	return nil
+}
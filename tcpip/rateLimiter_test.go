@@ -0,0 +1,73 @@
+package tcpip
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+func framedMessage(head string, payload []byte) []byte {
+	m := append(common.MessageInitialization[:], []byte(head)...)
+	m = append(m, payload...)
+	return append(m, []byte("<-END->")...)
+}
+
+func TestMessageReader_AssemblesChunks(t *testing.T) {
+	receiveChan := make(chan []byte, 10)
+	mr := newMessageReader([4]byte{10, 1, 0, 1}, TransactionTopic, receiveChan)
+	m := framedMessage("tx", make([]byte, 100))
+
+	for _, chunk := range [][]byte{m[:3], m[3:50], m[50:]} {
+		if err := mr.read(chunk); err != nil {
+			t.Fatalf("Expected chunk accepted, got %v", err)
+		}
+	}
+	if len(receiveChan) != 1 {
+		t.Fatalf("Expected one assembled message, got %v", len(receiveChan))
+	}
+	if got := <-receiveChan; len(got) != len(m) {
+		t.Errorf("Expected message prefixed by ip, got %v bytes", len(got))
+	}
+}
+
+func TestMessageReader_AppliesPeerLimits(t *testing.T) {
+	defer func(n int64) { common.PeerMaxMessagesPerSecond = n }(common.PeerMaxMessagesPerSecond)
+	common.PeerMaxMessagesPerSecond = 2
+	receiveChan := make(chan []byte, 100)
+	mr := newMessageReader([4]byte{10, 1, 0, 2}, TransactionTopic, receiveChan)
+
+	// bucket holds two seconds of messages
+	for i := 0; i < 10; i++ {
+		if err := mr.read(framedMessage("tx", []byte{byte(i)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(receiveChan) != 4 {
+		t.Errorf("Expected messages above peer rate dropped, got %v enqueued", len(receiveChan))
+	}
+
+	exempt := newMessageReader(MyIP, TransactionTopic, receiveChan)
+	for i := 0; i < 10; i++ {
+		_ = exempt.read(framedMessage("tx", []byte{byte(i)}))
+	}
+	if len(receiveChan) != 14 {
+		t.Errorf("Expected own messages not limited, got %v enqueued", len(receiveChan))
+	}
+}
+
+func TestMessageReader_RejectsWrongMessages(t *testing.T) {
+	defer func(n int32) { common.MaxMessageSizeBytes = n }(common.MaxMessageSizeBytes)
+	receiveChan := make(chan []byte, 10)
+	mr := newMessageReader([4]byte{10, 1, 0, 3}, TransactionTopic, receiveChan)
+
+	if err := mr.read(append([]byte{9, 9, 9, 9, 't', 'x'}, []byte("<-END->")...)); err == nil {
+		t.Errorf("Expected wrong initialization rejected")
+	}
+	common.MaxMessageSizeBytes = 20
+	if err := mr.read(make([]byte, 21)); err == nil {
+		t.Errorf("Expected too long message rejected")
+	}
+	if len(mr.partial) != 0 || len(receiveChan) != 0 {
+		t.Errorf("Expected rejected data dropped, got %v partial bytes and %v messages", len(mr.partial), len(receiveChan))
+	}
+}
@@ -0,0 +1,78 @@
+package transactionsDefinition
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/wallet"
+)
+
+// MigrateKey generates key of current scheme in wallet in place of key of replaced scheme, backs up
+// old wallet and returns transaction registering new key signed with key of other, still valid, scheme
+// and with new key
+func MigrateKey(w *wallet.Wallet, primary bool, height int64) (Transaction, error) {
+	sigName, otherPaused := common.SigName2(), common.IsPaused()
+	if primary {
+		sigName, otherPaused = common.SigName(), common.IsPaused2()
+	}
+	if otherPaused {
+		return Transaction{}, fmt.Errorf("other scheme is paused, key cannot be registered: MigrateKey")
+	}
+	if primary && common.IsPaused() || !primary && common.IsPaused2() {
+		return Transaction{}, fmt.Errorf("scheme %v is paused, wait for replacement: MigrateKey", sigName)
+	}
+	if w.GetSigName(primary) == sigName {
+		return Transaction{}, fmt.Errorf("wallet key already uses scheme %v: MigrateKey", sigName)
+	}
+	// both directories of wallet are moved to backups and wallet is stored again
+	w.HomePathOld = w.HomePath
+	w.HomePath2Old = w.HomePath2
+	err := w.AddNewEncryptionToActiveWallet(sigName, primary)
+	if err != nil {
+		return Transaction{}, err
+	}
+	err = w.Store(true)
+	if err != nil {
+		return Transaction{}, err
+	}
+	pk := w.PublicKey2
+	if primary {
+		pk = w.PublicKey
+	}
+	pk.MainAddress = w.MainAddress
+	tx := Transaction{
+		TxData: TxData{
+			Recipient: w.MainAddress,
+			Amount:    0,
+			OptData:   []byte{},
+			Pubkey:    pk,
+		},
+		TxParam: TxParam{
+			ChainID:     common.GetChainID(),
+			Sender:      w.MainAddress,
+			SendingTime: common.GetCurrentTimeStampInSecond(),
+			Nonce:       int16(rand.Intn(0xffff)),
+		},
+		Hash:      common.Hash{},
+		Signature: common.Signature{},
+		Height:    height,
+		GasPrice:  1,
+	}
+	tx.GasUsage = tx.GasUsageEstimate()
+	err = tx.CalcHashAndSet()
+	if err != nil {
+		return Transaction{}, err
+	}
+	err = tx.Sign(w, !primary)
+	if err != nil {
+		return Transaction{}, err
+	}
+	// new key proves its possession
+	sig, err := w.Sign(tx.GetHash().GetBytes(), primary)
+	if err != nil {
+		return Transaction{}, err
+	}
+	tx.PubKeySignature = *sig
+	return tx, nil
+}
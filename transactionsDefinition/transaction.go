@@ -23,6 +23,9 @@ type Transaction struct {
 	GasUsage        int64            `json:"gas_usage"`
 	OutputLogs      []byte           `json:"outputLogs,omitempty"`
 	ContractAddress common.Address   `json:"contractAddress,omitempty"`
+	// PubKeySignature is signature of hash with attached pubkey of other scheme than Signature,
+	// it proves possession of key registered in key migration
+	PubKeySignature common.Signature `json:"pubkeySignature,omitempty"`
 }
 
 func (mt *Transaction) GetData() TxData {
@@ -63,6 +66,9 @@ func (tx *Transaction) GetString() string {
 	t += "Gas Usage: " + strconv.FormatInt(tx.GasUsage, 10) + "\n"
 	t += "Hash: " + tx.Hash.GetHex() + "\n"
 	t += "Signature: " + tx.Signature.GetHex() + "\n"
+	if len(tx.PubKeySignature.GetBytes()) > 0 {
+		t += "PubKey Signature: " + tx.PubKeySignature.GetHex() + "\n"
+	}
 	t += "Contract Address: " + tx.ContractAddress.GetHex() + "\n"
 	t += "Contract Logs:\n" + string(tx.OutputLogs) + "\n"
 	return t
@@ -115,6 +121,17 @@ func (tx *Transaction) GetFromBytes(b []byte) (Transaction, []byte, error) {
 		return Transaction{}, nil, err
 	}
 	at.OutputLogs = toBytes[:]
+	if len(leftb2) > 0 {
+		pb, leftb3, err := common.BytesWithLenToBytes(leftb2)
+		if err != nil {
+			return Transaction{}, nil, err
+		}
+		at.PubKeySignature, err = common.GetSignatureFromBytes(pb, tp.Sender)
+		if err != nil {
+			return Transaction{}, nil, err
+		}
+		return at, leftb3, nil
+	}
 	return at, leftb2, nil
 }
 
@@ -157,6 +174,10 @@ func (mt *Transaction) GetBytes() []byte {
 		b = append(b, mt.ContractAddress.GetBytes()...)
 		olb := common.BytesToLenAndBytes(mt.OutputLogs)
 		b = append(b, olb...)
+		// signature of registered pubkey follows only when set, so other transactions keep their bytes
+		if len(mt.PubKeySignature.GetBytes()) > 0 {
+			b = append(b, common.BytesToLenAndBytes(mt.PubKeySignature.GetBytes())...)
+		}
 
 		return b
 	}
@@ -263,7 +284,9 @@ func (tx *Transaction) Verify() bool {
 
 	canAccountBeModified := account.CanBeModifiedAccount(tx.TxData.Recipient.GetBytes())
 
-	if canAccountBeModified == false && (tx.TxData.EscrowTransactionsDelay > 0 || tx.TxData.MultiSignNumber > 0) {
+	// escrow and multi signature account can change its own configuration, change needs approvals or delay
+	selfConfiguration := bytes.Equal(tx.TxData.Recipient.GetBytes(), tx.TxParam.Sender.GetBytes())
+	if canAccountBeModified == false && !selfConfiguration && (tx.TxData.EscrowTransactionsDelay > 0 || tx.TxData.MultiSignNumber > 0) {
 		logger.GetLogger().Println("Account cannot be modified")
 		return false
 	}
@@ -300,6 +323,19 @@ func (tx *Transaction) Verify() bool {
 
 	pk := tx.TxData.GetPubKey()
 	pkb := pk.GetBytes()
+	if len(pkb) > 0 && pk.Primary != primary {
+		// new key of replaced scheme is registered with signature of other, still valid, key of sender
+		// and signature of new key proving its possession
+		if pk.MainAddress.ByteValue != tx.TxParam.Sender.ByteValue {
+			logger.GetLogger().Println("registered pubkey has to belong to sender")
+			return false
+		}
+		if !verifyPubKeySignature(b, tx.PubKeySignature, pk) {
+			logger.GetLogger().Println("registered pubkey has to sign transaction")
+			return false
+		}
+		pkb = nil
+	}
 	if len(pkb) == 0 {
 		pkp, err := pubkeys.LoadPubKeyWithPrimary(tx.GetSenderAddress(), primary)
 		if err != nil {
@@ -307,9 +343,29 @@ func (tx *Transaction) Verify() bool {
 		}
 		pkb = pkp.GetBytes()
 	}
+	// pubkeys replaced in social recovery cannot sign
+	pka, err := common.PubKeyToAddress(pkb, primary)
+	if err != nil || pubkeys.IsPubKeyRevoked(pka.GetBytes()) {
+		logger.GetLogger().Println("pubkey is revoked or invalid")
+		return false
+	}
 	return wallet.Verify(b, signature.GetBytes(), pkb)
 }
 
+// verifyPubKeySignature checks that pk registered in transaction signed hash and is not registered
+// already for other main address
+func verifyPubKeySignature(hash []byte, sig common.Signature, pk common.PubKey) bool {
+	sb := sig.GetBytes()
+	if len(sb) == 0 || (sb[0] == 0) != pk.Primary {
+		return false
+	}
+	stored, err := pubkeys.LoadPubKey(pk.Address.GetBytes())
+	if err == nil && !bytes.Equal(stored.MainAddress.GetBytes(), pk.MainAddress.GetBytes()) {
+		return false
+	}
+	return wallet.Verify(hash, sb, pk.GetBytes())
+}
+
 func (tx *Transaction) Sign(w *wallet.Wallet, primary bool) error {
 	b := tx.GetHash()
 	sign, err := w.Sign(b.GetBytes(), primary)
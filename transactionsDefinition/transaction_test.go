@@ -0,0 +1,194 @@
+package transactionsDefinition
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/crypto/oqs"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/pubkeys"
+)
+
+var testSender = common.Address{ByteValue: [common.AddressLength]byte{0, 9, 9}, Primary: true}
+
+type testKey struct {
+	pk     common.PubKey
+	signer oqs.Signature
+}
+
+func newTestKey(t *testing.T, primary bool, mainAddress common.Address) *testKey {
+	k := &testKey{}
+	sigName := common.SigName2()
+	if primary {
+		sigName = common.SigName()
+	}
+	if err := k.signer.Init(sigName, nil); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := k.signer.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.pk.Init(pub, mainAddress); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func (k *testKey) sign(t *testing.T, hash []byte) common.Signature {
+	s, err := k.signer.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := byte(0)
+	if !k.pk.Primary {
+		scheme = 1
+	}
+	return common.Signature{ByteValue: append([]byte{scheme}, s...), Address: testSender, Primary: k.pk.Primary}
+}
+
+func testTransaction(pk common.PubKey) Transaction {
+	tx := Transaction{Height: 10, GasPrice: 1, GasUsage: 21000}
+	tx.TxParam.ChainID = common.GetChainID()
+	tx.TxParam.Sender = testSender
+	tx.TxParam.Nonce = 7
+	tx.TxData.Recipient = testSender
+	tx.TxData.OptData = []byte{}
+	tx.TxData.Pubkey = pk
+	_ = tx.CalcHashAndSet()
+	return tx
+}
+
+func TestTransaction_BytesWithAndWithoutPubKeySignature(t *testing.T) {
+	primaryKey := newTestKey(t, true, testSender)
+	defer primaryKey.signer.Clean()
+	newKey := newTestKey(t, false, testSender)
+	defer newKey.signer.Clean()
+
+	plain := testTransaction(common.PubKey{})
+	plain.Signature = primaryKey.sign(t, plain.Hash.GetBytes())
+	migration := testTransaction(newKey.pk)
+	migration.Signature = primaryKey.sign(t, migration.Hash.GetBytes())
+	migration.PubKeySignature = newKey.sign(t, migration.Hash.GetBytes())
+
+	for _, tx := range []Transaction{plain, migration} {
+		b := tx.GetBytes()
+		got, left, err := (&Transaction{}).GetFromBytes(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(left) != 0 {
+			t.Errorf("Expected all bytes decoded, got %v left", len(left))
+		}
+		if got.Hash != tx.Hash || !bytes.Equal(got.Signature.GetBytes(), tx.Signature.GetBytes()) ||
+			!bytes.Equal(got.PubKeySignature.GetBytes(), tx.PubKeySignature.GetBytes()) ||
+			!bytes.Equal(got.TxData.Pubkey.GetBytes(), tx.TxData.Pubkey.GetBytes()) {
+			t.Errorf("Expected decoded transaction %v, got %v", tx.GetString(), got.GetString())
+		}
+		if gb := got.GetBytes(); !bytes.Equal(gb, b) {
+			t.Errorf("Expected decoded transaction encoded the same")
+		}
+	}
+
+	// transactions without signature of pubkey keep their bytes, signature is only appended
+	withoutSig := migration
+	withoutSig.PubKeySignature = common.Signature{}
+	b := migration.GetBytes()
+	if !bytes.HasPrefix(b, withoutSig.GetBytes()) ||
+		!bytes.Equal(b[len(withoutSig.GetBytes()):], common.BytesToLenAndBytes(migration.PubKeySignature.GetBytes())) {
+		t.Errorf("Expected pubkey signature appended to bytes of transaction")
+	}
+	if got, _, err := (&Transaction{}).GetFromBytes(withoutSig.GetBytes()); err != nil || len(got.PubKeySignature.GetBytes()) != 0 {
+		t.Errorf("Expected no pubkey signature decoded, got %v", err)
+	}
+	if _, _, err := (&Transaction{}).GetFromBytes(b[:len(b)-1]); err == nil {
+		t.Errorf("Expected truncated pubkey signature rejected")
+	}
+}
+
+func TestVerifyPubKeySignature(t *testing.T) {
+	newKey := newTestKey(t, false, testSender)
+	defer newKey.signer.Clean()
+	otherKey := newTestKey(t, false, testSender)
+	defer otherKey.signer.Clean()
+	hash := testTransaction(newKey.pk).Hash.GetBytes()
+
+	wrongScheme := newKey.sign(t, hash)
+	wrongScheme.ByteValue = append([]byte{0}, wrongScheme.ByteValue[1:]...)
+	tests := []struct {
+		name string
+		sig  common.Signature
+		want bool
+	}{
+		{"signed by registered key", newKey.sign(t, hash), true},
+		{"missing", common.Signature{}, false},
+		{"marked with other scheme", wrongScheme, false},
+		{"signed by other key", otherKey.sign(t, hash), false},
+		{"other message", newKey.sign(t, common.Hash{1}.GetBytes()), false},
+	}
+	for _, tt := range tests {
+		if got := verifyPubKeySignature(hash, tt.sig, newKey.pk); got != tt.want {
+			t.Errorf("%s: Expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// key registered already for other account cannot be taken over
+	stored := newKey.pk
+	stored.MainAddress = common.Address{ByteValue: [common.AddressLength]byte{0, 8}, Primary: true}
+	sb, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := append(common.PubKeyMarshalDBPrefix[:], newKey.pk.Address.GetBytes()...)
+	if err = database.MainDB.Put(key, sb); err != nil {
+		t.Fatal(err)
+	}
+	defer database.MainDB.Delete(key)
+	if verifyPubKeySignature(hash, newKey.sign(t, hash), newKey.pk) {
+		t.Errorf("Expected key of other account rejected")
+	}
+}
+
+func TestTransaction_VerifyMigrationAndRevokedKey(t *testing.T) {
+	primaryKey := newTestKey(t, true, testSender)
+	defer primaryKey.signer.Clean()
+	newKey := newTestKey(t, false, testSender)
+	defer newKey.signer.Clean()
+
+	tx := testTransaction(primaryKey.pk)
+	tx.Signature = primaryKey.sign(t, tx.Hash.GetBytes())
+	if !tx.Verify() {
+		t.Fatalf("Expected transaction with attached pubkey verified")
+	}
+	otherAccount := newKey.pk
+	otherAccount.MainAddress = common.Address{ByteValue: [common.AddressLength]byte{0, 8}, Primary: true}
+	tests := []struct {
+		name string
+		pk   common.PubKey
+		sign bool
+	}{
+		{"pubkey without its signature", newKey.pk, false},
+		{"pubkey of other account", otherAccount, true},
+	}
+	for _, tt := range tests {
+		m := testTransaction(tt.pk)
+		m.Signature = primaryKey.sign(t, m.Hash.GetBytes())
+		if tt.sign {
+			m.PubKeySignature = newKey.sign(t, m.Hash.GetBytes())
+		}
+		if m.Verify() {
+			t.Errorf("%s: Expected migration rejected", tt.name)
+		}
+	}
+
+	const height = int64(1) << 40
+	if err := pubkeys.RevokePubKey(primaryKey.pk.Address, height); err != nil {
+		t.Fatal(err)
+	}
+	defer pubkeys.RemoveRevokedPubKeysFromDB(height)
+	if tx.Verify() {
+		t.Errorf("Expected transaction signed with revoked pubkey rejected")
+	}
+}
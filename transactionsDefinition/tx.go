@@ -19,6 +19,7 @@ type TxData struct {
 	ReleasePerBlock            int64                        `json:"releasePerBlock,omitempty"`
 	DelegatedAccountForLocking common.Address               `json:"delegatedAccountForLocking,omitempty"`
 	EscrowTransactionsDelay    int64                        `json:"escrowTransactionsDelay,omitempty"`
+	EscrowGuardian             [common.AddressLength]byte   `json:"escrowGuardian,omitempty"`
 	MultiSignNumber            uint8                        `json:"multiSignNumber,omitempty"`
 	MultiSignAddresses         [][common.AddressLength]byte `json:"multiSignAddresses,omitempty"`
 }
@@ -38,6 +39,9 @@ func (td TxData) GetString() string {
 	}
 	if td.EscrowTransactionsDelay > 0 {
 		t += "Escrow account modification with delay: " + strconv.FormatInt(td.EscrowTransactionsDelay, 10) + " blocks\n"
+		if td.EscrowGuardian != [common.AddressLength]byte{} {
+			t += "Escrow guardian: " + hexutil.Encode(td.EscrowGuardian[:]) + "\n"
+		}
 	}
 	if td.MultiSignNumber > 0 {
 		t += "Multi Signature account with \n"
@@ -88,7 +92,12 @@ func (md TxData) GetBytes() ([]byte, error) {
 	bl = append(bl, common.BytesToLenAndBytes(common.GetByteInt64(md.LockedAmount))...)
 	bl = append(bl, common.BytesToLenAndBytes(common.GetByteInt64(md.ReleasePerBlock))...)
 	bl = append(bl, common.BytesToLenAndBytes(md.DelegatedAccountForLocking.GetBytes())...)
-	bl = append(bl, common.BytesToLenAndBytes(common.GetByteInt64(md.EscrowTransactionsDelay))...)
+	// guardian of escrow follows delay only when set, so transactions without guardian keep their bytes
+	etd := common.GetByteInt64(md.EscrowTransactionsDelay)
+	if md.EscrowGuardian != [common.AddressLength]byte{} {
+		etd = append(etd, md.EscrowGuardian[:]...)
+	}
+	bl = append(bl, common.BytesToLenAndBytes(etd)...)
 	bl = append(bl, common.BytesToLenAndBytes([]byte{md.MultiSignNumber})...)
 	for _, msa := range md.MultiSignAddresses {
 		bl = append(bl, common.BytesToLenAndBytes(msa[:])...)
@@ -163,7 +172,11 @@ func (TxData) GetFromBytes(data []byte) (TxData, []byte, error) {
 	if err != nil {
 		return TxData{}, nil, err
 	}
-	md.EscrowTransactionsDelay = common.GetInt64FromByte(etd)
+	if len(etd) != 8 && len(etd) != 8+common.AddressLength {
+		return TxData{}, nil, fmt.Errorf("wrong length of escrow parameters in transaction")
+	}
+	md.EscrowTransactionsDelay = common.GetInt64FromByte(etd[:8])
+	copy(md.EscrowGuardian[:], etd[8:])
 
 	msn, left, err := common.BytesWithLenToBytes(left)
 	if err != nil {
@@ -0,0 +1,65 @@
+package transactionsDefinition
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+)
+
+func escrowTxData(delay int64, guardian [common.AddressLength]byte) TxData {
+	return TxData{
+		Recipient:               common.Address{ByteValue: [common.AddressLength]byte{1}, Primary: true},
+		EscrowTransactionsDelay: delay,
+		EscrowGuardian:          guardian,
+	}
+}
+
+func TestTxData_EscrowGuardianRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    int64
+		guardian [common.AddressLength]byte
+	}{
+		{"delay only", 100, [common.AddressLength]byte{}},
+		{"delay with guardian", 100, [common.AddressLength]byte{7, 7}},
+	}
+	for _, tt := range tests {
+		td := escrowTxData(tt.delay, tt.guardian)
+		b, err := td.GetBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, left, err := TxData{}.GetFromBytes(b)
+		if err != nil {
+			t.Errorf("%s: Expected data decoded, got %v", tt.name, err)
+			continue
+		}
+		if got.EscrowTransactionsDelay != tt.delay || got.EscrowGuardian != tt.guardian || len(left) != 0 {
+			t.Errorf("%s: Expected delay %v and guardian %v, got %v and %v", tt.name, tt.delay, tt.guardian,
+				got.EscrowTransactionsDelay, got.EscrowGuardian)
+		}
+		gb, err := got.GetBytes()
+		if err != nil || !bytes.Equal(gb, b) {
+			t.Errorf("%s: Expected decoded data encoded the same", tt.name)
+		}
+	}
+}
+
+func TestTxData_WithoutGuardianKeepsBytes(t *testing.T) {
+	withoutGuardian, err := escrowTxData(100, [common.AddressLength]byte{}).GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withGuardian, err := escrowTxData(100, [common.AddressLength]byte{7}).GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// delay is encoded in 8 bytes as before guardian was introduced, guardian adds only its address
+	if len(withGuardian)-len(withoutGuardian) != common.AddressLength {
+		t.Errorf("Expected guardian to add %v bytes, got %v", common.AddressLength, len(withGuardian)-len(withoutGuardian))
+	}
+	if !bytes.Contains(withoutGuardian, common.BytesToLenAndBytes(common.GetByteInt64(100))) {
+		t.Errorf("Expected delay without guardian encoded in 8 bytes")
+	}
+}
@@ -0,0 +1,160 @@
+package transactionsPool
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/database"
+	"github.com/okuralabs/okura-node/logger"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+// Marshal returns pool transactions with their priorities sorted by hash, so every node
+// stores the same bytes for the same pending state
+func (tp *TransactionPool) Marshal() []byte {
+	tp.rwmutex.RLock()
+	items := make([]*Item, len(tp.priorityQueue))
+	copy(items, tp.priorityQueue)
+	tp.rwmutex.RUnlock()
+	sort.Slice(items, func(i, j int) bool {
+		return bytes.Compare(items[i].value[:], items[j].value[:]) < 0
+	})
+	b := common.GetByteInt32(int32(len(items)))
+	for _, item := range items {
+		b = append(b, common.GetByteInt64(item.priority)...)
+		b = append(b, common.BytesToLenAndBytes(item.Transaction.GetBytes())...)
+	}
+	return b
+}
+
+// Unmarshal replaces pool content with transactions encoded by Marshal. Banned transactions are kept.
+func (tp *TransactionPool) Unmarshal(b []byte) error {
+	if len(b) < 4 {
+		return fmt.Errorf("too short pending pool bytes: Unmarshal")
+	}
+	n := common.GetInt32FromByte(b[:4])
+	b = b[4:]
+	items := make([]*Item, 0, n)
+	for i := int32(0); i < n; i++ {
+		if len(b) < 8 {
+			return fmt.Errorf("too short pending pool bytes: Unmarshal")
+		}
+		priority := common.GetInt64FromByte(b[:8])
+		txb, rest, err := common.BytesWithLenToBytes(b[8:])
+		if err != nil {
+			return err
+		}
+		b = rest
+		tx := transactionsDefinition.Transaction{}
+		tx, _, err = tx.GetFromBytes(txb)
+		if err != nil {
+			return err
+		}
+		items = append(items, NewItem(tx, priority))
+	}
+	if len(b) > 0 {
+		return fmt.Errorf("too long pending pool bytes: Unmarshal")
+	}
+	tp.rwmutex.Lock()
+	tp.transactions = make(map[[common.HashLength]byte]transactionsDefinition.Transaction)
+	tp.transactionIndices = map[[common.HashLength]byte]int{}
+	tp.priorityQueue = make(PriorityQueue, 0, len(items))
+	for _, item := range items {
+		tp.transactions[item.value] = item.Transaction
+		heap.Push(&tp.priorityQueue, item)
+	}
+	tp.rwmutex.Unlock()
+	tp.updateIndices()
+	return nil
+}
+
+// Clear removes all transactions from pool
+func (tp *TransactionPool) Clear() {
+	tp.rwmutex.Lock()
+	tp.transactions = make(map[[common.HashLength]byte]transactionsDefinition.Transaction)
+	tp.transactionIndices = map[[common.HashLength]byte]int{}
+	tp.priorityQueue = make(PriorityQueue, 0)
+	tp.rwmutex.Unlock()
+}
+
+func pendingPoolKey(prefix [2]byte, height int64) []byte {
+	return append(prefix[:], common.GetByteInt64(height)...)
+}
+
+// StorePendingPools stores escrow and multisig pools as they are after block at height was processed
+func StorePendingPools(height int64) error {
+	if height < 0 {
+		height = common.GetHeight()
+	}
+	err := database.MainDB.Put(pendingPoolKey(common.PendingEscrowPoolDBPrefix, height), PoolTxEscrow.Marshal())
+	if err != nil {
+		logger.GetLogger().Println("cannot store escrow pool", err)
+		return err
+	}
+	err = database.MainDB.Put(pendingPoolKey(common.PendingMultiSignPoolDBPrefix, height), PoolTxMultiSign.Marshal())
+	if err != nil {
+		logger.GetLogger().Println("cannot store multisig pool", err)
+		return err
+	}
+	return nil
+}
+
+// LoadPendingPools restores escrow and multisig pools stored at height. When height < 0
+// the last stored height is used.
+func LoadPendingPools(height int64) error {
+	var err error
+	if height < 0 {
+		height, err = LastHeightStoredInPendingPools()
+		if err != nil {
+			logger.GetLogger().Println(err)
+		}
+		if height < 0 {
+			return nil
+		}
+	}
+	b, err := database.MainDB.Get(pendingPoolKey(common.PendingEscrowPoolDBPrefix, height))
+	if err != nil || b == nil {
+		return fmt.Errorf("cannot load escrow pool at height %v: LoadPendingPools", height)
+	}
+	err = PoolTxEscrow.Unmarshal(b)
+	if err != nil {
+		return err
+	}
+	b, err = database.MainDB.Get(pendingPoolKey(common.PendingMultiSignPoolDBPrefix, height))
+	if err != nil || b == nil {
+		return fmt.Errorf("cannot load multisig pool at height %v: LoadPendingPools", height)
+	}
+	return PoolTxMultiSign.Unmarshal(b)
+}
+
+func RemovePendingPoolsFromDB(height int64) error {
+	err := database.MainDB.Delete(pendingPoolKey(common.PendingEscrowPoolDBPrefix, height))
+	if err != nil {
+		logger.GetLogger().Println("cannot remove escrow pool", err)
+		return err
+	}
+	err = database.MainDB.Delete(pendingPoolKey(common.PendingMultiSignPoolDBPrefix, height))
+	if err != nil {
+		logger.GetLogger().Println("cannot remove multisig pool", err)
+		return err
+	}
+	return nil
+}
+
+func LastHeightStoredInPendingPools() (int64, error) {
+	i := database.FirstStoredHeight()
+	for {
+		isKey, err := database.MainDB.IsKey(pendingPoolKey(common.PendingEscrowPoolDBPrefix, i))
+		if err != nil {
+			return i - 1, err
+		}
+		if !isKey {
+			break
+		}
+		i++
+	}
+	return i - 1, nil
+}
@@ -0,0 +1,113 @@
+package transactionsPool
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/transactionsDefinition"
+)
+
+func pendingTestTx(sender byte, height int64, delay int64) transactionsDefinition.Transaction {
+	tx := transactionsDefinition.Transaction{Height: height, GasPrice: 1, GasUsage: 21000}
+	tx.TxParam.ChainID = common.GetChainID()
+	tx.TxParam.Sender = common.Address{ByteValue: [common.AddressLength]byte{sender}, Primary: true}
+	tx.TxParam.SendingTime = height
+	tx.TxData.Amount = height
+	tx.TxData.EscrowTransactionsDelay = delay
+	tx.Signature = common.Signature{ByteValue: append([]byte{0}, make([]byte, common.SignatureLength())...), Primary: true}
+	_ = tx.CalcHashAndSet()
+	return tx
+}
+
+func TestPendingPool_RoundTripKeepsEscrowPriority(t *testing.T) {
+	pool := NewTransactionPool(100, 1)
+	early := pendingTestTx(1, 10, 5)
+	late := pendingTestTx(2, 20, 0)
+	pool.AddTransaction(late, common.Hash{})
+	pool.AddTransaction(early, common.Hash{})
+
+	restored := NewTransactionPool(100, 1)
+	if err := restored.Unmarshal(pool.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored.Marshal(), pool.Marshal()) {
+		t.Errorf("Expected restored pool encoded the same")
+	}
+	tests := []struct {
+		height int64
+		mature int
+	}{
+		{14, 0},
+		{15, 1},
+		{20, 2},
+	}
+	for _, tt := range tests {
+		if got := len(restored.PeekTransactions(100, tt.height)); got != tt.mature {
+			t.Errorf("Expected %v escrow transactions mature at %v, got %v", tt.mature, tt.height, got)
+		}
+	}
+	if txs := restored.PeekTransactions(100, 15); len(txs) != 1 || !bytes.Equal(txs[0].Hash.GetBytes(), early.Hash.GetBytes()) {
+		t.Errorf("Expected transaction with earlier maturity released first")
+	}
+}
+
+func TestPendingPool_MultiSignKeyedByProposal(t *testing.T) {
+	pool := NewTransactionPool(100, 2)
+	proposal := pendingTestTx(1, 10, 0)
+	vote := pendingTestTx(2, 11, 0)
+	pool.AddTransaction(vote, proposal.Hash)
+
+	restored := NewTransactionPool(100, 2)
+	if err := restored.Unmarshal(pool.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	key := common.GetInt64FromByte(proposal.Hash.GetBytes())
+	if txs := restored.PeekTransactions(100, key); len(txs) != 1 || !bytes.Equal(txs[0].Hash.GetBytes(), vote.Hash.GetBytes()) {
+		t.Errorf("Expected vote found under proposal after restore, got %v", len(txs))
+	}
+}
+
+func TestPendingPool_MarshalIndependentOfInsertionOrder(t *testing.T) {
+	txs := []transactionsDefinition.Transaction{pendingTestTx(1, 10, 1), pendingTestTx(2, 11, 1), pendingTestTx(3, 12, 1)}
+	p1 := NewTransactionPool(100, 1)
+	p2 := NewTransactionPool(100, 1)
+	for i := range txs {
+		p1.AddTransaction(txs[i], common.Hash{})
+		p2.AddTransaction(txs[len(txs)-1-i], common.Hash{})
+	}
+	if !bytes.Equal(p1.Marshal(), p2.Marshal()) {
+		t.Errorf("Expected the same bytes for the same pending state")
+	}
+	if err := p1.Unmarshal(p1.Marshal()[:10]); err == nil {
+		t.Errorf("Expected truncated bytes rejected")
+	}
+}
+
+func TestStoreAndLoadPendingPools(t *testing.T) {
+	const height = int64(1) << 40
+	defer func(e, m *TransactionPool) { PoolTxEscrow, PoolTxMultiSign = e, m }(PoolTxEscrow, PoolTxMultiSign)
+	defer RemovePendingPoolsFromDB(height)
+	PoolTxEscrow = NewTransactionPool(100, 1)
+	PoolTxMultiSign = NewTransactionPool(100, 2)
+	escrowed := pendingTestTx(1, 10, 5)
+	vote := pendingTestTx(2, 11, 0)
+	PoolTxEscrow.AddTransaction(escrowed, common.Hash{})
+	PoolTxMultiSign.AddTransaction(vote, escrowed.Hash)
+	if err := StorePendingPools(height); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart or reset empties pools, they are restored from DB
+	PoolTxEscrow.Clear()
+	PoolTxMultiSign.Clear()
+	if err := LoadPendingPools(height); err != nil {
+		t.Fatal(err)
+	}
+	if !PoolTxEscrow.TransactionExists(escrowed.Hash.GetBytes()) || !PoolTxMultiSign.TransactionExists(vote.Hash.GetBytes()) {
+		t.Errorf("Expected pending escrow and multisig transactions restored")
+	}
+	if err := LoadPendingPools(height + 1); err == nil {
+		t.Errorf("Expected error when pools are not stored at height")
+	}
+}
@@ -177,6 +177,16 @@ func (tp *TransactionPool) TransactionExists(hash []byte) bool {
 	return exists
 }
 
+// GetTransactionByHash returns transaction from pool without removing it
+func (tp *TransactionPool) GetTransactionByHash(hash []byte) (transactionsDefinition.Transaction, bool) {
+	h := [common.HashLength]byte{}
+	copy(h[:], hash)
+	tp.rwmutex.RLock()
+	defer tp.rwmutex.RUnlock()
+	tx, exists := tp.transactions[h]
+	return tx, exists
+}
+
 func (tp *TransactionPool) PopTransactionByHash(hash []byte) transactionsDefinition.Transaction {
 	h := [common.HashLength]byte{}
 	copy(h[:], hash)
@@ -198,3 +208,53 @@ func (tp *TransactionPool) NumberOfTransactions() int {
 	defer tp.rwmutex.RUnlock()
 	return len(tp.transactions)
 }
+
+// SaltedShortHash is first ShortHashLength bytes of hash of salt and transaction hash. Salt differs
+// for every block, so colliding short ids of two transactions cannot be prepared in advance.
+func SaltedShortHash(hash []byte, salt []byte) common.ShortHash {
+	s := common.ShortHash{}
+	b, err := common.CalcHashToByte(append(append([]byte{}, salt...), hash...))
+	if err != nil {
+		s.Set(hash[:common.ShortHashLength])
+		return s
+	}
+	s.Set(b[:common.ShortHashLength])
+	return s
+}
+
+// HashesByShortHashes resolves short transaction ids salted with salt to full hashes of transactions in pool.
+// Short id which matches none or more than one transaction is not returned.
+func (tp *TransactionPool) HashesByShortHashes(shorts []common.ShortHash, salt []byte) map[common.ShortHash]common.Hash {
+	wanted := make(map[common.ShortHash]int, len(shorts))
+	for _, s := range shorts {
+		wanted[s] = 0
+	}
+	found := make(map[common.ShortHash]common.Hash, len(shorts))
+	tp.rwmutex.RLock()
+	defer tp.rwmutex.RUnlock()
+	for h := range tp.transactions {
+		s := SaltedShortHash(h[:], salt)
+		if _, ok := wanted[s]; !ok {
+			continue
+		}
+		wanted[s]++
+		found[s] = common.Hash(h)
+	}
+	for s, n := range wanted {
+		if n > 1 {
+			delete(found, s)
+		}
+	}
+	return found
+}
+
+// GetAllTransactions returns all transactions in pool in no particular order
+func (tp *TransactionPool) GetAllTransactions() []transactionsDefinition.Transaction {
+	tp.rwmutex.RLock()
+	defer tp.rwmutex.RUnlock()
+	txs := make([]transactionsDefinition.Transaction, 0, len(tp.transactions))
+	for _, tx := range tp.transactions {
+		txs = append(txs, tx)
+	}
+	return txs
+}
@@ -0,0 +1,215 @@
+package voting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/crypto/oqs"
+	"github.com/okuralabs/okura-node/database"
+)
+
+// VoteRecord is vote for encryption scheme sent by delegated account in nonce transaction
+type VoteRecord struct {
+	Height           int64         `json:"height"`
+	DelegatedAccount int           `json:"delegated_account"`
+	Staked           int64         `json:"staked"`
+	Primary          bool          `json:"primary"`
+	Config           oqs.ConfigEnc `json:"config"`
+}
+
+// TallyEntry sums stake of delegated accounts voting for the same configuration
+type TallyEntry struct {
+	Config            oqs.ConfigEnc `json:"config"`
+	Staked            int64         `json:"staked"`
+	DelegatedAccounts []int         `json:"delegated_accounts"`
+}
+
+// EncryptionTally compares current votes with thresholds used in block verification
+type EncryptionTally struct {
+	Height           int64        `json:"height"`
+	Primary          bool         `json:"primary"`
+	Entries          []TallyEntry `json:"entries"`
+	VotedStaked      int64        `json:"voted_staked"`
+	TotalStaked      int64        `json:"total_staked"`
+	PauseThreshold   int64        `json:"pause_threshold"`   // votes have to exceed 1/3 of staked coins
+	ReplaceThreshold int64        `json:"replace_threshold"` // votes have to exceed 2/3 of staked coins
+	CanPause         bool         `json:"can_pause"`
+	CanReplace       bool         `json:"can_replace"`
+}
+
+// SchemeChange is encryption scheme applied by block with tally of votes before the change
+type SchemeChange struct {
+	Height  int64           `json:"height"`
+	Primary bool            `json:"primary"`
+	Config  oqs.ConfigEnc   `json:"config"`
+	Tally   EncryptionTally `json:"tally"`
+}
+
+func primaryByte(primary bool) byte {
+	if primary {
+		return 0
+	}
+	return 1
+}
+
+func voteRecordsKey(height int64) []byte {
+	return append(common.EncryptionVotesDBPrefix[:], common.GetByteInt64(height)...)
+}
+
+func schemeChangeKey(height int64, primary bool) []byte {
+	return append(append(common.EncryptionChangesDBPrefix[:], common.GetByteInt64(height)...), primaryByte(primary))
+}
+
+// storeVoteRecord keeps last vote of delegated account for scheme at height
+func storeVoteRecord(value []byte, height int64, id uint8, staked int64, primary bool) error {
+	enc, err := oqs.FromBytesToEncryptionConfig(value)
+	if err != nil {
+		return err
+	}
+	rs, err := LoadVoteRecords(height)
+	if err != nil {
+		rs = []VoteRecord{}
+	}
+	r := VoteRecord{Height: height, DelegatedAccount: int(id), Staked: staked, Primary: primary, Config: enc}
+	replaced := false
+	for i := range rs {
+		if rs[i].DelegatedAccount == r.DelegatedAccount && rs[i].Primary == primary {
+			rs[i] = r
+			replaced = true
+		}
+	}
+	if !replaced {
+		rs = append(rs, r)
+	}
+	b, err := json.Marshal(rs)
+	if err != nil {
+		return err
+	}
+	return database.MainDB.Put(voteRecordsKey(height), b)
+}
+
+// LoadVoteRecords returns votes for encryption schemes sent for height
+func LoadVoteRecords(height int64) ([]VoteRecord, error) {
+	rs := []VoteRecord{}
+	b, err := database.MainDB.Get(voteRecordsKey(height))
+	if err != nil {
+		return rs, err
+	}
+	err = json.Unmarshal(b, &rs)
+	return rs, err
+}
+
+// LoadVoteRecordsInRange returns votes for heights from to to, heights without votes are skipped
+func LoadVoteRecordsInRange(from, to int64) ([]VoteRecord, error) {
+	if from < 0 || to < from {
+		return nil, fmt.Errorf("wrong range of heights: LoadVoteRecordsInRange")
+	}
+	if to-from >= common.MaxOracleHistoryRange {
+		return nil, fmt.Errorf("range of heights larger than %v: LoadVoteRecordsInRange", common.MaxOracleHistoryRange)
+	}
+	all := []VoteRecord{}
+	for h := from; h <= to; h++ {
+		rs, err := LoadVoteRecords(h)
+		if err != nil {
+			continue
+		}
+		all = append(all, rs...)
+	}
+	return all, nil
+}
+
+// Tally groups current votes by configuration, thresholds are checked as in block verification
+func Tally(height int64, totalStaked int64, primary bool) EncryptionTally {
+	t := EncryptionTally{
+		Height:           height,
+		Primary:          primary,
+		Entries:          []TallyEntry{},
+		TotalStaked:      totalStaked,
+		PauseThreshold:   totalStaked / 3,
+		ReplaceThreshold: 2 * totalStaked / 3,
+	}
+	votes := VotesEncryption2
+	if primary {
+		votes = VotesEncryption1
+	}
+	VotesEncryptionMutex.Lock()
+	ids := make([]int, 0, len(votes))
+	for id := range votes {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	values := [][]byte{}
+	for _, id := range ids {
+		v := votes[uint8(id)]
+		if height > v.Height+common.VotingHeightDistance || len(v.Values) == 0 {
+			continue
+		}
+		t.VotedStaked += v.Staked
+		found := false
+		for i := range values {
+			if bytes.Equal(values[i], v.Values) {
+				t.Entries[i].Staked += v.Staked
+				t.Entries[i].DelegatedAccounts = append(t.Entries[i].DelegatedAccounts, id)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		enc, err := oqs.FromBytesToEncryptionConfig(v.Values)
+		if err != nil {
+			continue
+		}
+		values = append(values, v.Values)
+		t.Entries = append(t.Entries, TallyEntry{Config: enc, Staked: v.Staked, DelegatedAccounts: []int{id}})
+	}
+	VotesEncryptionMutex.Unlock()
+	t.CanPause = VerifyEncryptionForPausing(height, totalStaked, primary)
+	t.CanReplace = VerifyEncryptionForReplacing(height, totalStaked, primary)
+	return t
+}
+
+// StoreSchemeChange records encryption scheme applied at height
+func StoreSchemeChange(c SchemeChange) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return database.MainDB.Put(schemeChangeKey(c.Height, c.Primary), b)
+}
+
+// GetSchemeChanges returns history of encryption scheme changes in order of heights
+func GetSchemeChanges() ([]SchemeChange, error) {
+	values, err := database.MainDB.LoadAll(common.EncryptionChangesDBPrefix[:])
+	if err != nil {
+		return nil, err
+	}
+	cs := []SchemeChange{}
+	for _, v := range values {
+		c := SchemeChange{}
+		err = json.Unmarshal(v, &c)
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+	}
+	sort.SliceStable(cs, func(i, j int) bool {
+		return cs[i].Height < cs[j].Height
+	})
+	return cs, nil
+}
+
+// RemoveSchemeChangesFromDB forgets scheme changes applied at height, used when chain is reset
+func RemoveSchemeChangesFromDB(height int64) error {
+	for _, primary := range []bool{true, false} {
+		err := database.MainDB.Delete(schemeChangeKey(height, primary))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
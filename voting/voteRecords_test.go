@@ -0,0 +1,138 @@
+package voting
+
+import (
+	"testing"
+
+	"github.com/okuralabs/okura-node/common"
+	"github.com/okuralabs/okura-node/crypto/oqs"
+	"github.com/okuralabs/okura-node/database"
+)
+
+func encBytes(t *testing.T, c *oqs.ConfigEnc) []byte {
+	b, err := oqs.GenerateBytesFromParams(c.SigName, c.PubKeyLength, c.PrivateKeyLength, c.SignatureLength, c.IsPaused)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func restoreVotes() func() {
+	v1, v2 := VotesEncryption1, VotesEncryption2
+	VotesEncryption1, VotesEncryption2 = map[uint8]Votes{}, map[uint8]Votes{}
+	return func() { VotesEncryption1, VotesEncryption2 = v1, v2 }
+}
+
+func TestVoteRecords_LastVoteOfAccountKept(t *testing.T) {
+	defer restoreVotes()()
+	const height = int64(1) << 40
+	defer database.MainDB.Delete(voteRecordsKey(height))
+	defer database.MainDB.Delete(voteRecordsKey(height + 2))
+	enc1, enc2 := encBytes(t, oqs.NewConfigEnc1()), encBytes(t, oqs.NewConfigEnc2())
+
+	if err := SaveVotesEncryption1(enc1, height, common.GetDelegatedAccountAddress(1), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveVotesEncryption1(enc2, height, common.GetDelegatedAccountAddress(1), 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveVotesEncryption2(enc2, height, common.GetDelegatedAccountAddress(1), 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveVotesEncryption1(enc1, height+2, common.GetDelegatedAccountAddress(2), 30); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := LoadVoteRecords(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 2 {
+		t.Fatalf("Expected one vote for every scheme, got %+v", rs)
+	}
+	for _, r := range rs {
+		if r.DelegatedAccount != 1 || r.Staked != 20 || r.Config.SigName != oqs.NewConfigEnc2().SigName {
+			t.Errorf("Expected last vote of delegated account, got %+v", r)
+		}
+	}
+	if rs, err = LoadVoteRecordsInRange(height, height+2); err != nil || len(rs) != 3 {
+		t.Errorf("Expected votes of range without missing height, got %v, %v", len(rs), err)
+	}
+	if _, err = LoadVoteRecordsInRange(height, height+common.MaxOracleHistoryRange); err == nil {
+		t.Errorf("Expected too large range rejected")
+	}
+}
+
+func TestTally_GroupsVotesAndThresholds(t *testing.T) {
+	defer restoreVotes()()
+	const height = int64(100)
+	enc1, enc2 := encBytes(t, oqs.NewConfigEnc1()), encBytes(t, oqs.NewConfigEnc2())
+	tests := []struct {
+		name        string
+		totalStaked int64
+		canPause    bool
+		canReplace  bool
+	}{
+		{"above two thirds", 100, true, true},
+		{"above one third", 200, true, false},
+		{"below one third", 300, false, false},
+	}
+	for _, tt := range tests {
+		VotesEncryption1 = map[uint8]Votes{
+			1: {Values: enc1, Height: height, Staked: 40},
+			2: {Values: enc1, Height: height, Staked: 40},
+			3: {Values: enc2, Height: height, Staked: 10},
+			4: {Values: enc1, Height: height - common.VotingHeightDistance - 1, Staked: 50},
+		}
+		tl := Tally(height, tt.totalStaked, true)
+		if len(tl.Entries) != 2 || tl.VotedStaked != 90 {
+			t.Fatalf("%s: Expected two configurations with 90 staked, got %+v", tt.name, tl)
+		}
+		if tl.Entries[0].Staked != 80 || len(tl.Entries[0].DelegatedAccounts) != 2 || tl.Entries[1].DelegatedAccounts[0] != 3 {
+			t.Errorf("%s: Expected votes grouped by configuration, got %+v", tt.name, tl.Entries)
+		}
+		if tl.CanPause != tt.canPause || tl.CanReplace != tt.canReplace {
+			t.Errorf("%s: Expected pause %v and replace %v, got %v and %v", tt.name, tt.canPause, tt.canReplace,
+				tl.CanPause, tl.CanReplace)
+		}
+	}
+	if tl := Tally(height, 100, false); len(tl.Entries) != 0 || tl.CanPause {
+		t.Errorf("Expected no votes for secondary scheme, got %+v", tl)
+	}
+}
+
+func TestSchemeChanges_HistoryAndRemove(t *testing.T) {
+	const height = int64(1) << 40
+	defer RemoveSchemeChangesFromDB(height)
+	defer RemoveSchemeChangesFromDB(height + 1)
+	for _, c := range []SchemeChange{
+		{Height: height + 1, Primary: true, Config: *oqs.NewConfigEnc1()},
+		{Height: height, Primary: false, Config: *oqs.NewConfigEnc2()},
+	} {
+		if err := StoreSchemeChange(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := func() []SchemeChange {
+		cs, err := GetSchemeChanges()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ours := []SchemeChange{}
+		for _, c := range cs {
+			if c.Height >= height {
+				ours = append(ours, c)
+			}
+		}
+		return ours
+	}
+	cs := changes()
+	if len(cs) != 2 || cs[0].Height != height || cs[0].Primary || cs[1].Config.SigName != oqs.NewConfigEnc1().SigName {
+		t.Fatalf("Expected scheme changes in order of heights, got %+v", cs)
+	}
+	if err := RemoveSchemeChangesFromDB(height + 1); err != nil {
+		t.Fatal(err)
+	}
+	if cs = changes(); len(cs) != 1 || cs[0].Height != height {
+		t.Errorf("Expected change of reset block removed, got %+v", cs)
+	}
+}
@@ -45,6 +45,10 @@ func SaveVotesEncryption1(value []byte, height int64, delegatedAccount common.Ad
 			Height: height,
 			Staked: staked,
 		}
+		err = storeVoteRecord(value, height, uint8(id), staked, true)
+		if err != nil {
+			logger.GetLogger().Println("cannot store vote record", err)
+		}
 	} else {
 		return errors.New("invalid height in voting, 1")
 	}
@@ -74,6 +78,10 @@ func SaveVotesEncryption2(value []byte, height int64, delegatedAccount common.Ad
 			Height: height,
 			Staked: staked,
 		}
+		err = storeVoteRecord(value, height, uint8(id), staked, false)
+		if err != nil {
+			logger.GetLogger().Println("cannot store vote record", err)
+		}
 	} else {
 		return errors.New("invalid height in voting, 2")
 	}